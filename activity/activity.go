@@ -0,0 +1,265 @@
+package activity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"todo/dates"
+	"todo/db"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Weeks is how many weeks the heatmap and the sparklines cover, a year.
+const Weeks = 53
+
+// levels are the characters of every intensity of the heatmap and sparklines,
+// from no tasks completed to the most completed in a day or a week.
+var (
+	heatmapLevels      = []string{"■", "■", "■", "■", "■"}
+	heatmapASCIILevels = []string{".", "-", "+", "*", "#"}
+	sparkLevels        = []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+	sparkASCIILevels   = []string{"_", ".", "-", "~", "=", "+", "*", "#"}
+)
+
+var levelColors = []lipgloss.Color{"237", "22", "28", "34", "40"}
+
+var (
+	labelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+
+	titleStyle = lipgloss.NewStyle().
+			Bold(true)
+
+	sparkStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("40"))
+)
+
+// Activity holds the tasks completed every day of the period, which starts on
+// a Monday and lasts Weeks weeks.
+type Activity struct {
+	From    time.Time
+	Today   time.Time
+	Days    map[time.Time]int
+	TagWeek map[string][]int
+}
+
+// Compute counts the todos completed every day and every week for every tag,
+// from the Monday Weeks-1 weeks before now to today.
+func Compute(todos []db.Todo, now time.Time) Activity {
+	a := Activity{
+		From:    From(now),
+		Today:   dates.StartOfDay(now),
+		Days:    map[time.Time]int{},
+		TagWeek: map[string][]int{},
+	}
+
+	for _, todo := range todos {
+		if !todo.DateCompleted.Valid {
+			continue
+		}
+
+		day := dates.StartOfDay(todo.DateCompleted.Time.In(now.Location()))
+
+		if day.Before(a.From) || day.After(a.Today) {
+			continue
+		}
+
+		a.Days[day]++
+
+		if _, ok := a.TagWeek[todo.Tag]; !ok {
+			a.TagWeek[todo.Tag] = make([]int, Weeks)
+		}
+
+		a.TagWeek[todo.Tag][dates.DaysBetween(a.From, day)/7]++
+	}
+
+	return a
+}
+
+// From returns the first day of the heatmap ending on the week of now.
+func From(now time.Time) time.Time {
+	return dates.StartOfWeek(now).AddDate(0, 0, -7*(Weeks-1))
+}
+
+// Streaks returns the days in a row with completed tasks up to today, or up to
+// yesterday when nothing has been completed today yet, and the longest run of
+// the period.
+func (a Activity) Streaks() (current, longest int) {
+	run := 0
+
+	for day := a.From; !day.After(a.Today); day = day.AddDate(0, 0, 1) {
+		if a.Days[day] > 0 {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	day := a.Today
+	if a.Days[day] == 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	for ; !day.Before(a.From) && a.Days[day] > 0; day = day.AddDate(0, 0, -1) {
+		current++
+	}
+
+	return current, longest
+}
+
+// Render draws the heatmap, the streaks and a sparkline of the weekly
+// throughput of every tag. The ASCII version doesn't use colors nor other
+// characters than ASCII ones.
+func Render(a Activity, ascii bool) string {
+	var b strings.Builder
+
+	total := 0
+	for _, count := range a.Days {
+		total += count
+	}
+
+	b.WriteString(titleStyle.Render(plural(total, "task")+" completed in the last year") + "\n\n")
+	b.WriteString(heatmap(a, ascii))
+
+	current, longest := a.Streaks()
+	b.WriteString(fmt.Sprintf("\ncurrent streak %s · longest streak %s\n", plural(current, "day"), plural(longest, "day")))
+
+	if len(a.TagWeek) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("\n" + titleStyle.Render("Completed per week") + "\n")
+
+	tags := []string{}
+	width := 0
+	for tag := range a.TagWeek {
+		tags = append(tags, tag)
+		width = max(width, len(tagName(tag)))
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		weeks := a.TagWeek[tag]
+		sum := 0
+		for _, count := range weeks {
+			sum += count
+		}
+
+		name := tagName(tag)
+		b.WriteString(labelStyle.Render(name+strings.Repeat(" ", width-len(name))) + " " + sparkline(weeks, ascii) + fmt.Sprintf(" %d\n", sum))
+	}
+
+	return b.String()
+}
+
+func heatmap(a Activity, ascii bool) string {
+	most := 0
+	for _, count := range a.Days {
+		most = max(most, count)
+	}
+
+	levels := heatmapLevels
+	if ascii {
+		levels = heatmapASCIILevels
+	}
+
+	// The first line has the months over the week they start on, the rest
+	// one line for every day of the week.
+	months := []byte(strings.Repeat(" ", Weeks*2))
+	for week := 0; week < Weeks; week++ {
+		monday := a.From.AddDate(0, 0, 7*week)
+		if first := dates.StartOfMonth(monday.AddDate(0, 0, 6)); !first.Before(monday) && week*2+3 <= len(months) {
+			copy(months[week*2:], first.Format("Jan"))
+		}
+	}
+
+	lines := []string{"    " + labelStyle.Render(strings.TrimRight(string(months), " "))}
+
+	for weekday := 0; weekday < 7; weekday++ {
+		label := "   "
+		if weekday%2 == 0 {
+			label = a.From.AddDate(0, 0, weekday).Format("Mon")
+		}
+
+		var line strings.Builder
+		line.WriteString(labelStyle.Render(label) + " ")
+
+		for week := 0; week < Weeks; week++ {
+			day := a.From.AddDate(0, 0, 7*week+weekday)
+
+			if day.After(a.Today) {
+				break
+			}
+
+			level := level(a.Days[day], most, len(levels)-1)
+
+			if ascii {
+				line.WriteString(levels[level] + " ")
+			} else {
+				line.WriteString(lipgloss.NewStyle().Foreground(levelColors[level]).Render(levels[level]) + " ")
+			}
+		}
+
+		lines = append(lines, strings.TrimRight(line.String(), " "))
+	}
+
+	legend := "less "
+	for i := range levels {
+		if ascii {
+			legend += levels[i] + " "
+		} else {
+			legend += lipgloss.NewStyle().Foreground(levelColors[i]).Render(levels[i]) + " "
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n    " + labelStyle.Render(legend+"more") + "\n"
+}
+
+func sparkline(counts []int, ascii bool) string {
+	most := 0
+	for _, count := range counts {
+		most = max(most, count)
+	}
+
+	levels := sparkLevels
+	if ascii {
+		levels = sparkASCIILevels
+	}
+
+	var b strings.Builder
+
+	for _, count := range counts {
+		b.WriteString(levels[level(count, most, len(levels)-1)])
+	}
+
+	if ascii {
+		return b.String()
+	}
+
+	return sparkStyle.Render(b.String())
+}
+
+// level scales the count to a level from 0 to top, only no tasks is level 0.
+func level(count, most, top int) int {
+	if count == 0 || most == 0 {
+		return 0
+	}
+	return max((count*top+most-1)/most, 1)
+}
+
+func tagName(tag string) string {
+	if tag == "" {
+		return "no tag"
+	}
+	return tag
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
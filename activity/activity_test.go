@@ -0,0 +1,111 @@
+package activity
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+func TestCompute(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+
+	if err != nil {
+		t.Skip("the time zone database isn't available")
+	}
+
+	// A Wednesday, the heatmap starts on Monday 2023-11-06.
+	now := time.Date(2024, 11, 6, 18, 0, 0, 0, madrid)
+	from := time.Date(2023, 11, 6, 0, 0, 0, 0, madrid)
+
+	done := func(tag string, completed time.Time) db.Todo {
+		return db.Todo{Tag: tag, State: db.Done, DateCompleted: sql.NullTime{Time: completed, Valid: true}}
+	}
+
+	todos := []db.Todo{
+		done("work", from),
+		done("work", from.Add(23*time.Hour)),
+		// After the change to summer time on 2024-03-31, in UTC.
+		done("work", time.Date(2024, 4, 1, 0, 30, 0, 0, time.UTC)),
+		done("home", now.Add(-time.Hour)),
+		// Before the period and in the future.
+		done("home", from.Add(-time.Minute)),
+		done("home", now.Add(24*time.Hour)),
+		// Not completed.
+		{Tag: "home", State: db.Pending},
+	}
+
+	a := Compute(todos, now)
+
+	if !a.From.Equal(from) {
+		t.Errorf("From = %v, want %v", a.From, from)
+	}
+
+	days := map[time.Time]int{
+		from: 2,
+		time.Date(2024, 4, 1, 0, 0, 0, 0, madrid):  1,
+		time.Date(2024, 11, 6, 0, 0, 0, 0, madrid): 1,
+	}
+
+	if len(a.Days) != len(days) {
+		t.Errorf("Days = %v, want %v", a.Days, days)
+	}
+
+	for day, count := range days {
+		if a.Days[day] != count {
+			t.Errorf("Days[%v] = %d, want %d", day, a.Days[day], count)
+		}
+	}
+
+	// 2024-04-01 is the Monday that starts week 21, after a day of 23 hours.
+	weeks := map[string]map[int]int{
+		"work": {0: 2, 21: 1},
+		"home": {Weeks - 1: 1},
+	}
+
+	for tag, counts := range weeks {
+		if len(a.TagWeek[tag]) != Weeks {
+			t.Fatalf("TagWeek[%q] has %d weeks, want %d", tag, len(a.TagWeek[tag]), Weeks)
+		}
+
+		for week, count := range a.TagWeek[tag] {
+			if count != counts[week] {
+				t.Errorf("TagWeek[%q][%d] = %d, want %d", tag, week, count, counts[week])
+			}
+		}
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	now := time.Date(2024, 3, 13, 12, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		daysAgo          []int
+		current, longest int
+	}{
+		{name: "none", daysAgo: nil, current: 0, longest: 0},
+		{name: "today", daysAgo: []int{0, 1, 2}, current: 3, longest: 3},
+		{name: "up to yesterday", daysAgo: []int{1, 2}, current: 2, longest: 2},
+		{name: "broken", daysAgo: []int{0, 2, 3, 4, 5}, current: 1, longest: 4},
+		{name: "before yesterday", daysAgo: []int{2, 3}, current: 0, longest: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos := []db.Todo{}
+
+			for _, ago := range tt.daysAgo {
+				completed := today.AddDate(0, 0, -ago).Add(10 * time.Hour)
+				todos = append(todos, db.Todo{State: db.Done, DateCompleted: sql.NullTime{Time: completed, Valid: true}})
+			}
+
+			current, longest := Compute(todos, now).Streaks()
+
+			if current != tt.current || longest != tt.longest {
+				t.Errorf("Streaks() = %d, %d, want %d, %d", current, longest, tt.current, tt.longest)
+			}
+		})
+	}
+}
@@ -45,6 +45,7 @@ type (
 
 type Model struct {
 	keys      keyMap
+	title     string
 	Value     string
 	textInput textinput.Model
 	err       error
@@ -60,6 +61,7 @@ func AddInputModel() Model {
 
 	return Model{
 		keys:      keys,
+		title:     "Input here your new task:",
 		Value:     "",
 		textInput: ti,
 		err:       nil,
@@ -67,6 +69,40 @@ func AddInputModel() Model {
 	}
 }
 
+// EditInputModel returns the input to change the name of a task, it starts
+// with the current name.
+func EditInputModel(value string) Model {
+	m := AddInputModel()
+	m.title = "Change the name of your task:"
+	m.keys.Confirm.SetHelp("enter", "save task")
+	m.textInput.SetValue(value)
+
+	return m
+}
+
+// TagInputModel returns the input to change the tag of a task, it starts with
+// the current tag.
+func TagInputModel(value string) Model {
+	m := AddInputModel()
+	m.title = "Change the tag of your task:"
+	m.keys.Confirm.SetHelp("enter", "save tag")
+	m.textInput.Placeholder = "no tag"
+	m.textInput.SetValue(value)
+
+	return m
+}
+
+// DeferInputModel returns the input to choose the new due date of a task.
+func DeferInputModel(value string) Model {
+	m := AddInputModel()
+	m.title = "Defer your task until (YYYY-MM-DD, today or tomorrow):"
+	m.keys.Confirm.SetHelp("enter", "defer task")
+	m.textInput.Placeholder = "YYYY-MM-DD"
+	m.textInput.SetValue(value)
+
+	return m
+}
+
 func (m Model) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -95,7 +131,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m Model) View() string {
 	helpView := m.help.View(m.keys)
 	return fmt.Sprintf(
-		"Input here your new task:\n\n%s\n\n%s",
+		"%s\n\n%s\n\n%s",
+		m.title,
 		m.textInput.View(),
 		helpView,
 	) + "\n"
@@ -0,0 +1,125 @@
+package agenda
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"todo/dates"
+	"todo/db"
+	list_table "todo/list-table"
+	todo_table "todo/todo-table"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	dayStyle = lipgloss.NewStyle().
+			Bold(true)
+
+	todayStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("212"))
+
+	overdueStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("196"))
+
+	emptyStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+)
+
+// columns are the columns of the tables of every day, the date is already in
+// the title of the section.
+var columns = []todo_table.Column{
+	todo_table.IDColumn,
+	todo_table.TitleColumn,
+	todo_table.TagColumn,
+	todo_table.StateColumn,
+	todo_table.DueColumn,
+}
+
+// Section is a day of the agenda, or the overdue tasks pinned before them.
+type Section struct {
+	Day     time.Time
+	Today   bool
+	Overdue bool
+	Todos   []db.Todo
+}
+
+// Build places the todos under the days from from, inclusive, to to, exclusive.
+// The open todos are placed under their due date and the finished ones under
+// their completion date. The open todos due before today are overdue and go
+// to the first section instead, whatever the period is. Only today and the
+// days with tasks have a section.
+func Build(todos []db.Todo, from, to, now time.Time) []Section {
+	today := dates.StartOfDay(now)
+	overdue := Section{Overdue: true}
+	days := map[time.Time][]db.Todo{}
+
+	for _, todo := range todos {
+		switch {
+		case todo.State.Closed() && todo.DateCompleted.Valid:
+			day := dates.StartOfDay(todo.DateCompleted.Time.In(now.Location()))
+			days[day] = append(days[day], todo)
+		case !todo.State.Closed() && todo.DateDue.Valid:
+			day := dates.StartOfDay(todo.DateDue.Time.In(now.Location()))
+
+			if day.Before(today) {
+				overdue.Todos = append(overdue.Todos, todo)
+			} else {
+				days[day] = append(days[day], todo)
+			}
+		}
+	}
+
+	sections := []Section{}
+
+	if len(overdue.Todos) > 0 {
+		todo_table.SortTodos(overdue.Todos, todo_table.Order{Field: todo_table.SortByDue})
+		sections = append(sections, overdue)
+	}
+
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if len(days[day]) == 0 && !day.Equal(today) {
+			continue
+		}
+
+		sections = append(sections, Section{Day: day, Today: day.Equal(today), Todos: days[day]})
+	}
+
+	return sections
+}
+
+// Render renders every section as a title followed by a table with the style
+// of list_table, the width includes the border of the tables.
+func Render(sections []Section, width int) string {
+	var b strings.Builder
+
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		b.WriteString(title(section) + "\n")
+
+		if len(section.Todos) == 0 {
+			b.WriteString(emptyStyle.Render("nothing due") + "\n")
+			continue
+		}
+
+		b.WriteString(list_table.Render(section.Todos, width, todo_table.WithColumns(columns)) + "\n")
+	}
+
+	return b.String()
+}
+
+func title(section Section) string {
+	switch {
+	case section.Overdue:
+		return overdueStyle.Render(fmt.Sprintf("Overdue (%d)", len(section.Todos)))
+	case section.Today:
+		return todayStyle.Render(section.Day.Format("Monday 2 January") + " · today")
+	default:
+		return dayStyle.Render(section.Day.Format("Monday 2 January"))
+	}
+}
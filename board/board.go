@@ -0,0 +1,301 @@
+package board
+
+import (
+	"fmt"
+	"strings"
+	"todo/db"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+// Store is the part of the database used by the board, the tasks are moved as
+// soon as the keys are pressed.
+type Store interface {
+	GetTasks(tag string) ([]db.Todo, error)
+	MoveTodo(todoId int, state db.Status) error
+}
+
+type keyMap struct {
+	Up        key.Binding
+	Down      key.Binding
+	Left      key.Binding
+	Right     key.Binding
+	MoveLeft  key.Binding
+	MoveRight key.Binding
+	Help      key.Binding
+	Quit      key.Binding
+}
+
+// ShortHelp returns keybindings to be shown in the mini help view. It's part
+// of the key.Map interface.
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.MoveLeft, k.MoveRight, k.Help, k.Quit}
+}
+
+// FullHelp returns keybindings for the expanded help view. It's part of the
+// key.Map interface.
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.Left, k.Right}, // first column
+		{k.MoveLeft, k.MoveRight},       // second column
+		{k.Help, k.Quit},                // third column
+	}
+}
+
+var keys = keyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "move up"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "move down"),
+	),
+	Left: key.NewBinding(
+		key.WithKeys("left", "h"),
+		key.WithHelp("←/h", "previous column"),
+	),
+	Right: key.NewBinding(
+		key.WithKeys("right", "l"),
+		key.WithHelp("→/l", "next column"),
+	),
+	MoveLeft: key.NewBinding(
+		key.WithKeys("shift+left", "H"),
+		key.WithHelp("shift+←/H", "move task to the previous state"),
+	),
+	MoveRight: key.NewBinding(
+		key.WithKeys("shift+right", "L"),
+		key.WithHelp("shift+→/L", "move task to the next state"),
+	),
+	Help: key.NewBinding(
+		key.WithKeys("?"),
+		key.WithHelp("?", "toggle help"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q/esc/ctrl+c", "quit"),
+	),
+}
+
+const (
+	minColumnWidth = 10
+	defaultHeight  = 10
+)
+
+var (
+	columnStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(lipgloss.Color("240")).
+			Padding(0, 1)
+
+	currentColumnStyle = columnStyle.Copy().
+				BorderForeground(lipgloss.Color("57"))
+
+	headerStyle = lipgloss.NewStyle().
+			Bold(true).
+			MarginBottom(1)
+
+	selectedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("229")).
+			Background(lipgloss.Color("57"))
+
+	closedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196"))
+)
+
+type column struct {
+	state db.State
+	todos []db.Todo
+}
+
+// Model is a kanban board with a column for every state of the workflow.
+type Model struct {
+	store   Store
+	tag     string
+	keys    keyMap
+	help    help.Model
+	columns []column
+	col     int
+	row     int
+	width   int
+	height  int
+	err     error
+}
+
+func New(store Store, tag string) (Model, error) {
+	m := Model{
+		store:  store,
+		tag:    tag,
+		keys:   keys,
+		help:   help.New(),
+		height: defaultHeight,
+	}
+
+	return m, m.reload()
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.help.Width = msg.Width
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+		case key.Matches(msg, m.keys.Up):
+			m.row = max(m.row-1, 0)
+		case key.Matches(msg, m.keys.Down):
+			m.row = min(m.row+1, max(len(m.columns[m.col].todos)-1, 0))
+		case key.Matches(msg, m.keys.Left):
+			m.selectColumn(m.col - 1)
+		case key.Matches(msg, m.keys.Right):
+			m.selectColumn(m.col + 1)
+		case key.Matches(msg, m.keys.MoveLeft):
+			m.moveTask(-1)
+		case key.Matches(msg, m.keys.MoveRight):
+			m.moveTask(1)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Model) selectColumn(col int) {
+	m.col = clamp(col, 0, len(m.columns)-1)
+	m.row = clamp(m.row, 0, max(len(m.columns[m.col].todos)-1, 0))
+}
+
+// moveTask moves the selected task to the state in the next or the previous
+// column and keeps it selected.
+func (m *Model) moveTask(step int) {
+	todos := m.columns[m.col].todos
+	target := m.col + step
+
+	if len(todos) == 0 || target < 0 || target >= len(m.columns) {
+		return
+	}
+
+	todo := todos[m.row]
+
+	if m.err = m.store.MoveTodo(todo.ID, m.columns[target].state.ID); m.err != nil {
+		return
+	}
+
+	if m.err = m.reload(); m.err != nil {
+		return
+	}
+
+	m.col = target
+	m.row = 0
+
+	for i, t := range m.columns[target].todos {
+		if t.ID == todo.ID {
+			m.row = i
+		}
+	}
+}
+
+// reload reads the tasks again and places them in the column of their state.
+func (m *Model) reload() error {
+	todos, err := m.store.GetTasks(m.tag)
+
+	if err != nil {
+		return err
+	}
+
+	m.columns = []column{}
+	index := map[db.Status]int{}
+
+	for _, state := range db.States() {
+		index[state.ID] = len(m.columns)
+		m.columns = append(m.columns, column{state: state})
+	}
+
+	for _, todo := range todos {
+		if i, ok := index[todo.State]; ok {
+			m.columns[i].todos = append(m.columns[i].todos, todo)
+		}
+	}
+
+	m.selectColumn(m.col)
+
+	return nil
+}
+
+func (m Model) View() string {
+	width := minColumnWidth
+
+	if m.width > 0 {
+		width = max(m.width/len(m.columns)-4, minColumnWidth)
+	}
+
+	// The header of the columns takes two lines, their border two more and
+	// the help and the error one line each.
+	rows := max(m.height-6-lipgloss.Height(m.help.View(m.keys)), 1)
+
+	views := []string{}
+
+	for i, c := range m.columns {
+		views = append(views, m.columnView(i, c, width, rows))
+	}
+
+	view := lipgloss.JoinHorizontal(lipgloss.Top, views...) + "\n"
+
+	if m.err != nil {
+		view += errorStyle.Render(m.err.Error())
+	}
+
+	return view + "\n" + m.help.View(m.keys)
+}
+
+func (m Model) columnView(i int, c column, width, rows int) string {
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render(runewidth.Truncate(fmt.Sprintf("%s (%d)", c.state.Name, len(c.todos)), width, "…")))
+
+	// Scroll the column so the selected task is always visible.
+	start := 0
+	if i == m.col && m.row >= rows {
+		start = m.row - rows + 1
+	}
+
+	for j := start; j < min(start+rows, len(c.todos)); j++ {
+		todo := c.todos[j]
+		line := runewidth.FillRight(runewidth.Truncate(fmt.Sprintf("#%d %s", todo.ID, todo.Todo), width, "…"), width)
+
+		switch {
+		case i == m.col && j == m.row:
+			line = selectedStyle.Render(line)
+		case c.state.Closed:
+			line = closedStyle.Render(line)
+		}
+
+		b.WriteString("\n" + line)
+	}
+
+	style := columnStyle
+	if i == m.col {
+		style = currentColumnStyle
+	}
+
+	return style.Width(width + 2).Height(rows + 2).Render(b.String())
+}
+
+func clamp(v, low, high int) int {
+	return min(max(v, low), high)
+}
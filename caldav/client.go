@@ -0,0 +1,371 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrPrecondition is returned when a resource changed on the server since its
+// ETag was read, or already exists when it was going to be created.
+var ErrPrecondition = errors.New("the task changed on the server")
+
+// errInvalidToken is returned when the server doesn't accept the sync token,
+// the changes have to be asked again without it.
+var errInvalidToken = errors.New("invalid sync token")
+
+// Client talks to a calendar collection of a CalDAV server, RFC 4791.
+type Client struct {
+	http       *http.Client
+	collection *url.URL
+	user       string
+	password   string
+}
+
+// NewClient returns a client of the calendar at the server url, the calendar
+// is the last segment of the collection path. The user is optional.
+func NewClient(serverURL, calendar, user, password string) (*Client, error) {
+	u, err := url.Parse(serverURL)
+
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("Not valid url %q, use http or https", serverURL)
+	}
+
+	if calendar = strings.Trim(calendar, "/"); calendar == "" {
+		return nil, errors.New("Not valid calendar, it is empty")
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + calendar + "/"
+
+	return &Client{
+		http:       &http.Client{Timeout: 30 * time.Second},
+		collection: u,
+		user:       user,
+		password:   password,
+	}, nil
+}
+
+// URL returns the url of the calendar collection.
+func (c *Client) URL() string {
+	return c.collection.String()
+}
+
+// Resource is a calendar object of the collection.
+type Resource struct {
+	Href string
+	ETag string
+}
+
+// Changes lists the resources changed and deleted since the sync token, all the
+// resources of the collection when the token is empty. Full is true when
+// the listing has every resource, so the ones missing from it were deleted.
+type Changes struct {
+	Changed []Resource
+	Deleted []string
+	Token   string
+	Full    bool
+}
+
+// Changes asks for the changes since the token with a sync-collection report,
+// RFC 6578. The whole collection is listed when the server doesn't accept the
+// token and with a PROPFIND when it doesn't support the report.
+func (c *Client) Changes(token string) (Changes, error) {
+	changes, err := c.syncCollection(token)
+
+	if errors.Is(err, errInvalidToken) && token != "" {
+		token = ""
+		changes, err = c.syncCollection(token)
+	}
+
+	if errors.Is(err, errInvalidToken) {
+		resources, err := c.list()
+		return Changes{Changed: resources, Full: true}, err
+	}
+
+	changes.Full = token == ""
+
+	return changes, err
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Status   string `xml:"DAV: status"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			ETag   string `xml:"DAV: prop>getetag"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+	SyncToken string `xml:"DAV: sync-token"`
+}
+
+func (c *Client) syncCollection(token string) (Changes, error) {
+	var body bytes.Buffer
+
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	body.WriteString(`<d:sync-collection xmlns:d="DAV:"><d:sync-token>`)
+	xml.EscapeText(&body, []byte(token))
+	body.WriteString(`</d:sync-token><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop></d:sync-collection>`)
+
+	status, err := c.multistatus("REPORT", c.collection.String(), "", &body)
+
+	if err != nil {
+		return Changes{}, err
+	}
+
+	changes := Changes{Token: status.SyncToken}
+
+	for _, response := range status.Responses {
+		href, ok := c.member(response.Href)
+
+		if !ok {
+			continue
+		}
+
+		if statusCode(response.Status) == http.StatusNotFound {
+			changes.Deleted = append(changes.Deleted, href)
+			continue
+		}
+
+		for _, propstat := range response.Propstat {
+			if statusCode(propstat.Status) == http.StatusOK {
+				changes.Changed = append(changes.Changed, Resource{Href: href, ETag: propstat.ETag})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// list returns every resource of the collection with a PROPFIND.
+func (c *Client) list() ([]Resource, error) {
+	body := strings.NewReader(`<?xml version="1.0" encoding="utf-8"?><d:propfind xmlns:d="DAV:"><d:prop><d:getetag/></d:prop></d:propfind>`)
+
+	status, err := c.multistatus("PROPFIND", c.collection.String(), "1", body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []Resource{}
+
+	for _, response := range status.Responses {
+		href, ok := c.member(response.Href)
+
+		if !ok {
+			continue
+		}
+
+		for _, propstat := range response.Propstat {
+			if statusCode(propstat.Status) == http.StatusOK && propstat.ETag != "" {
+				resources = append(resources, Resource{Href: href, ETag: propstat.ETag})
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+func (c *Client) multistatus(method, target, depth string, body io.Reader) (multistatus, error) {
+	var status multistatus
+
+	req, err := c.request(method, target, body)
+
+	if err != nil {
+		return status, err
+	}
+
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	if depth != "" {
+		req.Header.Set("Depth", depth)
+	}
+
+	res, err := c.http.Do(req)
+
+	if err != nil {
+		return status, err
+	}
+
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusMultiStatus:
+	case method == "REPORT" && (res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusConflict ||
+		res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusNotImplemented || res.StatusCode == http.StatusMethodNotAllowed):
+		return status, errInvalidToken
+	default:
+		return status, responseError(method, target, res)
+	}
+
+	if err := xml.NewDecoder(res.Body).Decode(&status); err != nil {
+		return status, fmt.Errorf("Not valid answer of the CalDAV server to %s: %w", method, err)
+	}
+
+	return status, nil
+}
+
+// member returns the absolute url of a href of the collection, the collection
+// itself isn't a member.
+func (c *Client) member(href string) (string, bool) {
+	u, err := c.collection.Parse(href)
+
+	if err != nil || strings.TrimSuffix(u.Path, "/") == strings.TrimSuffix(c.collection.Path, "/") {
+		return "", false
+	}
+
+	return u.String(), true
+}
+
+// Href returns the url of a new calendar object named after the uid.
+func (c *Client) Href(uid string) string {
+	return c.collection.JoinPath(strings.ReplaceAll(uid, "/", "-") + ".ics").String()
+}
+
+// Get returns the calendar object and its ETag.
+func (c *Client) Get(href string) ([]byte, string, error) {
+	req, err := c.request(http.MethodGet, href, nil)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := c.http.Do(req)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", responseError(http.MethodGet, href, res)
+	}
+
+	body, err := io.ReadAll(res.Body)
+
+	return body, res.Header.Get("ETag"), err
+}
+
+// Put saves the calendar object when the one in the server still has the
+// ETag, an empty ETag creates it and fails when it already exists. The new
+// ETag is returned, asking for it when the server doesn't send it.
+func (c *Client) Put(href string, body []byte, etag string) (string, error) {
+	req, err := c.request(http.MethodPut, href, bytes.NewReader(body))
+
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+
+	if etag == "" {
+		req.Header.Set("If-None-Match", "*")
+	} else {
+		req.Header.Set("If-Match", etag)
+	}
+
+	res, err := c.http.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusPreconditionFailed:
+		return "", ErrPrecondition
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return "", responseError(http.MethodPut, href, res)
+	case res.Header.Get("ETag") != "":
+		return res.Header.Get("ETag"), nil
+	}
+
+	status, err := c.multistatus("PROPFIND", href, "0", strings.NewReader(`<?xml version="1.0" encoding="utf-8"?><d:propfind xmlns:d="DAV:"><d:prop><d:getetag/></d:prop></d:propfind>`))
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, response := range status.Responses {
+		for _, propstat := range response.Propstat {
+			if propstat.ETag != "" {
+				return propstat.ETag, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// Delete deletes the calendar object when the one in the server still has the
+// ETag, one already deleted isn't an error.
+func (c *Client) Delete(href, etag string) error {
+	req, err := c.request(http.MethodDelete, href, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	res, err := c.http.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusPreconditionFailed:
+		return ErrPrecondition
+	case res.StatusCode == http.StatusNotFound:
+		return nil
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return responseError(http.MethodDelete, href, res)
+	}
+
+	return nil
+}
+
+func (c *Client) request(method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, target, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	return req, nil
+}
+
+func responseError(method, target string, res *http.Response) error {
+	return fmt.Errorf("the CalDAV server answered %s to %s %s", res.Status, method, target)
+}
+
+// statusCode returns the code of a status line like "HTTP/1.1 200 OK".
+func statusCode(status string) int {
+	fields := strings.Fields(status)
+
+	if len(fields) < 2 {
+		return 0
+	}
+
+	code, _ := strconv.Atoi(fields[1])
+
+	return code
+}
@@ -0,0 +1,473 @@
+package caldav
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"todo/db"
+	"todo/ical"
+)
+
+// Policy decides which side wins when a task changed both in the database and
+// on the server since the last sync.
+type Policy string
+
+const (
+	PreferDatabase Policy = "db"
+	PreferServer   Policy = "server"
+	PreferNewer    Policy = "newer"
+)
+
+// ParsePolicy parses the value of the --conflict flag of the sync.
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case PreferDatabase, PreferServer, PreferNewer:
+		return Policy(s), nil
+	default:
+		return "", errors.New("Not valid conflict policy, use one of: db, server, newer")
+	}
+}
+
+// Store is the part of the database used by the sync.
+type Store interface {
+	GetTasksByFilter(filter db.Filter) ([]db.Todo, error)
+	AddTodo(todo db.Todo) (int, error)
+	UpdateTodo(todo db.Todo) error
+	DeleteTodo(todoId int) error
+	SyncItems(source string) ([]db.SyncItem, error)
+	SaveSyncItems(source string, items []db.SyncItem) error
+	SyncToken(source string) (string, error)
+	SaveSyncToken(source, token string) error
+}
+
+// Report counts the changes made by a sync.
+type Report struct {
+	Pulled, Pushed              int
+	DeletedLocal, DeletedRemote int
+	Conflicts                   int
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"pulled %d, pushed %d, deleted %d here and %d on the server · %d conflicts",
+		r.Pulled, r.Pushed, r.DeletedLocal, r.DeletedRemote, r.Conflicts,
+	)
+}
+
+// Sync pulls the tasks changed on the server since the last sync and pushes the
+// ones changed in the database, every task is a VTODO of the calendar.
+//
+// The sync items map the ids of the todos to the urls of their calendar
+// objects, with the ETag they had and the modification time the todo had after
+// the last sync. A calendar object changed on the server has another ETag and
+// a todo changed in the database another modification time. When both changed
+// the policy decides which one wins, newer compares the LAST-MODIFIED of the
+// calendar object with the modification time of the todo. A push rejected
+// because the object changed meanwhile is left for the next sync.
+func Sync(store Store, client *Client, policy Policy) (Report, error) {
+	var report Report
+
+	source := "caldav:" + client.URL()
+
+	token, err := store.SyncToken(source)
+
+	if err != nil {
+		return report, err
+	}
+
+	saved, err := store.SyncItems(source)
+
+	if err != nil {
+		return report, err
+	}
+
+	todos, err := store.GetTasksByFilter(db.Filter{})
+
+	if err != nil {
+		return report, err
+	}
+
+	changes, err := client.Changes(token)
+
+	if err != nil {
+		return report, err
+	}
+
+	s := syncer{
+		store:  store,
+		client: client,
+		policy: policy,
+		report: &report,
+		items:  map[string]*db.SyncItem{},
+		byTodo: map[int]*db.SyncItem{},
+		tasks:  map[int]db.Todo{},
+		pulled: map[int]bool{},
+		stale:  map[int]bool{},
+	}
+
+	for i := range saved {
+		s.link(&saved[i])
+	}
+
+	for _, todo := range todos {
+		s.tasks[todo.ID] = todo
+	}
+
+	deleted := changes.Deleted
+
+	if changes.Full {
+		listed := map[string]bool{}
+		for _, resource := range changes.Changed {
+			listed[resource.Href] = true
+		}
+
+		for href := range s.items {
+			if !listed[href] {
+				deleted = append(deleted, href)
+			}
+		}
+	}
+
+	for _, resource := range changes.Changed {
+		if item := s.items[resource.Href]; item != nil && item.ETag == resource.ETag {
+			continue
+		}
+
+		if err := s.pull(resource); err != nil {
+			return report, err
+		}
+	}
+
+	for _, href := range deleted {
+		if err := s.pullDeletion(href); err != nil {
+			return report, err
+		}
+	}
+
+	if err := s.push(); err != nil {
+		return report, err
+	}
+
+	// The modification times are read again, the ones of the pulled todos
+	// changed while saving them.
+	todos, err = store.GetTasksByFilter(db.Filter{})
+
+	if err != nil {
+		return report, err
+	}
+
+	fresh := map[int]db.Todo{}
+	for _, todo := range todos {
+		fresh[todo.ID] = todo
+	}
+
+	items := []db.SyncItem{}
+
+	for _, item := range s.items {
+		if todo, ok := fresh[item.TodoID]; ok && !s.stale[item.TodoID] {
+			item.Snapshot = snapshot(todo)
+		}
+
+		items = append(items, *item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].TodoID < items[j].TodoID
+	})
+
+	if err := store.SaveSyncItems(source, items); err != nil {
+		return report, err
+	}
+
+	return report, store.SaveSyncToken(source, changes.Token)
+}
+
+type syncer struct {
+	store  Store
+	client *Client
+	policy Policy
+	report *Report
+	items  map[string]*db.SyncItem // by url
+	byTodo map[int]*db.SyncItem
+	tasks  map[int]db.Todo
+	// pulled are the todos saved from the server in this sync, they aren't
+	// pushed back.
+	pulled map[int]bool
+	// stale are the todos whose push was rejected, their snapshot is kept so
+	// they are still changed in the next sync.
+	stale map[int]bool
+}
+
+func (s *syncer) link(item *db.SyncItem) {
+	s.items[item.RemoteID] = item
+	s.byTodo[item.TodoID] = item
+}
+
+func (s *syncer) unlink(item *db.SyncItem) {
+	delete(s.items, item.RemoteID)
+	delete(s.byTodo, item.TodoID)
+}
+
+// changed reports whether the todo changed in the database since the last
+// sync.
+func (s *syncer) changed(item *db.SyncItem) bool {
+	task, ok := s.tasks[item.TodoID]
+	return ok && snapshot(task) != item.Snapshot
+}
+
+func snapshot(todo db.Todo) string {
+	return todo.DateModified.UTC().Format(time.RFC3339Nano)
+}
+
+// pull saves the calendar object in the database.
+func (s *syncer) pull(resource Resource) error {
+	body, etag, err := s.client.Get(resource.Href)
+
+	if err != nil {
+		return err
+	}
+
+	if etag == "" {
+		etag = resource.ETag
+	}
+
+	remotes, err := ical.Read(bytes.NewReader(body))
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", resource.Href, err)
+	}
+
+	// Events and the rest of objects that aren't tasks are skipped.
+	if len(remotes) == 0 {
+		return nil
+	}
+
+	remote := remotes[0]
+	item := s.items[resource.Href]
+	linked := false
+
+	if item == nil {
+		// A task exported before, or synced with another calendar, has the
+		// same uid.
+		for _, task := range s.tasks {
+			if remote.UID != "" && task.UID == remote.UID && s.byTodo[task.ID] == nil {
+				item = &db.SyncItem{TodoID: task.ID, RemoteID: resource.Href}
+				s.link(item)
+				linked = true
+				break
+			}
+		}
+	}
+
+	if item == nil {
+		id, err := s.add(remote)
+
+		if err != nil {
+			return err
+		}
+
+		s.link(&db.SyncItem{TodoID: id, RemoteID: resource.Href, ETag: etag})
+		return nil
+	}
+
+	item.ETag = etag
+	task, exists := s.tasks[item.TodoID]
+
+	switch {
+	case !exists:
+		// Deleted here and changed on the server.
+		s.report.Conflicts++
+
+		if s.policy == PreferDatabase {
+			if err := s.client.Delete(resource.Href, etag); err != nil && !errors.Is(err, ErrPrecondition) {
+				return err
+			}
+
+			s.unlink(item)
+			s.report.DeletedRemote++
+			return nil
+		}
+
+		s.unlink(item)
+
+		id, err := s.add(remote)
+
+		if err != nil {
+			return err
+		}
+
+		item.TodoID = id
+		s.link(item)
+		return nil
+	case s.changed(item):
+		if !linked {
+			s.report.Conflicts++
+		}
+
+		useRemote := s.policy == PreferServer || s.policy == PreferNewer && remote.DateModified.After(task.DateModified)
+
+		if !useRemote {
+			// The todo is pushed over the object with its new ETag.
+			return nil
+		}
+	}
+
+	task = apply(task, remote)
+
+	if err := s.store.UpdateTodo(task); err != nil {
+		return err
+	}
+
+	s.tasks[task.ID] = task
+	s.pulled[task.ID] = true
+	s.report.Pulled++
+
+	return nil
+}
+
+// add saves a task of the server that isn't in the database.
+func (s *syncer) add(remote db.Todo) (int, error) {
+	if remote.DateCreated.IsZero() {
+		remote.DateCreated = time.Now()
+	}
+
+	if remote.State.Closed() && !remote.DateCompleted.Valid {
+		remote.DateCompleted.Time, remote.DateCompleted.Valid = time.Now(), true
+	}
+
+	id, err := s.store.AddTodo(remote)
+
+	if err != nil {
+		return 0, err
+	}
+
+	remote.ID = id
+	s.tasks[id] = remote
+	s.pulled[id] = true
+	s.report.Pulled++
+
+	return id, nil
+}
+
+// apply returns the task with the fields of the calendar object.
+func apply(task, remote db.Todo) db.Todo {
+	task.Todo = remote.Todo
+	task.Tag = remote.Tag
+	task.State = remote.State
+	task.Priority = remote.Priority
+	task.DateDue = remote.DateDue
+	task.DateCompleted = remote.DateCompleted
+
+	if !remote.DateCreated.IsZero() {
+		task.DateCreated = remote.DateCreated
+	}
+
+	if task.State.Closed() && !task.DateCompleted.Valid {
+		task.DateCompleted.Time, task.DateCompleted.Valid = time.Now(), true
+	}
+
+	return task
+}
+
+// pullDeletion deletes the todo of a calendar object deleted on the server,
+// unless it changed here and the server doesn't win, then it is pushed again.
+func (s *syncer) pullDeletion(href string) error {
+	item := s.items[href]
+
+	if item == nil {
+		return nil
+	}
+
+	s.unlink(item)
+
+	if _, exists := s.tasks[item.TodoID]; !exists {
+		return nil
+	}
+
+	if s.changed(item) {
+		s.report.Conflicts++
+
+		if s.policy != PreferServer {
+			return nil
+		}
+	}
+
+	if err := s.store.DeleteTodo(item.TodoID); err != nil && !errors.Is(err, db.ErrNotFound) {
+		return err
+	}
+
+	delete(s.tasks, item.TodoID)
+	s.report.DeletedLocal++
+
+	return nil
+}
+
+// push saves on the server the todos that are new or changed in the database
+// and deletes the objects of the todos deleted.
+func (s *syncer) push() error {
+	ids := []int{}
+	for id := range s.tasks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		task := s.tasks[id]
+		item := s.byTodo[id]
+
+		if s.pulled[id] || item != nil && !s.changed(item) {
+			continue
+		}
+
+		var body bytes.Buffer
+
+		if err := ical.Write(&body, []db.Todo{task}); err != nil {
+			return err
+		}
+
+		if item == nil {
+			item = &db.SyncItem{TodoID: id, RemoteID: s.client.Href(task.UID)}
+		}
+
+		etag, err := s.client.Put(item.RemoteID, body.Bytes(), item.ETag)
+
+		if errors.Is(err, ErrPrecondition) {
+			s.report.Conflicts++
+			s.stale[id] = true
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		item.ETag = etag
+		s.link(item)
+		s.report.Pushed++
+	}
+
+	for _, item := range s.items {
+		if _, exists := s.tasks[item.TodoID]; exists {
+			continue
+		}
+
+		err := s.client.Delete(item.RemoteID, item.ETag)
+
+		if errors.Is(err, ErrPrecondition) {
+			// Changed on the server, the next sync decides.
+			s.report.Conflicts++
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s.unlink(item)
+		s.report.DeletedRemote++
+	}
+
+	return nil
+}
@@ -0,0 +1,672 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todo/db"
+	"todo/ical"
+)
+
+const collectionPath = "/cal/me/tasks/"
+
+// fakeServer is a CalDAV calendar collection that supports sync-collection
+// reports, their tokens are the revision of the collection.
+type fakeServer struct {
+	mu       sync.Mutex
+	objects  map[string]*object
+	deleted  map[string]int // revision of the deletion by path
+	rev      int
+	etags    int
+	noSync   bool
+	requests []string // method, path and sync token
+}
+
+type object struct {
+	body string
+	etag string
+	rev  int
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *Client) {
+	t.Helper()
+
+	f := &fakeServer{objects: map[string]*object{}, deleted: map[string]int{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL+"/cal/me", "tasks", "me", "pw")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f, client
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if user, password, ok := r.BasicAuth(); !ok || user != "me" || password != "pw" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	path := r.URL.Path
+
+	switch r.Method {
+	case "REPORT":
+		var report struct {
+			Token string `xml:"sync-token"`
+		}
+		xml.Unmarshal(body, &report)
+		f.requests = append(f.requests, "REPORT "+path+" "+report.Token)
+
+		if f.noSync {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		since := 0
+
+		if report.Token != "" {
+			if _, err := fmt.Sscanf(report.Token, "tok-%d", &since); err != nil {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+
+		for _, p := range f.paths() {
+			if o := f.objects[p]; o.rev > since {
+				fmt.Fprint(w, propstat(p, o.etag))
+			}
+		}
+
+		for p, rev := range f.deleted {
+			if since > 0 && rev > since {
+				fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`, p)
+			}
+		}
+
+		fmt.Fprintf(w, `<d:sync-token>tok-%d</d:sync-token></d:multistatus>`, f.rev)
+	case "PROPFIND":
+		f.requests = append(f.requests, "PROPFIND "+path)
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+
+		if path == collectionPath {
+			fmt.Fprint(w, propstat(collectionPath, ""))
+		}
+
+		for _, p := range f.paths() {
+			if path == collectionPath || path == p {
+				fmt.Fprint(w, propstat(p, f.objects[p].etag))
+			}
+		}
+
+		fmt.Fprint(w, `</d:multistatus>`)
+	case http.MethodGet:
+		f.requests = append(f.requests, "GET "+path)
+		o := f.objects[path]
+
+		if o == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("ETag", o.etag)
+		fmt.Fprint(w, o.body)
+	case http.MethodPut:
+		f.requests = append(f.requests, "PUT "+path)
+		o := f.objects[path]
+		ifMatch := r.Header.Get("If-Match")
+
+		if r.Header.Get("If-None-Match") == "*" && o != nil || ifMatch != "" && (o == nil || o.etag != ifMatch) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		w.Header().Set("ETag", f.save(path, string(body)))
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		f.requests = append(f.requests, "DELETE "+path)
+		o := f.objects[path]
+
+		if o == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != o.etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		f.remove(path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func propstat(href, etag string) string {
+	return fmt.Sprintf(`<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>%s</d:getetag></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, etag)
+}
+
+// paths returns the paths of the objects in order, so the answers don't
+// depend on the order of the map.
+func (f *fakeServer) paths() []string {
+	paths := []string{}
+	for p := range f.objects {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func (f *fakeServer) save(path, body string) string {
+	f.rev++
+	f.etags++
+	f.objects[path] = &object{body: body, etag: fmt.Sprintf(`"e%d"`, f.etags), rev: f.rev}
+	delete(f.deleted, path)
+
+	return f.objects[path].etag
+}
+
+func (f *fakeServer) remove(path string) {
+	f.rev++
+	delete(f.objects, path)
+	f.deleted[path] = f.rev
+}
+
+// put saves the todo as another client of the server would.
+func (f *fakeServer) put(t *testing.T, name string, todo db.Todo) {
+	t.Helper()
+
+	var body bytes.Buffer
+
+	if err := ical.Write(&body, []db.Todo{todo}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.save(collectionPath+name, body.String())
+}
+
+// delete deletes the object as another client of the server would.
+func (f *fakeServer) delete(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.remove(collectionPath + name)
+}
+
+// todos returns the todos of the objects of the collection by path.
+func (f *fakeServer) todos(t *testing.T) map[string]db.Todo {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	todos := map[string]db.Todo{}
+
+	for p, o := range f.objects {
+		read, err := ical.Read(strings.NewReader(o.body))
+
+		if err != nil || len(read) != 1 {
+			t.Fatalf("Not valid object %s: %v", p, err)
+		}
+
+		todos[p] = read[0]
+	}
+
+	return todos
+}
+
+// took returns the requests made since the last call.
+func (f *fakeServer) took() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	requests := f.requests
+	f.requests = nil
+
+	return requests
+}
+
+// fakeStore keeps the todos and the sync state in memory, every change
+// happens a second after the last one so the modification times differ.
+type fakeStore struct {
+	todos  map[int]db.Todo
+	nextID int
+	now    time.Time
+	items  map[string][]db.SyncItem
+	tokens map[string]string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		todos:  map[int]db.Todo{},
+		nextID: 1,
+		now:    time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		items:  map[string][]db.SyncItem{},
+		tokens: map[string]string{},
+	}
+}
+
+func (s *fakeStore) tick() time.Time {
+	s.now = s.now.Add(time.Second)
+	return s.now
+}
+
+func (s *fakeStore) GetTasksByFilter(filter db.Filter) ([]db.Todo, error) {
+	todos := []db.Todo{}
+	for _, todo := range s.todos {
+		todos = append(todos, todo)
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].ID < todos[j].ID
+	})
+
+	return todos, nil
+}
+
+func (s *fakeStore) AddTodo(todo db.Todo) (int, error) {
+	todo.ID = s.nextID
+	s.nextID++
+
+	if todo.UID == "" {
+		todo.UID = fmt.Sprintf("uid-%d", todo.ID)
+	}
+
+	todo.DateModified = s.tick()
+	s.todos[todo.ID] = todo
+
+	return todo.ID, nil
+}
+
+func (s *fakeStore) UpdateTodo(todo db.Todo) error {
+	if _, ok := s.todos[todo.ID]; !ok {
+		return db.ErrNotFound
+	}
+
+	todo.DateModified = s.tick()
+	s.todos[todo.ID] = todo
+
+	return nil
+}
+
+func (s *fakeStore) DeleteTodo(todoId int) error {
+	if _, ok := s.todos[todoId]; !ok {
+		return db.ErrNotFound
+	}
+
+	delete(s.todos, todoId)
+
+	return nil
+}
+
+func (s *fakeStore) SyncItems(source string) ([]db.SyncItem, error) {
+	return append([]db.SyncItem{}, s.items[source]...), nil
+}
+
+func (s *fakeStore) SaveSyncItems(source string, items []db.SyncItem) error {
+	s.items[source] = append([]db.SyncItem{}, items...)
+	return nil
+}
+
+func (s *fakeStore) SyncToken(source string) (string, error) {
+	return s.tokens[source], nil
+}
+
+func (s *fakeStore) SaveSyncToken(source, token string) error {
+	s.tokens[source] = token
+	return nil
+}
+
+// rename changes the title of the todo as the user would.
+func (s *fakeStore) rename(t *testing.T, id int, title string) {
+	t.Helper()
+
+	todo := s.todos[id]
+	todo.Todo = title
+
+	if err := s.UpdateTodo(todo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func remoteTodo(uid, title string, modified time.Time) db.Todo {
+	return db.Todo{UID: uid, Todo: title, State: db.Pending, DateCreated: modified, DateModified: modified}
+}
+
+func runSync(t *testing.T, store *fakeStore, client *Client, policy Policy) Report {
+	t.Helper()
+
+	report, err := Sync(store, client, policy)
+
+	if err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+
+	return report
+}
+
+// titles returns the titles of the todos of the store and of the server,
+// sorted.
+func titles(t *testing.T, store *fakeStore, server *fakeServer) ([]string, []string) {
+	t.Helper()
+
+	local, remote := []string{}, []string{}
+
+	for _, todo := range store.todos {
+		local = append(local, todo.Todo)
+	}
+
+	for _, todo := range server.todos(t) {
+		remote = append(remote, todo.Todo)
+	}
+
+	sort.Strings(local)
+	sort.Strings(remote)
+
+	return local, remote
+}
+
+func checkTitles(t *testing.T, store *fakeStore, server *fakeServer, want ...string) {
+	t.Helper()
+
+	local, remote := titles(t, store, server)
+
+	if strings.Join(local, "|") != strings.Join(want, "|") {
+		t.Errorf("titles in the database = %q, want %q", local, want)
+	}
+
+	if strings.Join(remote, "|") != strings.Join(want, "|") {
+		t.Errorf("titles on the server = %q, want %q", remote, want)
+	}
+}
+
+func TestSyncFirst(t *testing.T) {
+	server, client := newFakeServer(t)
+	store := newFakeStore()
+
+	server.put(t, "remote.ics", remoteTodo("remote", "Remote task", store.now))
+	store.AddTodo(db.Todo{Todo: "Local task", State: db.Pending, DateCreated: store.now})
+
+	report := runSync(t, store, client, PreferDatabase)
+
+	if want := (Report{Pulled: 1, Pushed: 1}); report != want {
+		t.Errorf("Sync() = %+v, want %+v", report, want)
+	}
+
+	checkTitles(t, store, server, "Local task", "Remote task")
+
+	if store.tokens["caldav:"+client.URL()] == "" {
+		t.Error("Sync() didn't save the sync token")
+	}
+
+	if items := store.items["caldav:"+client.URL()]; len(items) != 2 {
+		t.Errorf("Sync() saved %d sync items, want 2", len(items))
+	}
+
+	// Nothing changed, nothing is pulled nor pushed.
+	if report := runSync(t, store, client, PreferDatabase); report != (Report{}) {
+		t.Errorf("second Sync() = %+v, want nothing", report)
+	}
+}
+
+func TestSyncIncremental(t *testing.T) {
+	server, client := newFakeServer(t)
+	store := newFakeStore()
+
+	server.put(t, "a.ics", remoteTodo("a", "First", store.now))
+	server.put(t, "b.ics", remoteTodo("b", "Second", store.now))
+	runSync(t, store, client, PreferDatabase)
+	token := store.tokens["caldav:"+client.URL()]
+	server.took()
+
+	server.put(t, "a.ics", remoteTodo("a", "First edited", store.now.Add(time.Hour)))
+
+	report := runSync(t, store, client, PreferDatabase)
+
+	if want := (Report{Pulled: 1}); report != want {
+		t.Errorf("Sync() = %+v, want %+v", report, want)
+	}
+
+	requests := server.took()
+	want := []string{"REPORT " + collectionPath + " " + token, "GET " + collectionPath + "a.ics"}
+
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Sync() requests = %q, want %q", requests, want)
+	}
+
+	checkTitles(t, store, server, "First edited", "Second")
+}
+
+func TestSyncInvalidToken(t *testing.T) {
+	server, client := newFakeServer(t)
+	store := newFakeStore()
+
+	server.put(t, "a.ics", remoteTodo("a", "First", store.now))
+	runSync(t, store, client, PreferDatabase)
+
+	store.tokens["caldav:"+client.URL()] = "expired"
+	server.delete("a.ics")
+
+	report := runSync(t, store, client, PreferDatabase)
+
+	if want := (Report{DeletedLocal: 1}); report != want {
+		t.Errorf("Sync() = %+v, want %+v", report, want)
+	}
+
+	checkTitles(t, store, server)
+}
+
+func TestSyncWithoutSyncCollection(t *testing.T) {
+	server, client := newFakeServer(t)
+	server.noSync = true
+	store := newFakeStore()
+
+	server.put(t, "a.ics", remoteTodo("a", "First", store.now))
+	server.put(t, "b.ics", remoteTodo("b", "Second", store.now))
+	runSync(t, store, client, PreferDatabase)
+	checkTitles(t, store, server, "First", "Second")
+
+	server.delete("b.ics")
+
+	report := runSync(t, store, client, PreferDatabase)
+
+	if want := (Report{DeletedLocal: 1}); report != want {
+		t.Errorf("Sync() = %+v, want %+v", report, want)
+	}
+
+	checkTitles(t, store, server, "First")
+}
+
+func TestSyncRemoteDelete(t *testing.T) {
+	server, client := newFakeServer(t)
+	store := newFakeStore()
+
+	server.put(t, "a.ics", remoteTodo("a", "First", store.now))
+	server.put(t, "b.ics", remoteTodo("b", "Second", store.now))
+	runSync(t, store, client, PreferDatabase)
+
+	server.delete("a.ics")
+
+	report := runSync(t, store, client, PreferDatabase)
+
+	if want := (Report{DeletedLocal: 1}); report != want {
+		t.Errorf("Sync() = %+v, want %+v", report, want)
+	}
+
+	checkTitles(t, store, server, "Second")
+}
+
+func TestSyncLocalDelete(t *testing.T) {
+	server, client := newFakeServer(t)
+	store := newFakeStore()
+
+	first, _ := store.AddTodo(db.Todo{Todo: "First", State: db.Pending, DateCreated: store.now})
+	store.AddTodo(db.Todo{Todo: "Second", State: db.Pending, DateCreated: store.now})
+	runSync(t, store, client, PreferDatabase)
+
+	store.DeleteTodo(first)
+
+	report := runSync(t, store, client, PreferDatabase)
+
+	if want := (Report{DeletedRemote: 1}); report != want {
+		t.Errorf("Sync() = %+v, want %+v", report, want)
+	}
+
+	checkTitles(t, store, server, "Second")
+}
+
+func TestSyncBothChanged(t *testing.T) {
+	tests := []struct {
+		policy        Policy
+		remoteIsNewer bool
+		want          string
+	}{
+		{policy: PreferDatabase, remoteIsNewer: true, want: "Edited here"},
+		{policy: PreferServer, remoteIsNewer: false, want: "Edited on the server"},
+		{policy: PreferNewer, remoteIsNewer: true, want: "Edited on the server"},
+		{policy: PreferNewer, remoteIsNewer: false, want: "Edited here"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s remote newer %t", tt.policy, tt.remoteIsNewer), func(t *testing.T) {
+			server, client := newFakeServer(t)
+			store := newFakeStore()
+
+			server.put(t, "a.ics", remoteTodo("a", "Original", store.now))
+			runSync(t, store, client, tt.policy)
+
+			store.rename(t, 1, "Edited here")
+
+			modified := store.now.Add(-time.Minute)
+			if tt.remoteIsNewer {
+				modified = store.now.Add(time.Minute)
+			}
+
+			server.put(t, "a.ics", remoteTodo("a", "Edited on the server", modified))
+
+			report := runSync(t, store, client, tt.policy)
+
+			if report.Conflicts != 1 {
+				t.Errorf("Sync() conflicts = %d, want 1", report.Conflicts)
+			}
+
+			checkTitles(t, store, server, tt.want)
+
+			// Both sides agree, the next sync doesn't change anything.
+			if report := runSync(t, store, client, tt.policy); report != (Report{}) {
+				t.Errorf("next Sync() = %+v, want nothing", report)
+			}
+		})
+	}
+}
+
+func TestSyncDeletedHereChangedThere(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   []string
+	}{
+		{policy: PreferDatabase, want: nil},
+		{policy: PreferServer, want: []string{"Edited on the server"}},
+		{policy: PreferNewer, want: []string{"Edited on the server"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			server, client := newFakeServer(t)
+			store := newFakeStore()
+
+			server.put(t, "a.ics", remoteTodo("a", "Original", store.now))
+			runSync(t, store, client, tt.policy)
+
+			store.DeleteTodo(1)
+			server.put(t, "a.ics", remoteTodo("a", "Edited on the server", store.now.Add(time.Minute)))
+
+			report := runSync(t, store, client, tt.policy)
+
+			if report.Conflicts != 1 {
+				t.Errorf("Sync() conflicts = %d, want 1", report.Conflicts)
+			}
+
+			checkTitles(t, store, server, tt.want...)
+		})
+	}
+}
+
+func TestSyncChangedHereDeletedThere(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   []string
+	}{
+		{policy: PreferDatabase, want: []string{"Edited here"}},
+		{policy: PreferServer, want: nil},
+		{policy: PreferNewer, want: []string{"Edited here"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			server, client := newFakeServer(t)
+			store := newFakeStore()
+
+			server.put(t, "a.ics", remoteTodo("a", "Original", store.now))
+			runSync(t, store, client, tt.policy)
+
+			store.rename(t, 1, "Edited here")
+			server.delete("a.ics")
+
+			report := runSync(t, store, client, tt.policy)
+
+			if report.Conflicts != 1 {
+				t.Errorf("Sync() conflicts = %d, want 1", report.Conflicts)
+			}
+
+			checkTitles(t, store, server, tt.want...)
+		})
+	}
+}
+
+func TestPutPrecondition(t *testing.T) {
+	server, client := newFakeServer(t)
+	server.put(t, "a.ics", remoteTodo("a", "First", time.Now()))
+
+	href := client.Href("a")
+
+	if _, err := client.Put(href, []byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"), ""); err != ErrPrecondition {
+		t.Errorf("Put() of an object that exists error = %v, want ErrPrecondition", err)
+	}
+
+	if _, err := client.Put(href, []byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"), `"old"`); err != ErrPrecondition {
+		t.Errorf("Put() with an old ETag error = %v, want ErrPrecondition", err)
+	}
+
+	if err := client.Delete(href, `"old"`); err != ErrPrecondition {
+		t.Errorf("Delete() with an old ETag error = %v, want ErrPrecondition", err)
+	}
+
+	if err := client.Delete(client.Href("missing"), ""); err != nil {
+		t.Errorf("Delete() of a missing object error = %v, want nil", err)
+	}
+}
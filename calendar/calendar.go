@@ -0,0 +1,344 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"todo/dates"
+	"todo/db"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Store is the part of the database used by the calendar.
+type Store interface {
+	GetTasksByFilter(filter db.Filter) ([]db.Todo, error)
+}
+
+type keyMap struct {
+	Up        key.Binding
+	Down      key.Binding
+	Left      key.Binding
+	Right     key.Binding
+	PrevMonth key.Binding
+	NextMonth key.Binding
+	Today     key.Binding
+	Open      key.Binding
+	Help      key.Binding
+	Quit      key.Binding
+}
+
+// ShortHelp returns keybindings to be shown in the mini help view. It's part
+// of the key.Map interface.
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.PrevMonth, k.NextMonth, k.Open, k.Help, k.Quit}
+}
+
+// FullHelp returns keybindings for the expanded help view. It's part of the
+// key.Map interface.
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.Left, k.Right},     // first column
+		{k.PrevMonth, k.NextMonth, k.Today}, // second column
+		{k.Open, k.Help, k.Quit},            // third column
+	}
+}
+
+var keys = keyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "previous week"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "next week"),
+	),
+	Left: key.NewBinding(
+		key.WithKeys("left", "h"),
+		key.WithHelp("←/h", "previous day"),
+	),
+	Right: key.NewBinding(
+		key.WithKeys("right", "l"),
+		key.WithHelp("→/l", "next day"),
+	),
+	PrevMonth: key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "previous month"),
+	),
+	NextMonth: key.NewBinding(
+		key.WithKeys("]"),
+		key.WithHelp("]", "next month"),
+	),
+	Today: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "go to today"),
+	),
+	Open: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "list the tasks of the day"),
+	),
+	Help: key.NewBinding(
+		key.WithKeys("?"),
+		key.WithHelp("?", "toggle help"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q/esc/ctrl+c", "quit"),
+	),
+}
+
+const (
+	minCellWidth = 10
+	cellHeight   = 3
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().
+			Bold(true).
+			MarginBottom(1)
+
+	weekdayStyle = lipgloss.NewStyle().
+			Bold(true).
+			Padding(0, 1)
+
+	cellStyle = lipgloss.NewStyle().
+			Padding(0, 1)
+
+	selectedStyle = cellStyle.Copy().
+			Foreground(lipgloss.Color("229")).
+			Background(lipgloss.Color("57"))
+
+	todayStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("212"))
+
+	outsideStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196"))
+)
+
+var weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// counts are the tasks created, due and completed on a day.
+type counts struct {
+	created   int
+	due       int
+	completed int
+}
+
+// Model is a month grid where every day shows how many tasks were created, are
+// due and were completed on it. When it quits after pressing enter Opened is
+// true and Selected is the day chosen.
+type Model struct {
+	Selected time.Time
+	Opened   bool
+
+	store Store
+	tag   string
+	keys  keyMap
+	help  help.Model
+	days  map[time.Time]counts
+	month time.Time
+	width int
+	err   error
+}
+
+// New returns the calendar of the month of the selected day, showing only the
+// tasks with the tag when it isn't empty.
+func New(store Store, tag string, selected time.Time) Model {
+	m := Model{
+		Selected: dates.StartOfDay(selected),
+		store:    store,
+		tag:      tag,
+		keys:     keys,
+		help:     help.New(),
+	}
+
+	m.load()
+
+	return m
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.help.Width = msg.Width
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+		case key.Matches(msg, m.keys.Open):
+			m.Opened = true
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Up):
+			m.selectDay(m.Selected.AddDate(0, 0, -7))
+		case key.Matches(msg, m.keys.Down):
+			m.selectDay(m.Selected.AddDate(0, 0, 7))
+		case key.Matches(msg, m.keys.Left):
+			m.selectDay(m.Selected.AddDate(0, 0, -1))
+		case key.Matches(msg, m.keys.Right):
+			m.selectDay(m.Selected.AddDate(0, 0, 1))
+		case key.Matches(msg, m.keys.PrevMonth):
+			m.selectDay(addMonths(m.Selected, -1))
+		case key.Matches(msg, m.keys.NextMonth):
+			m.selectDay(addMonths(m.Selected, 1))
+		case key.Matches(msg, m.keys.Today):
+			m.selectDay(dates.StartOfDay(time.Now()))
+		}
+	}
+
+	return m, nil
+}
+
+// selectDay moves the selection to the day, loading the counts again when it
+// is in another month.
+func (m *Model) selectDay(day time.Time) {
+	m.Selected = day
+
+	if !dates.StartOfMonth(day).Equal(m.month) {
+		m.load()
+	}
+}
+
+// load counts the tasks created, due and completed on every day of the month
+// of the selected day.
+func (m *Model) load() {
+	m.month = dates.StartOfMonth(m.Selected)
+	m.days = map[time.Time]counts{}
+	m.err = nil
+
+	from, to := m.month, m.month.AddDate(0, 1, 0)
+
+	for _, period := range []struct {
+		filter db.Filter
+		date   func(db.Todo) time.Time
+		add    func(*counts)
+	}{
+		{
+			db.Filter{Tag: m.tag, CreatedFrom: from, CreatedTo: to},
+			func(t db.Todo) time.Time { return t.DateCreated },
+			func(c *counts) { c.created++ },
+		},
+		{
+			db.Filter{Tag: m.tag, DueFrom: from, DueTo: to},
+			func(t db.Todo) time.Time { return t.DateDue.Time },
+			func(c *counts) { c.due++ },
+		},
+		{
+			db.Filter{Tag: m.tag, CompletedFrom: from, CompletedTo: to},
+			func(t db.Todo) time.Time { return t.DateCompleted.Time },
+			func(c *counts) { c.completed++ },
+		},
+	} {
+		todos, err := m.store.GetTasksByFilter(period.filter)
+
+		if err != nil {
+			m.err = err
+			return
+		}
+
+		for _, todo := range todos {
+			day := dates.StartOfDay(period.date(todo).In(m.month.Location()))
+			c := m.days[day]
+			period.add(&c)
+			m.days[day] = c
+		}
+	}
+}
+
+func (m Model) View() string {
+	cellWidth := minCellWidth
+
+	if m.width > 0 {
+		cellWidth = max(m.width/7-2, minCellWidth)
+	}
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(m.month.Format("January 2006")) + "\n")
+
+	header := []string{}
+	for _, weekday := range weekdays {
+		header = append(header, weekdayStyle.Width(cellWidth+2).Render(weekday))
+	}
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, header...) + "\n")
+
+	today := dates.StartOfDay(time.Now())
+	start := dates.StartOfWeek(m.month)
+	end := m.month.AddDate(0, 1, 0)
+
+	for week := start; week.Before(end); week = week.AddDate(0, 0, 7) {
+		cells := []string{}
+
+		for day := week; day.Before(week.AddDate(0, 0, 7)); day = day.AddDate(0, 0, 1) {
+			cells = append(cells, m.cellView(day, today, cellWidth))
+		}
+
+		b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, cells...) + "\n")
+	}
+
+	b.WriteString(outsideStyle.Render("+ created  ! due  ✓ completed") + "\n")
+
+	if m.err != nil {
+		b.WriteString(errorStyle.Render(m.err.Error()) + "\n")
+	}
+
+	return b.String() + "\n" + m.help.View(m.keys)
+}
+
+func (m Model) cellView(day, today time.Time, width int) string {
+	number := fmt.Sprint(day.Day())
+	lines := []string{number}
+
+	if c := m.days[day]; c != (counts{}) {
+		summary := []string{}
+
+		if c.created > 0 {
+			summary = append(summary, fmt.Sprintf("+%d", c.created))
+		}
+
+		if c.due > 0 {
+			summary = append(summary, fmt.Sprintf("!%d", c.due))
+		}
+
+		if c.completed > 0 {
+			summary = append(summary, fmt.Sprintf("✓%d", c.completed))
+		}
+
+		lines = append(lines, strings.Join(summary, " "))
+	}
+
+	style := cellStyle
+
+	switch {
+	case day.Equal(m.Selected):
+		style = selectedStyle
+	case !dates.StartOfMonth(day).Equal(m.month):
+		style = style.Copy().Inherit(outsideStyle)
+	case day.Equal(today):
+		style = style.Copy().Inherit(todayStyle)
+	}
+
+	return style.Width(width + 2).Height(cellHeight).Render(strings.Join(lines, "\n"))
+}
+
+// addMonths moves the day the number of months, keeping the day of the month
+// unless the new month is shorter.
+func addMonths(day time.Time, months int) time.Time {
+	first := dates.StartOfMonth(day).AddDate(0, months, 0)
+	last := first.AddDate(0, 1, -1).Day()
+
+	return first.AddDate(0, 0, min(day.Day(), last)-1)
+}
@@ -1,14 +1,37 @@
 package main
 
 import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
+	"todo/activity"
 	"todo/add"
+	"todo/agenda"
+	"todo/board"
+	"todo/caldav"
+	"todo/calendar"
+	"todo/dates"
 	"todo/db"
+	"todo/export"
+	"todo/importer"
 	list_actionable "todo/list-actionable"
 	list_table "todo/list-table"
+	"todo/markdown"
+	"todo/output"
+	"todo/review"
+	"todo/scan"
+	"todo/standup"
+	"todo/stats"
+	todo_table "todo/todo-table"
+	"todo/todotxt"
+	"todo/ui"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
@@ -17,7 +40,15 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "todo",
 	Short: "todo is a simple cli utility to manage task in progress",
-	Args:  cobra.NoArgs,
+	Long: `todo is a simple cli utility to manage task in progress.
+
+It exits with 0 on success, 1 on any other error, 2 when the flags can't be
+parsed, 3 when the task doesn't exist, 4 when the task state doesn't allow the
+action and 5 when the database can't be read or written.`,
+	Args: cobra.NoArgs,
+	// The errors are printed to stderr by main, which also sets the exit code.
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 var addCmd = &cobra.Command{
@@ -26,6 +57,11 @@ var addCmd = &cobra.Command{
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
 		defer todoDB.Close()
 
 		tag, err := cmd.Flags().GetString("tag")
@@ -34,6 +70,22 @@ var addCmd = &cobra.Command{
 			return errors.New("Not valid tag")
 		}
 
+		dueString, err := cmd.Flags().GetString("due")
+
+		if err != nil {
+			return errors.New("Not valid due date")
+		}
+
+		todo := db.Todo{State: db.Pending, Tag: tag, DateCreated: time.Now()}
+
+		if dueString != "" {
+			todo.DateDue, err = parseDueDate(dueString)
+
+			if err != nil {
+				return err
+			}
+		}
+
 		if len(args) == 0 {
 			p := tea.NewProgram(add.AddInputModel())
 			m, err := p.Run()
@@ -42,7 +94,8 @@ var addCmd = &cobra.Command{
 					return errors.New("Cannot add empty task")
 				}
 
-				err = todoDB.CreateTodo(task.Value, tag)
+				todo.Todo = task.Value
+				_, err = todoDB.AddTodo(todo)
 
 				if err != nil {
 					return err
@@ -53,17 +106,14 @@ var addCmd = &cobra.Command{
 			return err
 		}
 
-		if err != nil {
-			return errors.New("Database initialization failed")
-		}
-
 		task := args[0]
 
 		if task == "" {
 			return errors.New("Cannot add empty task")
 		}
 
-		err = todoDB.CreateTodo(task, tag)
+		todo.Todo = task
+		_, err = todoDB.AddTodo(todo)
 
 		if err != nil {
 			return err
@@ -75,84 +125,77 @@ var addCmd = &cobra.Command{
 	},
 }
 
-var listCmd = &cobra.Command{
-	Use:   "list [command]",
-	Short: "list your tasks, it will list only your pending tasks",
-	Long: `By default it list your pending tasks, the same as "todo list pending", but if you want to see your completed task you can use "todo list done", or see all your task with "todo list all".
-	`,
-	Args: cobra.NoArgs,
+var dueCmd = &cobra.Command{
+	Use:   "due <id> <date>",
+	Short: "set the date a task is due",
+	Long:  `set the date a task is due, "todo due 4 2024-05-31" or "todo due 4 tomorrow", and remove it with "todo due 4 none"`,
+	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		todoDB, err := db.NewTodoDB()
-		defer todoDB.Close()
-
-		if err != nil {
-			return err
-		}
-
-		dateString, err := cmd.Flags().GetString("date")
-
-		if err != nil {
-			return errors.New("Not valid date")
-		}
-
-		tag, err := cmd.Flags().GetString("tag")
+		id, err := strconv.Atoi(args[0])
 
 		if err != nil {
-			return errors.New("Not valid tag")
+			return fmt.Errorf("Not valid id %q", args[0])
 		}
 
-		var todos []db.Todo
-
-		if dateString == "" {
-			todos, err = todoDB.GetFilteredTasksByState(db.Pending, tag)
-		} else {
-			if dateString == "today" {
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Pending, time.Now(), tag)
-			} else if dateString == "yesterday" {
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Pending, time.Now().Add(-24*time.Hour), tag)
-			} else {
-				date, err := time.Parse("2006-01-02", dateString)
+		due := sql.NullTime{}
 
-				if err != nil {
-					return errors.New("Not valid date")
-				}
+		if args[1] != "none" {
+			due, err = parseDueDate(args[1])
 
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Pending, date, tag)
+			if err != nil {
+				return err
 			}
 		}
 
+		todoDB, err := db.NewTodoDB()
+
 		if err != nil {
 			return err
 		}
 
-		m := list_table.NewTodoTable(todos)
-		p := tea.NewProgram(m)
-		_, err = p.Run()
+		defer todoDB.Close()
 
-		if err != nil {
-			return err
+		if err := todoDB.SetDueDate(id, due); err != nil {
+			return fmt.Errorf("todo with id %d couldn't be changed: %w", id, err)
+		}
+
+		if due.Valid {
+			fmt.Printf("task with the id %d is due on %s.\n", id, due.Time.Format("2006-01-02"))
+		} else {
+			fmt.Printf("task with the id %d has no due date.\n", id)
 		}
 
 		return nil
 	},
 }
 
-var listAllCmd = &cobra.Command{
-	Use:   "all",
-	Short: "list all your tasks",
-	Args:  cobra.NoArgs,
+func parseDueDate(s string) (sql.NullTime, error) {
+	due, err := dates.ParseDay(s, time.Now())
+
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+
+	return sql.NullTime{Time: due, Valid: true}, nil
+}
+
+var agendaCmd = &cobra.Command{
+	Use:   "agenda",
+	Short: "show your tasks by day",
+	Long: `show the tasks of today under their due date, and the ones finished today under their completion date, with the overdue tasks first.
+Use --week or --month to see the rest of the week or the month.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		todoDB, err := db.NewTodoDB()
-		defer todoDB.Close()
+		week, err := cmd.Flags().GetBool("week")
 
 		if err != nil {
-			return err
+			return errors.New("Not valid week")
 		}
 
-		dateString, err := cmd.Flags().GetString("date")
+		month, err := cmd.Flags().GetBool("month")
 
 		if err != nil {
-			return errors.New("Not valid date")
+			return errors.New("Not valid month")
 		}
 
 		tag, err := cmd.Flags().GetString("tag")
@@ -161,39 +204,70 @@ var listAllCmd = &cobra.Command{
 			return errors.New("Not valid tag")
 		}
 
-		var todos []db.Todo
+		now := time.Now()
+		from := dates.StartOfDay(now)
+		to := from.AddDate(0, 0, 1)
+
+		switch {
+		case month:
+			from = dates.StartOfMonth(now)
+			to = from.AddDate(0, 1, 0)
+		case week:
+			from = dates.StartOfWeek(now)
+			to = from.AddDate(0, 0, 7)
+		}
 
-		if dateString == "" {
-			todos, err = todoDB.GetTasks(tag)
-		} else {
-			if dateString == "today" {
-				todos, err = todoDB.GetFilteredTasksByCreationDate(time.Now(), tag)
-			} else if dateString == "yesterday" {
-				todos, err = todoDB.GetFilteredTasksByCreationDate(time.Now().Add(-24*time.Hour), tag)
-			} else {
-				date, err := time.Parse("2006-01-02", dateString)
+		todoDB, err := db.NewTodoDB()
 
-				if err != nil {
-					return errors.New("Not valid date")
-				}
+		if err != nil {
+			return err
+		}
 
-				todos, err = todoDB.GetFilteredTasksByCreationDate(date, tag)
-			}
+		defer todoDB.Close()
+
+		todos, err := todoDB.GetTasksByFilter(db.Filter{Tag: tag})
+
+		if err != nil {
+			return err
 		}
 
+		sections := agenda.Build(todos, from, to, now)
+
+		fmt.Print(agenda.Render(sections, output.TerminalWidth()))
+
+		return nil
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list [command]",
+	Short: "list your tasks, it will list only your pending tasks",
+	Long: `By default it list your pending tasks, the same as "todo list pending", but if you want to see your completed task you can use "todo list done", or see all your task with "todo list all".
+	`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todos, err := listTodos(cmd, db.Filter{Open: true})
+
 		if err != nil {
 			return err
 		}
 
-		m := list_table.NewTodoTable(todos)
-		p := tea.NewProgram(m)
-		_, err = p.Run()
+		return showTodos(cmd, todos)
+	},
+}
+
+var listAllCmd = &cobra.Command{
+	Use:   "all",
+	Short: "list all your tasks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todos, err := listTodos(cmd, db.Filter{})
 
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return showTodos(cmd, todos)
 	},
 }
 
@@ -202,319 +276,1461 @@ var listDoneTasksCmd = &cobra.Command{
 	Short: "list done tasks",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		todoDB, err := db.NewTodoDB()
-		defer todoDB.Close()
+		todos, err := listTodos(cmd, db.Filter{States: []db.Status{db.Done}})
 
 		if err != nil {
 			return err
 		}
 
-		dateString, err := cmd.Flags().GetString("date")
+		return showTodos(cmd, todos)
+	},
+}
+
+var listPendingTasksCmd = &cobra.Command{
+	Use:   "pending",
+	Short: "list pending tasks, the ones in any state of the workflow but done and cancelled",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todos, err := listTodos(cmd, db.Filter{Open: true})
 
 		if err != nil {
-			return errors.New("Not valid date")
+			return err
 		}
 
-		tag, err := cmd.Flags().GetString("tag")
+		return showTodos(cmd, todos)
+	},
+}
 
-		if err != nil {
-			return errors.New("Not valid tag")
-		}
+// listTodos returns the tasks matching the filter, created on the day of the
+// --date flag and with the tag of the --tag flag.
+func listTodos(cmd *cobra.Command, filter db.Filter) ([]db.Todo, error) {
+	todoDB, err := db.NewTodoDB()
 
-		var todos []db.Todo
+	if err != nil {
+		return nil, err
+	}
 
-		if dateString == "" {
-			todos, err = todoDB.GetFilteredTasksByState(db.Done, tag)
-		} else {
-			if dateString == "today" {
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Done, time.Now(), tag)
-			} else if dateString == "yesterday" {
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Done, time.Now().Add(-24*time.Hour), tag)
-			} else {
-				date, err := time.Parse("2006-01-02", dateString)
+	defer todoDB.Close()
 
-				if err != nil {
-					return errors.New("Not valid date")
-				}
+	dateString, err := cmd.Flags().GetString("date")
 
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Done, date, tag)
-			}
-		}
+	if err != nil {
+		return nil, errors.New("Not valid date")
+	}
 
-		if err != nil {
-			return err
-		}
+	tag, err := cmd.Flags().GetString("tag")
 
-		m := list_table.NewTodoTable(todos)
-		p := tea.NewProgram(m)
-		_, err = p.Run()
+	if err != nil {
+		return nil, errors.New("Not valid tag")
+	}
+
+	filter.Tag = tag
+
+	if dateString != "" {
+		date, err := dates.ParseDay(dateString, time.Now())
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		return nil
-	},
+		filter.CreatedFrom = date
+		filter.CreatedTo = date.AddDate(0, 0, 1)
+	}
+
+	return todoDB.GetTasksByFilter(filter)
 }
 
-var listPendingTasksCmd = &cobra.Command{
-	Use:   "pending",
-	Short: "list pending tasks",
-	Args:  cobra.NoArgs,
+var markAsDoneCmd = &cobra.Command{
+	Use:   "done [id or range...]",
+	Short: "mark the tasks with the ids passed as done",
+	Long: `mark the tasks as done, "todo done 1" will mark the task with the id 1 as done, "todo done 3 5 8-12" will mark the tasks 3, 5 and from 8 to 12 as done and "todo done --tag sprint-14 --created last-week" the pending tasks tagged sprint-14 created last week.
+Without ids nor filters the tasks can be chosen interactively.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		todoDB, err := db.NewTodoDB()
-		defer todoDB.Close()
 
 		if err != nil {
 			return err
 		}
 
-		dateString, err := cmd.Flags().GetString("date")
+		defer todoDB.Close()
 
-		if err != nil {
-			return errors.New("Not valid date")
-		}
+		return runBulk(cmd, args, bulkAction{
+			description: "marked as done",
+			skipped:     "was already done",
+			filter:      db.Filter{Open: true},
+			skip:        inState(db.Done),
+			getTasks:    todoDB.GetTasksByFilter,
+			apply:       todoDB.CompleteTodos,
+		})
+	},
+}
 
-		tag, err := cmd.Flags().GetString("tag")
+var markAsNotDoneCmd = &cobra.Command{
+	Use:   "pending [id or range...]",
+	Short: "mark the tasks with the ids passed as pending",
+	Long: `mark the tasks as pending, "todo pending 1" will mark the task with the id 1 as pending, "todo pending 3 5 8-12" will mark the tasks 3, 5 and from 8 to 12 as pending and "todo pending --tag sprint-14" the done or cancelled tasks tagged sprint-14.
+Without ids nor filters the tasks can be chosen interactively.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todoDB, err := db.NewTodoDB()
 
 		if err != nil {
-			return errors.New("Not valid tag")
+			return err
 		}
 
-		var todos []db.Todo
-
-		if dateString == "" {
-			todos, err = todoDB.GetFilteredTasksByState(db.Pending, tag)
-		} else {
-			if dateString == "today" {
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Pending, time.Now(), tag)
-			} else if dateString == "yesterday" {
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Pending, time.Now().Add(-24*time.Hour), tag)
-			} else {
-				date, err := time.Parse("2006-01-02", dateString)
+		defer todoDB.Close()
 
-				if err != nil {
-					return errors.New("Not valid date")
-				}
+		return runBulk(cmd, args, bulkAction{
+			description: "marked as pending",
+			skipped:     "was already pending",
+			filter:      db.Filter{Closed: true},
+			skip:        inState(db.Pending),
+			getTasks:    todoDB.GetTasksByFilter,
+			apply:       todoDB.UncompleteTodos,
+		})
+	},
+}
 
-				todos, err = todoDB.GetFilteredTasksByStateAndDate(db.Pending, date, tag)
-			}
-		}
+var deleteTodoCmd = &cobra.Command{
+	Use:   "delete [id or range...]",
+	Short: "delete the tasks with the ids passed",
+	Long: `delete the tasks, "todo delete 1" will delete the task with the id 1, "todo delete 3 5 8-12" will delete the tasks 3, 5 and from 8 to 12 and "todo delete --tag sprint-14" the tasks tagged sprint-14.
+Without ids nor filters the tasks can be chosen interactively.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todoDB, err := db.NewTodoDB()
 
 		if err != nil {
 			return err
 		}
 
-		m := list_table.NewTodoTable(todos)
-		p := tea.NewProgram(m)
-		_, err = p.Run()
-
-		if err != nil {
-			return err
-		}
+		defer todoDB.Close()
 
-		return nil
+		return runBulk(cmd, args, bulkAction{
+			description: "deleted",
+			getTasks:    todoDB.GetTasksByFilter,
+			apply:       todoDB.DeleteTodos,
+		})
 	},
 }
 
-var markAsDoneCmd = &cobra.Command{
-	Use:   "done",
-	Short: "mark the task with the id passed as done",
-	Long:  `mark the task as done, "todo done 1" will mark the task with the id 1 as done`,
-	Args:  cobra.MaximumNArgs(1),
+var calendarCmd = &cobra.Command{
+	Use:   "calendar",
+	Short: "show a calendar with the tasks of every day",
+	Long: `show a calendar of the month where every day shows how many tasks were created, are due and were completed on it.
+Pressing enter lists the tasks of the selected day, quitting the list goes back to the calendar.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		tag, err := cmd.Flags().GetString("tag")
+
+		if err != nil {
+			return errors.New("Not valid tag")
+		}
+
 		todoDB, err := db.NewTodoDB()
-		defer todoDB.Close()
 
 		if err != nil {
 			return err
 		}
 
-		if len(args) == 0 {
-			todos, err := todoDB.GetFilteredTasksByState(db.Pending, "")
+		defer todoDB.Close()
 
-			if err != nil {
-				return nil
-			}
+		selected := time.Now()
 
-			m := list_actionable.NewTodoTable(todos)
-			p := tea.NewProgram(m)
+		for {
+			p := tea.NewProgram(calendar.New(todoDB, tag, selected), tea.WithAltScreen())
 			model, err := p.Run()
 
 			if err != nil {
 				return err
 			}
 
-			if task, ok := model.(list_actionable.Model); ok {
-				id, err := strconv.Atoi(task.SelectedId)
+			month, ok := model.(calendar.Model)
 
-				if err != nil {
-					return err
-				}
+			if !ok || !month.Opened {
+				return nil
+			}
 
-				err = todoDB.CompleteTodo(id)
+			selected = month.Selected
 
-				if err != nil {
-					return errors.New(fmt.Sprintf("todo with id %d couldn't be marked as done", id))
-				}
+			todos, err := tasksOfDay(todoDB.GetTasksByFilter, tag, selected)
 
-				fmt.Printf("task with the id %d marked as done.\n", id)
+			if err != nil {
+				return err
+			}
 
-				return nil
+			p = tea.NewProgram(list_table.NewTodoTable(todos, todo_table.WithColumns(dayColumns)), tea.WithAltScreen())
+
+			if _, err := p.Run(); err != nil {
+				return err
 			}
 		}
+	},
+}
 
-		id, err := strconv.Atoi(args[0])
+// dayColumns are the columns of the list of the tasks of a day, with the three
+// dates shown in the calendar.
+var dayColumns = []todo_table.Column{
+	todo_table.IDColumn,
+	todo_table.TitleColumn,
+	todo_table.TagColumn,
+	todo_table.StateColumn,
+	todo_table.CreatedColumn,
+	todo_table.CompletedColumn,
+	todo_table.DueColumn,
+}
 
-		if err != nil {
-			return errors.New("Not a valid task id")
-		}
+// tasksOfDay returns the tasks created, due or completed on the day.
+func tasksOfDay(getTasks func(db.Filter) ([]db.Todo, error), tag string, day time.Time) ([]db.Todo, error) {
+	from, to := dates.StartOfDay(day), dates.StartOfDay(day).AddDate(0, 0, 1)
+	seen := map[int]bool{}
+	todos := []db.Todo{}
 
-		err = todoDB.CompleteTodo(id)
+	for _, filter := range []db.Filter{
+		{Tag: tag, CreatedFrom: from, CreatedTo: to},
+		{Tag: tag, DueFrom: from, DueTo: to},
+		{Tag: tag, CompletedFrom: from, CompletedTo: to},
+	} {
+		found, err := getTasks(filter)
 
 		if err != nil {
-			return errors.New(fmt.Sprintf("todo with id %d couldn't be marked as done", id))
+			return nil, err
 		}
 
-		fmt.Printf("task with the id %d marked as done.\n", id)
+		for _, todo := range found {
+			if !seen[todo.ID] {
+				seen[todo.ID] = true
+				todos = append(todos, todo)
+			}
+		}
+	}
 
-		return nil
-	},
+	todo_table.SortTodos(todos, todo_table.Order{})
+
+	return todos, nil
 }
 
-var markAsNotDoneCmd = &cobra.Command{
-	Use:   "pending",
-	Short: "mark the task with the id passed as pending",
-	Long:  `mark the task as pending, "todo pending 1" will mark the task with the id 1 as pending`,
-	Args:  cobra.MaximumNArgs(1),
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "show statistics of your tasks",
+	Long: `show how many tasks were completed, how long they took, how many are completed per day and week, the busiest weekday, the oldest pending tasks and the same numbers for every tag.
+By default all the tasks are used, "todo stats --since 30d" uses only the ones created in the last 30 days.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		todoDB, err := db.NewTodoDB()
-		defer todoDB.Close()
+		tag, err := cmd.Flags().GetString("tag")
 
 		if err != nil {
-			return err
+			return errors.New("Not valid tag")
 		}
 
-		if len(args) == 0 {
-			todos, err := todoDB.GetFilteredTasksByState(db.Done, "")
-
-			if err != nil {
-				return err
-			}
-
-			m := list_actionable.NewTodoTable(todos)
-			p := tea.NewProgram(m)
-			model, err := p.Run()
-
-			if err != nil {
-				return err
-			}
+		since, err := cmd.Flags().GetString("since")
 
-			if task, ok := model.(list_actionable.Model); ok {
-				id, err := strconv.Atoi(task.SelectedId)
+		if err != nil {
+			return errors.New("Not valid period")
+		}
 
-				if err != nil {
-					return nil
-				}
+		asJSON, err := cmd.Flags().GetBool("json")
 
-				err = todoDB.UncompleteTodo(id)
+		if err != nil {
+			return errors.New("Not valid json value")
+		}
 
-				if err != nil {
-					return errors.New(fmt.Sprintf("todo with id %d couldn't be marked as pending", id))
-				}
+		now := time.Now()
+		filter := db.Filter{Tag: tag}
 
-				fmt.Printf("task with the id %d marked as pending.\n", id)
+		if since != "" {
+			filter.CreatedFrom, filter.CreatedTo, err = dates.ParsePeriod(since, now)
 
-				return nil
+			if err != nil {
+				return err
 			}
 		}
 
-		id, err := strconv.Atoi(args[0])
+		todoDB, err := db.NewTodoDB()
 
 		if err != nil {
-			return errors.New("Not a valid task id")
+			return err
 		}
 
-		err = todoDB.UncompleteTodo(id)
+		defer todoDB.Close()
+
+		todos, err := todoDB.GetTasksByFilter(filter)
 
 		if err != nil {
-			return errors.New(fmt.Sprintf("todo with id %d couldn't be marked as pending", id))
+			return err
 		}
 
-		fmt.Printf("task with the id %d marked as pending.\n", id)
+		from, to := filter.CreatedFrom, filter.CreatedTo
 
-		return nil
-	},
+		if since == "" {
+			from, to = stats.Since(todos, now), dates.StartOfDay(now).AddDate(0, 0, 1)
+		}
+
+		report := stats.Compute(todos, from, to)
+
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+
+			return encoder.Encode(report)
+		}
+
+		fmt.Print(stats.Render(report))
+
+		return nil
+	},
+}
+
+var activityCmd = &cobra.Command{
+	Use:   "activity",
+	Short: "show a heatmap of the tasks completed in the last year",
+	Long: `show a heatmap with the tasks completed every day of the last year, the current and the longest streaks and a sparkline with the tasks completed every week for every tag.
+The heatmap only uses ASCII characters and no colors with --ascii or when the output isn't a terminal.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tag, err := cmd.Flags().GetString("tag")
+
+		if err != nil {
+			return errors.New("Not valid tag")
+		}
+
+		ascii, err := cmd.Flags().GetBool("ascii")
+
+		if err != nil {
+			return errors.New("Not valid ascii value")
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		now := time.Now()
+		todos, err := todoDB.GetTasksByFilter(db.Filter{Tag: tag, CompletedFrom: activity.From(now)})
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(activity.Render(activity.Compute(todos, now), ascii || !output.IsTerminal()))
+
+		return nil
+	},
+}
+
+var standupCmd = &cobra.Command{
+	Use:   "standup",
+	Short: "write the report of the daily standup",
+	Long: `write the tasks done since the last standup, the ones planned for today and the blocked ones as Markdown or, with --output slack, as text ready to paste in Slack.
+By default the done tasks are the ones completed since the last working day, so on Monday it covers Friday, use --since to choose another day.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tag, err := cmd.Flags().GetString("tag")
+
+		if err != nil {
+			return errors.New("Not valid tag")
+		}
+
+		sinceString, err := cmd.Flags().GetString("since")
+
+		if err != nil {
+			return errors.New("Not valid date")
+		}
+
+		outputString, err := cmd.Flags().GetString("output")
+
+		if err != nil {
+			return errors.New("Not valid output")
+		}
+
+		format, err := standup.ParseFormat(outputString)
+
+		if err != nil {
+			return err
+		}
+
+		since, err := standup.ParseSince(sinceString, time.Now())
+
+		if err != nil {
+			return err
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		todos := []db.Todo{}
+
+		for _, filter := range []db.Filter{
+			{Tag: tag, CompletedFrom: since},
+			{Tag: tag, Open: true},
+		} {
+			found, err := todoDB.GetTasksByFilter(filter)
+
+			if err != nil {
+				return err
+			}
+
+			todos = append(todos, found...)
+		}
+
+		return standup.Write(os.Stdout, format, standup.Build(todos, since))
+	},
+}
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "review your pending tasks one by one",
+	Long: `walk through every pending task, first the ones never reviewed and then the ones reviewed longest ago, and keep, complete, retag, defer or delete each of them.
+Every action is saved right away and records when the task was reviewed, skipped tasks come first the next time.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tag, err := cmd.Flags().GetString("tag")
+
+		if err != nil {
+			return errors.New("Not valid tag")
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		todos, err := todoDB.GetTasksByFilter(db.Filter{Tag: tag, Open: true})
+
+		if err != nil {
+			return err
+		}
+
+		review.Queue(todos)
+
+		p := tea.NewProgram(review.New(todoDB, todos), tea.WithAltScreen())
+		_, err = p.Run()
+
+		return err
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export your tasks to json, csv, markdown, todo.txt, iCalendar, Taskwarrior or Org",
+	Long: `export the tasks with all their fields, "todo export --format csv -o todos.csv" writes all of them to a file and "todo export --format markdown --tag sprint-14 --state todo,in-progress" prints a checklist of the open tasks tagged sprint-14.
+The json export is an array of objects with the fields id, todo, state, tag, date_created, date_completed, date_due, date_reviewed, priority and uid, the csv one has the same columns. The dates use RFC 3339 and are null in json, or empty in csv, when the task doesn't have them.
+The todotxt export has a todo.txt line for every task with its priority, creation date, the tag as a +project and the closed tasks marked with x and their completion date, the due date and the states todo.txt doesn't have are kept as due: and state: keys.
+The ics export is an iCalendar with a VTODO for every task that calendar apps can import, its UID is the uid of the task so importing it again doesn't duplicate anything.
+The taskwarrior export is read by "task import", the tag is the project and the uid the uuid of the task.
+The org export has a headline for every task with its state as the keyword and its uid in the ID property, so importing it again doesn't duplicate anything.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		formatString, err := cmd.Flags().GetString("format")
+
+		if err != nil {
+			return errors.New("Not valid format")
+		}
+
+		format, err := export.ParseFormat(formatString)
+
+		if err != nil {
+			return err
+		}
+
+		file, err := cmd.Flags().GetString("output")
+
+		if err != nil {
+			return errors.New("Not valid output file")
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		// After opening the database, which loads the states --state can have.
+		filter, err := exportFilter(cmd)
+
+		if err != nil {
+			return err
+		}
+
+		todos, err := todoDB.GetTasksByFilter(filter)
+
+		if err != nil {
+			return err
+		}
+
+		if file == "" {
+			return export.Write(os.Stdout, format, todos)
+		}
+
+		f, err := os.Create(file)
+
+		if err != nil {
+			return err
+		}
+
+		if err := export.Write(f, format, todos); err != nil {
+			f.Close()
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "%d tasks exported to %s.\n", len(todos), file)
+
+		return nil
+	},
+}
+
+// exportFilter returns the filter of the --tag, --state, --created and
+// --completed flags of the export.
+func exportFilter(cmd *cobra.Command) (db.Filter, error) {
+	var filter db.Filter
+	var err error
+
+	filter.Tag, err = cmd.Flags().GetString("tag")
+
+	if err != nil {
+		return filter, errors.New("Not valid tag")
+	}
+
+	states, err := cmd.Flags().GetStringSlice("state")
+
+	if err != nil {
+		return filter, errors.New("Not valid state")
+	}
+
+	for _, name := range states {
+		state, err := db.ParseStatus(name)
+
+		if err != nil {
+			return filter, err
+		}
+
+		filter.States = append(filter.States, state)
+	}
+
+	for _, period := range []struct {
+		flag     string
+		from, to *time.Time
+	}{
+		{"created", &filter.CreatedFrom, &filter.CreatedTo},
+		{"completed", &filter.CompletedFrom, &filter.CompletedTo},
+	} {
+		value, err := cmd.Flags().GetString(period.flag)
+
+		if err != nil {
+			return filter, errors.New("Not valid period")
+		}
+
+		if value == "" {
+			continue
+		}
+
+		*period.from, *period.to, err = dates.ParsePeriod(value, time.Now())
+
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "import tasks from a json, csv, todo.txt, iCalendar, Taskwarrior or Org file",
+	Long: `import the tasks of a file made with "todo export", keeping their state and dates. The format is taken from the extension of the file unless --format is used.
+Other csv files can be imported mapping their columns to the fields of the tasks, "todo import tasks.csv --map todo=Title --map tag=Project --map date_created=Created".
+A todo.txt file takes the tag from the first +project, or from the first @context when there is none, the (A) priority, the x completion marker and the dates of every line.
+An iCalendar file imports its VTODO components with their SUMMARY, STATUS, dates, first category as the tag and UID.
+A Taskwarrior export, "task export > tasks.json" and "todo import --format taskwarrior tasks.json", keeps the description, status, dates, priority and uuid of the tasks, the tag is the project or the first tag without one. The attributes that can't be imported, like the annotations, are reported.
+An Org file imports its TODO and DONE headlines, or the keywords of its #+TODO lines, with their priority, first tag, CLOSED date as the completion date, DEADLINE as the due date and ID property as the uid.
+The tasks with the same uid, or the same title, tag and creation day, as an existing task are skipped, "todo import todos.json --dry-run" shows what would be imported without changing anything.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		formatString, err := cmd.Flags().GetString("format")
+
+		if err != nil {
+			return errors.New("Not valid format")
+		}
+
+		format, err := importer.DetectFormat(args[0], formatString)
+
+		if err != nil {
+			return err
+		}
+
+		maps, err := cmd.Flags().GetStringArray("map")
+
+		if err != nil {
+			return errors.New("Not valid map")
+		}
+
+		mapping, err := importer.ParseMapping(maps)
+
+		if err != nil {
+			return err
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+
+		if err != nil {
+			return errors.New("Not valid dry-run value")
+		}
+
+		// The database is opened first, it loads the states of the workflow
+		// the states of the file are checked against.
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		f, err := os.Open(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		incoming, notes, err := importer.Read(f, format, mapping)
+
+		if err != nil {
+			return err
+		}
+
+		for _, note := range notes {
+			fmt.Fprintln(os.Stderr, "note: "+note)
+		}
+
+		existing, err := todoDB.GetTasksByFilter(db.Filter{})
+
+		if err != nil {
+			return err
+		}
+
+		entries := importer.Plan(incoming, existing)
+		todos := importer.New(entries)
+		duplicates := len(entries) - len(todos)
+
+		if dryRun {
+			if err := importer.WriteDiff(os.Stdout, entries); err != nil {
+				return err
+			}
+
+			fmt.Printf("%d tasks would be imported, %d duplicates skipped, nothing was changed.\n", len(todos), duplicates)
+
+			return nil
+		}
+
+		if _, err := todoDB.ImportTodos(todos); err != nil {
+			return err
+		}
+
+		fmt.Printf("%d tasks imported, %d duplicates skipped.\n", len(todos), duplicates)
+
+		return nil
+	},
+}
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [path]",
+	Short: "create tasks from the TODO, FIXME and XXX comments of your code",
+	Long: `create a task for every TODO, FIXME and XXX comment of the files of a repository, "todo scan ~/code/app" scans the current directory when no path is passed.
+The files ignored by .gitignore, the documents and the binary files are skipped. The title of every task is the comment, the new tasks are tagged with --tag and the file and line of every comment are remembered, the open tasks are listed with them after the scan.
+Scanning again follows the comments that moved without changing their tasks, closes the tasks of the comments removed and doesn't duplicate the rest.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tag, err := cmd.Flags().GetString("tag")
+
+		if err != nil || strings.TrimSpace(tag) == "" {
+			return errors.New("Not valid tag")
+		}
+
+		path := "."
+
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		tx, err := todoDB.Begin()
+
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback()
+
+		report, err := scan.Scan(tx, path, strings.TrimSpace(tag))
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(report)
+
+		tasks, err := scan.Tasks(todoDB, path)
+
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+
+		for _, task := range tasks {
+			fmt.Fprintf(w, "%d\t%s:%d\t%s\n", task.ID, task.Path, task.Line, task.Todo.Todo)
+		}
+
+		return w.Flush()
+	},
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "keep your tasks in sync with other tools",
+	Long:  `keep the tasks in sync in both directions with files other tools can edit, the database stays the source of truth.`,
+}
+
+var syncTodoTxtCmd = &cobra.Command{
+	Use:   "todotxt <path>",
+	Short: "keep your tasks in sync with a todo.txt file",
+	Long: `keep the tasks in sync with a todo.txt file in both directions, "todo sync todotxt ~/todo.txt" creates the file the first time and later copies the changes made on each side to the other one.
+Every line gets the id: of its task, the lines added without one become new tasks and the tasks removed on one side are deleted from the other.
+When a task changed on both sides since the last sync --conflict decides which one wins: db, the default, keeps the database, file keeps the file and newer keeps the side modified last.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conflict, err := cmd.Flags().GetString("conflict")
+
+		if err != nil {
+			return errors.New("Not valid conflict policy")
+		}
+
+		policy, err := todotxt.ParsePolicy(conflict)
+
+		if err != nil {
+			return err
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		tx, err := todoDB.Begin()
+
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback()
+
+		report, err := todotxt.Sync(tx, args[0], policy)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(report)
+
+		return nil
+	},
+}
+
+var syncMarkdownCmd = &cobra.Command{
+	Use:   "markdown <dir>",
+	Short: "keep your tasks in sync with the checklists of a notes folder",
+	Long: `keep the tasks in sync with the "- [ ]" and "- [x]" checklist items of the Markdown notes in a folder, like an Obsidian vault, "todo sync markdown ~/notes" imports the new items as tasks tagged with the name of their note.
+Every item gets a hidden <!-- todo:N --> marker with the id of its task, so editing its title or checking it on one side is copied to the other one in the next sync. The items checked complete their task and the tasks done here are checked in the notes, a task completed with "todo done" is checked in its note right away. The items removed from the notes close their task, which isn't synced with them anymore, and the tasks deleted remove their item.
+When a task changed on both sides since the last sync --conflict decides which one wins: db, the default, keeps the database, file keeps the note and newer keeps the side modified last.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conflict, err := cmd.Flags().GetString("conflict")
+
+		if err != nil {
+			return errors.New("Not valid conflict policy")
+		}
+
+		policy, err := markdown.ParsePolicy(conflict)
+
+		if err != nil {
+			return err
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		tx, err := todoDB.Begin()
+
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback()
+
+		report, err := markdown.Sync(tx, args[0], policy)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(report)
+
+		return nil
+	},
+}
+
+var syncCalDAVCmd = &cobra.Command{
+	Use:   "caldav",
+	Short: "keep your tasks in sync with a CalDAV calendar",
+	Long: `keep the tasks in sync with the tasks of a CalDAV calendar, "todo sync caldav --url https://dav.example.com/calendars/me --calendar tasks --user me" pushes the tasks changed here and pulls the ones changed on the server since the last sync.
+The password is read from the TODO_CALDAV_PASSWORD environment variable. Only the changes are asked to servers that support sync tokens, the rest are compared by ETag.
+When a task changed on both sides since the last sync --conflict decides which one wins: db, the default, keeps the database, server keeps the server and newer keeps the side modified last.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serverURL, err := cmd.Flags().GetString("url")
+
+		if err != nil {
+			return errors.New("Not valid url")
+		}
+
+		calendar, err := cmd.Flags().GetString("calendar")
+
+		if err != nil {
+			return errors.New("Not valid calendar")
+		}
+
+		user, err := cmd.Flags().GetString("user")
+
+		if err != nil {
+			return errors.New("Not valid user")
+		}
+
+		conflict, err := cmd.Flags().GetString("conflict")
+
+		if err != nil {
+			return errors.New("Not valid conflict policy")
+		}
+
+		policy, err := caldav.ParsePolicy(conflict)
+
+		if err != nil {
+			return err
+		}
+
+		client, err := caldav.NewClient(serverURL, calendar, user, os.Getenv("TODO_CALDAV_PASSWORD"))
+
+		if err != nil {
+			return err
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		report, err := caldav.Sync(todoDB, client, policy)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(report)
+
+		return nil
+	},
+}
+
+var startCmd = &cobra.Command{
+	Use:   "start [id or range...]",
+	Short: "move the tasks with the ids passed to in-progress",
+	Long: `move the tasks to in-progress, "todo start 1" will start the task with the id 1 and "todo start 3 5 8-12" the tasks 3, 5 and from 8 to 12.
+Without ids nor filters the tasks can be chosen interactively.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		return runBulk(cmd, args, bulkAction{
+			description: "moved to " + db.InProgress.String(),
+			skipped:     "was already in " + db.InProgress.String(),
+			filter:      db.Filter{Open: true},
+			skip:        inState(db.InProgress),
+			getTasks:    todoDB.GetTasksByFilter,
+			apply: func(ids []int) ([]db.Result, error) {
+				return todoDB.MoveTodos(ids, db.InProgress)
+			},
+		})
+	},
+}
+
+var moveCmd = &cobra.Command{
+	Use:   "move [id or range...] <state>",
+	Short: "move the tasks with the ids passed to another state",
+	Long: `move the tasks to the state passed as last argument, "todo move 1 review" will move the task with the id 1 to review and "todo move 3 5 8-12 blocked" the tasks 3, 5 and from 8 to 12 to blocked.
+The states can be listed with "todo states". Without ids nor filters the tasks can be chosen interactively.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		state, err := db.ParseStatus(args[len(args)-1])
+
+		if err != nil {
+			return err
+		}
+
+		return runBulk(cmd, args[:len(args)-1], bulkAction{
+			description: "moved to " + state.String(),
+			skipped:     "was already in " + state.String(),
+			skip:        inState(state),
+			getTasks:    todoDB.GetTasksByFilter,
+			apply: func(ids []int) ([]db.Result, error) {
+				return todoDB.MoveTodos(ids, state)
+			},
+		})
+	},
+}
+
+var statesCmd = &cobra.Command{
+	Use:   "states",
+	Short: "list the states of the workflow",
+	Long:  `list the states the tasks go through in order, with how many tasks are in each of them. Closed states hold the finished tasks and are hidden by "todo list".`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		counts, err := todoDB.CountByState()
+
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+
+		for _, state := range db.States() {
+			closed := ""
+			if state.Closed {
+				closed = "closed"
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\n", state.Position, state.Name, counts[state.ID], closed)
+		}
+
+		return w.Flush()
+	},
+}
+
+var addStateCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "add a state to the workflow",
+	Long:  `add a state to the workflow, "todo states add qa --position 4" adds it before the fifth state and "todo states add wontfix --closed" adds a closed state at the end`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		position, err := cmd.Flags().GetInt("position")
+
+		if err != nil {
+			return errors.New("Not valid position")
+		}
+
+		closed, err := cmd.Flags().GetBool("closed")
+
+		if err != nil {
+			return errors.New("Not valid closed value")
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		return todoDB.AddState(args[0], position, closed)
+	},
+}
+
+var removeStateCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "remove a state without tasks from the workflow",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		return todoDB.RemoveState(args[0])
+	},
+}
+
+var boardCmd = &cobra.Command{
+	Use:   "board",
+	Short: "show your tasks in a kanban board",
+	Long:  `show your tasks in a kanban board with a column for every state, the selected task can be moved to the previous or the next state with shift+←/→ or H/L`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tag, err := cmd.Flags().GetString("tag")
+
+		if err != nil {
+			return errors.New("Not valid tag")
+		}
+
+		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
+		defer todoDB.Close()
+
+		m, err := board.New(todoDB, tag)
+
+		if err != nil {
+			return err
+		}
+
+		p := tea.NewProgram(m, tea.WithAltScreen())
+		_, err = p.Run()
+
+		return err
+	},
 }
 
-var deleteTodoCmd = &cobra.Command{
-	Use:   "delete",
-	Short: "delete the task with the id passed",
-	Long:  `delete the task, "todo delete 1" will delete the task with the id 1`,
-	Args:  cobra.MaximumNArgs(1),
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "manage your tasks in a full screen interface",
+	Long:  `manage your tasks in a full screen interface where they can be added, edited, completed, deleted and filtered, every change is saved right away`,
+	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		todoDB, err := db.NewTodoDB()
+
+		if err != nil {
+			return err
+		}
+
 		defer todoDB.Close()
 
+		m, err := ui.New(todoDB)
+
 		if err != nil {
 			return err
 		}
 
-		if len(args) == 0 {
-			todos, err := todoDB.GetTasks("")
+		p := tea.NewProgram(m, tea.WithAltScreen())
+		_, err = p.Run()
 
-			if err != nil {
-				return err
-			}
+		return err
+	},
+}
 
-			m := list_actionable.NewTodoTable(todos)
-			p := tea.NewProgram(m)
-			model, err := p.Run()
+// bulkAction describes how done, pending and delete change the tasks.
+type bulkAction struct {
+	description string             // what happened to every task, e.g. "marked as done"
+	skipped     string             // what the skipped tasks are, e.g. "was already done"
+	filter      db.Filter          // the tasks the action applies to, all when empty
+	skip        func(db.Todo) bool // the tasks the action wouldn't change, none when nil
+	getTasks    func(db.Filter) ([]db.Todo, error)
+	apply       func([]int) ([]db.Result, error)
+}
 
-			if err != nil {
-				return err
-			}
+// inState skips the tasks that already are in the state.
+func inState(state db.Status) func(db.Todo) bool {
+	return func(todo db.Todo) bool {
+		return todo.State == state
+	}
+}
 
-			if task, ok := model.(list_actionable.Model); ok {
-				id, err := strconv.Atoi(task.SelectedId)
+// runBulk applies the action to the tasks with the ids and ranges passed, to
+// the tasks matching the --tag and --created flags, or to both when used
+// together. Without any of them the tasks are chosen interactively. Above the
+// --confirm-above threshold the user has to confirm, unless --yes is used, and
+// the tasks the action wouldn't change, like the done ones for done, are only
+// reported.
+func runBulk(cmd *cobra.Command, args []string, action bulkAction) error {
+	ids, err := parseIDs(args)
 
-				if err != nil {
-					return nil
-				}
+	if err != nil {
+		return err
+	}
 
-				err = todoDB.DeleteTodo(id)
+	tag, err := cmd.Flags().GetString("tag")
 
-				if err != nil {
-					return errors.New(fmt.Sprintf("todo with id %d couldn't be deleted", id))
-				}
+	if err != nil {
+		return errors.New("Not valid tag")
+	}
 
-				fmt.Printf("task with the id %d deleted.\n", id)
+	created, err := cmd.Flags().GetString("created")
 
-				return nil
-			}
+	if err != nil {
+		return errors.New("Not valid period")
+	}
+
+	filter := action.filter
+	filter.IDs, filter.Tag = ids, tag
+
+	if created != "" {
+		filter.CreatedFrom, filter.CreatedTo, err = dates.ParsePeriod(created, time.Now())
+
+		if err != nil {
+			return err
 		}
+	}
 
-		id, err := strconv.Atoi(args[0])
+	interactive := len(ids) == 0 && tag == "" && created == ""
+
+	if interactive {
+		todos, err := action.getTasks(filter)
+
+		if err != nil {
+			return err
+		}
+
+		p := tea.NewProgram(list_actionable.NewTodoTable(todos))
+		model, err := p.Run()
 
 		if err != nil {
-			return errors.New("Not a valid task id")
+			return err
 		}
 
-		err = todoDB.DeleteTodo(id)
+		task, ok := model.(list_actionable.Model)
+
+		if !ok || len(task.SelectedIds) == 0 {
+			return nil
+		}
+
+		ids = task.SelectedIds
+	} else if tag != "" || created != "" {
+		todos, err := action.getTasks(filter)
 
 		if err != nil {
-			return errors.New(fmt.Sprintf("todo with id %d couldn't be deleted", id))
+			return err
 		}
 
-		fmt.Printf("task with the id %d deleted.\n", id)
+		ids = []int{}
 
+		for _, todo := range todos {
+			ids = append(ids, todo.ID)
+		}
+
+		if len(ids) == 0 {
+			fmt.Println("no tasks match the filters.")
+			return nil
+		}
+	}
+
+	// The ids passed that don't exist are reported after applying the action,
+	// the confirmation only counts the tasks the action changes.
+	existing, err := action.getTasks(db.Filter{IDs: ids})
+
+	if err != nil {
+		return err
+	}
+
+	skipped := map[int]bool{}
+
+	for _, todo := range existing {
+		if action.skip != nil && action.skip(todo) {
+			skipped[todo.ID] = true
+		}
+	}
+
+	confirmed, err := confirmBulk(cmd, len(existing)-len(skipped), action.description)
+
+	if err != nil {
+		return err
+	}
+
+	if !confirmed {
+		fmt.Println("no tasks were changed.")
 		return nil
-	},
+	}
+
+	changed := []int{}
+
+	for _, id := range ids {
+		if !skipped[id] {
+			changed = append(changed, id)
+		}
+	}
+
+	// The results come with the error of the state hooks when the tasks changed
+	// but their synced copies couldn't be updated.
+	results, err := action.apply(changed)
+
+	if results == nil {
+		return err
+	}
+
+	errs := map[int]error{}
+
+	for _, result := range results {
+		errs[result.ID] = result.Err
+	}
+
+	var failed []error
+
+	for _, id := range ids {
+		switch {
+		case skipped[id]:
+			fmt.Printf("task with the id %d %s.\n", id, action.skipped)
+		case errs[id] != nil:
+			failed = append(failed, fmt.Errorf("todo with id %d couldn't be %s: %w", id, action.description, errs[id]))
+		default:
+			fmt.Printf("task with the id %d %s.\n", id, action.description)
+		}
+	}
+
+	return errors.Join(append(failed, err)...)
+}
+
+// confirmBulk asks the user before changing more tasks than the threshold set
+// by --confirm-above.
+func confirmBulk(cmd *cobra.Command, count int, description string) (bool, error) {
+	yes, err := cmd.Flags().GetBool("yes")
+
+	if err != nil {
+		return false, errors.New("Not valid yes")
+	}
+
+	threshold, err := cmd.Flags().GetInt("confirm-above")
+
+	if err != nil {
+		return false, errors.New("Not valid confirmation threshold")
+	}
+
+	if yes || count <= threshold {
+		return true, nil
+	}
+
+	fmt.Printf("%d tasks will be %s, continue? [y/N] ", count, description)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false, nil
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes", nil
+}
+
+// maxBulkIDs is the most ids that can be passed at once, counting the ones of
+// the ranges.
+const maxBulkIDs = 10000
+
+// parseIDs parses task ids and ranges of ids like 8-12, the repeated ids are
+// only returned once.
+func parseIDs(args []string) ([]int, error) {
+	ids := []int{}
+	seen := map[int]bool{}
+
+	for _, arg := range args {
+		first, last, isRange := strings.Cut(arg, "-")
+
+		from, err := strconv.Atoi(first)
+
+		if err != nil {
+			return nil, fmt.Errorf("Not a valid task id %q", arg)
+		}
+
+		to := from
+
+		if isRange {
+			to, err = strconv.Atoi(last)
+
+			if err != nil || to < from {
+				return nil, fmt.Errorf("Not a valid range of task ids %q", arg)
+			}
+		}
+
+		if to-from >= maxBulkIDs-len(ids) {
+			return nil, fmt.Errorf("Not a valid range of task ids %q, at most %d tasks can be changed at once", arg, maxBulkIDs)
+		}
+
+		for id := from; id <= to; id++ {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// showTodos shows the todos sorted, grouped and with the columns requested by
+// the --sort, --group-by and --columns flags, in the interactive table or in
+// the format requested by the --output or --format flags.
+func showTodos(cmd *cobra.Command, todos []db.Todo) error {
+	sortString, err := cmd.Flags().GetString("sort")
+
+	if err != nil {
+		return errors.New("Not valid sort")
+	}
+
+	order, err := todo_table.ParseOrder(sortString)
+
+	if err != nil {
+		return err
+	}
+
+	groupByString, err := cmd.Flags().GetString("group-by")
+
+	if err != nil {
+		return errors.New("Not valid group")
+	}
+
+	groupBy, err := todo_table.ParseGroupBy(groupByString)
+
+	if err != nil {
+		return err
+	}
+
+	columnsString, err := cmd.Flags().GetString("columns")
+
+	if err != nil {
+		return errors.New("Not valid columns")
+	}
+
+	columns, err := todo_table.ParseColumns(columnsString)
+
+	if err != nil {
+		return err
+	}
+
+	templateString, err := cmd.Flags().GetString("format")
+
+	if err != nil {
+		return errors.New("Not valid format")
+	}
+
+	outputString, err := cmd.Flags().GetString("output")
+
+	if err != nil {
+		return errors.New("Not valid output")
+	}
+
+	format, err := output.ParseFormat(outputString)
+
+	if err != nil {
+		return err
+	}
+
+	// The flags an output would ignore are rejected instead, so a script
+	// doesn't get fields or an order it didn't ask for.
+	for _, flag := range []struct {
+		name, value string
+		used        bool
+	}{
+		{"columns", columnsString, format.HasColumns()},
+		{"group-by", groupByString, format.HasGroups()},
+	} {
+		if templateString == "" && flag.value != "" && !flag.used {
+			return usageError{fmt.Errorf("--%s can't be used with --output %s\nrun %q for usage", flag.name, format, cmd.CommandPath()+" --help")}
+		}
+	}
+
+	if templateString != "" || format != output.Table {
+		todo_table.SortTodos(todos, order)
+
+		if templateString != "" {
+			return output.WriteTemplate(os.Stdout, templateString, todos)
+		}
+
+		return output.Write(os.Stdout, format, todos, columns, groupBy)
+	}
+
+	m := list_table.NewTodoTable(
+		todos,
+		todo_table.WithOrder(order),
+		todo_table.WithGroupBy(groupBy),
+		todo_table.WithColumns(columns),
+	)
+	p := tea.NewProgram(m)
+	_, err = p.Run()
+
+	return err
+}
+
+// confirmThreshold returns the default of --confirm-above, read from the
+// TODO_CONFIRM_ABOVE environment variable.
+func confirmThreshold() int {
+	if threshold, err := strconv.Atoi(os.Getenv("TODO_CONFIRM_ABOVE")); err == nil {
+		return threshold
+	}
+
+	return 5
 }
 
 // Flag --date -d today, yesterday, 2024-02-01
 
 func init() {
+	// The tasks synced with Markdown notes are checked in them as soon as they
+	// are completed.
+	db.OnStateChange(func(store db.SyncStore, todoIds []int) error {
+		return markdown.WriteBack(store, todoIds)
+	})
+
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{fmt.Errorf("%w\nrun %q for usage", err, cmd.CommandPath()+" --help")}
+	})
+
 	listCmd.PersistentFlags().StringP(
 		"date",
 		"d",
@@ -529,6 +1745,37 @@ func init() {
 		"tag used as identifier of your todos",
 	)
 
+	listCmd.PersistentFlags().String(
+		"sort",
+		"",
+		"sort the tasks by id, title, tag, state, created, completed or due, prefix it with - to sort in descending order",
+	)
+
+	listCmd.PersistentFlags().String(
+		"group-by",
+		"",
+		"group the tasks by tag, state or day in the table, plain and markdown outputs",
+	)
+
+	listCmd.PersistentFlags().String(
+		"columns",
+		"",
+		"comma separated columns to show: id, title, tag, state, created, completed, due and priority, the json and jsonl outputs always have every field",
+	)
+
+	listCmd.PersistentFlags().StringP(
+		"output",
+		"o",
+		"",
+		"output format: table, plain, json, jsonl, csv, tsv or markdown, plain is used by default when the output isn't a terminal",
+	)
+
+	listCmd.PersistentFlags().String(
+		"format",
+		"",
+		`Go template executed for every task, e.g. --format '{{.ID}} {{.Todo}}'`,
+	)
+
 	addCmd.PersistentFlags().StringP(
 		"tag",
 		"t",
@@ -536,13 +1783,278 @@ func init() {
 		"tag used as identifier of your todos",
 	)
 
+	addCmd.PersistentFlags().String(
+		"due",
+		"",
+		"date the task is due with format YYYY-MM-DD, today or tomorrow",
+	)
+
+	agendaCmd.Flags().Bool(
+		"week",
+		false,
+		"show the whole week",
+	)
+
+	agendaCmd.Flags().Bool(
+		"month",
+		false,
+		"show the whole month",
+	)
+
+	agendaCmd.MarkFlagsMutuallyExclusive("week", "month")
+
+	agendaCmd.Flags().StringP(
+		"tag",
+		"t",
+		"",
+		"show only the tasks with this tag",
+	)
+
+	for _, cmd := range []*cobra.Command{markAsDoneCmd, markAsNotDoneCmd, deleteTodoCmd, startCmd, moveCmd} {
+		cmd.Flags().StringP(
+			"tag",
+			"t",
+			"",
+			"change only the tasks with this tag",
+		)
+
+		cmd.Flags().String(
+			"created",
+			"",
+			"change only the tasks created in this period: a date with format YYYY-MM-DD, today, yesterday, this-week, last-week, this-month, last-month, a number of days like 30d or FROM..TO",
+		)
+
+		cmd.Flags().BoolP(
+			"yes",
+			"y",
+			false,
+			"don't ask for confirmation",
+		)
+
+		cmd.Flags().Int(
+			"confirm-above",
+			confirmThreshold(),
+			"ask for confirmation when more tasks than this are changed, the default can be set with TODO_CONFIRM_ABOVE",
+		)
+	}
+
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(markAsDoneCmd)
 	rootCmd.AddCommand(markAsNotDoneCmd)
 	rootCmd.AddCommand(deleteTodoCmd)
 
+	addStateCmd.Flags().Int(
+		"position",
+		-1,
+		"place of the new state in the workflow, starting at 0, at the end by default",
+	)
+
+	addStateCmd.Flags().Bool(
+		"closed",
+		false,
+		"the tasks in this state are finished",
+	)
+
+	statsCmd.Flags().StringP(
+		"tag",
+		"t",
+		"",
+		"use only the tasks with this tag",
+	)
+
+	statsCmd.Flags().String(
+		"since",
+		"",
+		"use only the tasks created in this period: a date with format YYYY-MM-DD, today, yesterday, this-week, last-week, this-month, last-month, a number of days like 30d or FROM..TO",
+	)
+
+	statsCmd.Flags().Bool(
+		"json",
+		false,
+		"print the statistics as json, the lead times are in hours",
+	)
+
+	activityCmd.Flags().StringP(
+		"tag",
+		"t",
+		"",
+		"use only the tasks with this tag",
+	)
+
+	activityCmd.Flags().Bool(
+		"ascii",
+		false,
+		"draw only with ASCII characters and without colors",
+	)
+
+	standupCmd.Flags().StringP(
+		"tag",
+		"t",
+		"",
+		"use only the tasks with this tag",
+	)
+
+	standupCmd.Flags().String(
+		"since",
+		"yesterday",
+		"day of the last standup with format YYYY-MM-DD, today or yesterday, which skips the weekend",
+	)
+
+	standupCmd.Flags().StringP(
+		"output",
+		"o",
+		string(standup.Markdown),
+		"output format: markdown or slack",
+	)
+
+	reviewCmd.Flags().StringP(
+		"tag",
+		"t",
+		"",
+		"review only the tasks with this tag",
+	)
+
+	exportCmd.Flags().String(
+		"format",
+		string(export.JSON),
+		"format of the export: json, csv, markdown, todotxt, ics, taskwarrior or org",
+	)
+
+	exportCmd.Flags().StringP(
+		"output",
+		"o",
+		"",
+		"file the tasks are written to, the standard output by default",
+	)
+
+	exportCmd.Flags().StringP(
+		"tag",
+		"t",
+		"",
+		"export only the tasks with this tag",
+	)
+
+	exportCmd.Flags().StringSlice(
+		"state",
+		nil,
+		"export only the tasks in these comma separated states",
+	)
+
+	exportCmd.Flags().String(
+		"created",
+		"",
+		"export only the tasks created in this period: a date with format YYYY-MM-DD, today, yesterday, this-week, last-week, this-month, last-month, a number of days like 30d or FROM..TO",
+	)
+
+	exportCmd.Flags().String(
+		"completed",
+		"",
+		"export only the tasks completed in this period, with the same values as --created",
+	)
+
+	importCmd.Flags().String(
+		"format",
+		"",
+		"format of the file: json, csv, todotxt, ics, taskwarrior or org, taken from its extension by default, .txt files are todo.txt",
+	)
+
+	importCmd.Flags().StringArray(
+		"map",
+		nil,
+		"read a field of the tasks from another column, field=column, the fields are todo, state, tag, date_created, date_completed, date_due, date_reviewed, priority and uid",
+	)
+
+	importCmd.Flags().Bool(
+		"dry-run",
+		false,
+		"show the tasks that would be imported and the duplicates without importing them",
+	)
+
+	scanCmd.Flags().String(
+		"tag",
+		scan.DefaultTag,
+		"tag of the tasks of the comments",
+	)
+
+	syncTodoTxtCmd.Flags().String(
+		"conflict",
+		string(todotxt.PreferDatabase),
+		"side kept when a task changed in both: db, file or newer",
+	)
+
+	syncMarkdownCmd.Flags().String(
+		"conflict",
+		string(markdown.PreferDatabase),
+		"side kept when a task changed in both: db, file or newer",
+	)
+
+	syncCalDAVCmd.Flags().String(
+		"url",
+		"",
+		"url of the CalDAV server where the calendars are, like https://dav.example.com/calendars/me",
+	)
+
+	syncCalDAVCmd.Flags().String(
+		"calendar",
+		"",
+		"name of the calendar of the tasks",
+	)
+
+	syncCalDAVCmd.Flags().String(
+		"user",
+		"",
+		"user of the CalDAV server, the password is read from TODO_CALDAV_PASSWORD",
+	)
+
+	syncCalDAVCmd.Flags().String(
+		"conflict",
+		string(caldav.PreferDatabase),
+		"side kept when a task changed in both: db, server or newer",
+	)
+
+	syncCalDAVCmd.MarkFlagRequired("url")
+	syncCalDAVCmd.MarkFlagRequired("calendar")
+
+	calendarCmd.Flags().StringP(
+		"tag",
+		"t",
+		"",
+		"show only the tasks with this tag",
+	)
+
+	boardCmd.Flags().StringP(
+		"tag",
+		"t",
+		"",
+		"show only the tasks with this tag",
+	)
+
+	rootCmd.AddCommand(dueCmd)
+	rootCmd.AddCommand(agendaCmd)
+	rootCmd.AddCommand(calendarCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(activityCmd)
+	rootCmd.AddCommand(standupCmd)
+	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(moveCmd)
+	rootCmd.AddCommand(statesCmd)
+	rootCmd.AddCommand(boardCmd)
+	rootCmd.AddCommand(uiCmd)
+
 	listCmd.AddCommand(listAllCmd)
 	listCmd.AddCommand(listPendingTasksCmd)
 	listCmd.AddCommand(listDoneTasksCmd)
+
+	syncCmd.AddCommand(syncTodoTxtCmd)
+	syncCmd.AddCommand(syncCalDAVCmd)
+	syncCmd.AddCommand(syncMarkdownCmd)
+
+	statesCmd.AddCommand(addStateCmd)
+	statesCmd.AddCommand(removeStateCmd)
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []int
+		wantErr bool
+	}{
+		{name: "single", args: []string{"3"}, want: []int{3}},
+		{name: "several", args: []string{"3", "1", "2"}, want: []int{3, 1, 2}},
+		{name: "range", args: []string{"2-5"}, want: []int{2, 3, 4, 5}},
+		{name: "range of one", args: []string{"4-4"}, want: []int{4}},
+		{name: "duplicates", args: []string{"1-3", "2", "3-4"}, want: []int{1, 2, 3, 4}},
+		{name: "biggest range", args: []string{"1-10000"}, want: seq(1, 10000)},
+		{name: "not a number", args: []string{"a"}, wantErr: true},
+		{name: "empty", args: []string{""}, wantErr: true},
+		{name: "reversed range", args: []string{"5-2"}, wantErr: true},
+		{name: "open range", args: []string{"2-"}, wantErr: true},
+		{name: "range too big", args: []string{"1-10001"}, wantErr: true},
+		{name: "huge range", args: []string{"1-2000000000"}, wantErr: true},
+		{name: "ranges too big together", args: []string{"1-6000", "10001-15000"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := parseIDs(tt.args)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIDs(%q) error = %v, want error %v", tt.args, err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("parseIDs(%q) = %v, want %v", tt.args, ids, tt.want)
+			}
+		})
+	}
+}
+
+func seq(from, to int) []int {
+	ids := []int{}
+	for id := from; id <= to; id++ {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
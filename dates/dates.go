@@ -0,0 +1,119 @@
+package dates
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const layout = "2006-01-02"
+
+var errNotValidDate = errors.New("Not valid date, use YYYY-MM-DD, today, yesterday or tomorrow")
+
+var errNotValidPeriod = errors.New("Not valid period, use a date, this-week, last-week, this-month, last-month, a number of days like 30d or FROM..TO")
+
+// StartOfDay returns the midnight that starts the day of t.
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// DaysBetween returns the calendar days from the day of a to the day of b, the
+// days of 23 or 25 hours when daylight saving time changes count as one.
+func DaysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+
+	return int(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+}
+
+// StartOfWeek returns the midnight that starts the week of t, weeks start on
+// Monday.
+func StartOfWeek(t time.Time) time.Time {
+	weekday := (int(t.Weekday()) + 6) % 7
+	return StartOfDay(t).AddDate(0, 0, -weekday)
+}
+
+// StartOfMonth returns the midnight that starts the month of t.
+func StartOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// ParseDay parses a day with format YYYY-MM-DD or one of the special days
+// today, yesterday and tomorrow, relative to now.
+func ParseDay(s string, now time.Time) (time.Time, error) {
+	switch s {
+	case "today":
+		return StartOfDay(now), nil
+	case "yesterday":
+		return StartOfDay(now).AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return StartOfDay(now).AddDate(0, 0, 1), nil
+	}
+
+	day, err := time.ParseInLocation(layout, s, now.Location())
+
+	if err != nil {
+		return time.Time{}, errNotValidDate
+	}
+
+	return day, nil
+}
+
+// ParsePeriod parses a period of time relative to now and returns its start,
+// inclusive, and its end, exclusive. A period can be a single day as accepted
+// by ParseDay, this-week, last-week, this-month, last-month, the last number
+// of days like 30d, which includes today, or two days separated by "..".
+func ParsePeriod(s string, now time.Time) (from, to time.Time, err error) {
+	switch s {
+	case "this-week":
+		from = StartOfWeek(now)
+		return from, from.AddDate(0, 0, 7), nil
+	case "last-week":
+		to = StartOfWeek(now)
+		return to.AddDate(0, 0, -7), to, nil
+	case "this-month":
+		from = StartOfMonth(now)
+		return from, from.AddDate(0, 1, 0), nil
+	case "last-month":
+		to = StartOfMonth(now)
+		return to.AddDate(0, -1, 0), to, nil
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+
+		if err != nil || n <= 0 {
+			return from, to, errNotValidPeriod
+		}
+
+		to = StartOfDay(now).AddDate(0, 0, 1)
+		return to.AddDate(0, 0, -n), to, nil
+	}
+
+	if first, last, ok := strings.Cut(s, ".."); ok {
+		from, err = ParseDay(first, now)
+
+		if err != nil {
+			return from, to, errNotValidPeriod
+		}
+
+		to, err = ParseDay(last, now)
+
+		if err != nil || to.Before(from) {
+			return from, to, errNotValidPeriod
+		}
+
+		return from, to.AddDate(0, 0, 1), nil
+	}
+
+	from, err = ParseDay(s, now)
+
+	if err != nil {
+		return from, to, errNotValidPeriod
+	}
+
+	return from, from.AddDate(0, 0, 1), nil
+}
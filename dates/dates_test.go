@@ -0,0 +1,88 @@
+package dates
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2024, 3, 13, 15, 30, 0, 0, time.UTC)
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		period   string
+		from, to time.Time
+		wantErr  bool
+	}{
+		{period: "today", from: day(2024, 3, 13), to: day(2024, 3, 14)},
+		{period: "yesterday", from: day(2024, 3, 12), to: day(2024, 3, 13)},
+		{period: "tomorrow", from: day(2024, 3, 14), to: day(2024, 3, 15)},
+		{period: "2024-02-29", from: day(2024, 2, 29), to: day(2024, 3, 1)},
+		{period: "this-week", from: day(2024, 3, 11), to: day(2024, 3, 18)},
+		{period: "last-week", from: day(2024, 3, 4), to: day(2024, 3, 11)},
+		{period: "this-month", from: day(2024, 3, 1), to: day(2024, 4, 1)},
+		{period: "last-month", from: day(2024, 2, 1), to: day(2024, 3, 1)},
+		{period: "1d", from: day(2024, 3, 13), to: day(2024, 3, 14)},
+		{period: "30d", from: day(2024, 2, 13), to: day(2024, 3, 14)},
+		{period: "2024-03-01..2024-03-05", from: day(2024, 3, 1), to: day(2024, 3, 6)},
+		{period: "2024-03-01..today", from: day(2024, 3, 1), to: day(2024, 3, 14)},
+		{period: "2024-03-05..2024-03-05", from: day(2024, 3, 5), to: day(2024, 3, 6)},
+		{period: "0d", wantErr: true},
+		{period: "-3d", wantErr: true},
+		{period: "xd", wantErr: true},
+		{period: "2024-03-05..2024-03-01", wantErr: true},
+		{period: "2024-03-01..", wantErr: true},
+		{period: "2024-13-01", wantErr: true},
+		{period: "next-week", wantErr: true},
+		{period: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			from, to, err := ParsePeriod(tt.period, now)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePeriod(%q) error = %v, want error %v", tt.period, err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("ParsePeriod(%q) = %v, %v, want %v, %v", tt.period, from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestDaysBetween(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+
+	if err != nil {
+		t.Skip("the time zone database isn't available")
+	}
+
+	tests := []struct {
+		name string
+		a, b time.Time
+		want int
+	}{
+		{name: "same day", a: time.Date(2024, 3, 13, 0, 0, 0, 0, madrid), b: time.Date(2024, 3, 13, 23, 0, 0, 0, madrid), want: 0},
+		{name: "next day", a: time.Date(2024, 3, 13, 23, 0, 0, 0, madrid), b: time.Date(2024, 3, 14, 1, 0, 0, 0, madrid), want: 1},
+		{name: "across spring forward", a: time.Date(2024, 3, 25, 0, 0, 0, 0, madrid), b: time.Date(2024, 4, 1, 0, 0, 0, 0, madrid), want: 7},
+		{name: "across fall back", a: time.Date(2024, 10, 21, 0, 0, 0, 0, madrid), b: time.Date(2024, 10, 28, 0, 0, 0, 0, madrid), want: 7},
+		{name: "backwards", a: time.Date(2024, 3, 14, 0, 0, 0, 0, madrid), b: time.Date(2024, 3, 13, 0, 0, 0, 0, madrid), want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DaysBetween(tt.a, tt.b); got != tt.want {
+				t.Errorf("DaysBetween(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
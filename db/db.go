@@ -1,11 +1,13 @@
 package db
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	_ "github.com/ncruces/go-sqlite3/driver"
@@ -14,35 +16,63 @@ import (
 
 const todoDirectory = ".todo"
 
-type status int
-
-const (
-	Pending status = iota
-	Done
+// Errors returned by the todoDB methods, they can be checked with errors.Is.
+var (
+	ErrNotFound     = errors.New("todo not found")
+	ErrInvalidState = errors.New("invalid todo state")
+	ErrStorage      = errors.New("storage error")
 )
 
-func (s status) String() string {
-	return [2]string{"todo", "done"}[s]
+// storageError wraps the errors coming from the database so they can be told
+// apart from the ones caused by the user.
+func storageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrStorage, err)
 }
 
+// Status is the id of the workflow state of a todo.
+type Status int
+
 type Todo struct {
 	ID            int
 	Todo          string
-	State         status
+	State         Status
 	DateCreated   time.Time // Probar si funciona bien el time.Time
 	DateCompleted sql.NullTime
 	Tag           string
+	DateDue       sql.NullTime
+	DateReviewed  sql.NullTime
+	Priority      string    // a letter from A, the highest, to Z, empty for none
+	DateModified  time.Time // last time any field but the review date changed
+	UID           string    // identifies the todo in other apps, kept by the imports
 }
 
+// ValidPriority reports whether the priority is a letter from A to Z or empty.
+func ValidPriority(priority string) bool {
+	return priority == "" || len(priority) == 1 && priority[0] >= 'A' && priority[0] <= 'Z'
+}
+
+// todoColumns are the columns read into a Todo, in the order getTodosHelper
+// scans them.
+const todoColumns = "id, todo, state, tag, date_created, date_completed, date_due, date_reviewed, priority, date_modified, uid"
+
 type todoDB struct {
 	db *sql.DB
+	// tx is the transaction of a todoDB returned by Begin, its changes are
+	// made in it.
+	tx *sql.Tx
+	// moved are the todos that changed state in the transaction, the state
+	// hooks run for them once it is committed.
+	moved []int
 }
 
 func NewTodoDB() (*todoDB, error) {
 	homeUserDir, err := os.UserHomeDir()
 
 	if err != nil {
-		return nil, errors.New("Home User Directory couldn't be used")
+		return nil, fmt.Errorf("%w: home user directory couldn't be used", ErrStorage)
 	}
 
 	todoFullPathDirectory := filepath.Join(homeUserDir, todoDirectory)
@@ -60,20 +90,32 @@ func NewTodoDB() (*todoDB, error) {
 	todoDB.db, err = sql.Open("sqlite3", "file:"+todoFullPathFile)
 
 	if err != nil {
-		return nil, err
+		return nil, storageError(err)
 	}
 
 	err = todoDB.setupTodoSchema()
 
 	if err != nil {
-		return nil, err
+		return nil, storageError(err)
+	}
+
+	err = todoDB.migrate()
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	err = todoDB.setupStatesSchema()
+
+	if err != nil {
+		return nil, storageError(err)
 	}
 
 	return todoDB, nil
 }
 
 func (t *todoDB) setupTodoSchema() error {
-	_, err := t.db.Exec(`
+	_, err := t.conn().Exec(`
 		CREATE TABLE IF NOT EXISTS todos (
 			id               INTEGER PRIMARY KEY AUTOINCREMENT,
 			todo             VARCHAR(255) NOT NULL,
@@ -91,16 +133,154 @@ func (t *todoDB) setupTodoSchema() error {
 	return nil
 }
 
+// migrations are the changes made to the schema after the todos table was
+// created, the user_version of the database counts how many of them have been
+// applied.
+var migrations = []string{
+	"ALTER TABLE todos ADD COLUMN date_due DATETIME",
+	"ALTER TABLE todos ADD COLUMN date_reviewed DATETIME",
+	"ALTER TABLE todos ADD COLUMN priority VARCHAR(1) NOT NULL DEFAULT ''",
+	"ALTER TABLE todos ADD COLUMN date_modified DATETIME",
+	"UPDATE todos SET date_modified = date_created",
+	"ALTER TABLE todos ADD COLUMN uid VARCHAR(255) NOT NULL DEFAULT ''",
+	// A random version 4 UUID, like the ones newUID makes.
+	`UPDATE todos SET uid = lower(
+		hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+		substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+	)`,
+	`CREATE TABLE IF NOT EXISTS sync_items (
+		source     VARCHAR(255) NOT NULL,
+		todo_id    INTEGER NOT NULL,
+		remote_id  VARCHAR(255) NOT NULL,
+		snapshot   TEXT NOT NULL,
+		PRIMARY KEY (source, todo_id)
+	)`,
+	"ALTER TABLE sync_items ADD COLUMN etag VARCHAR(255) NOT NULL DEFAULT ''",
+	`CREATE TABLE sync_tokens (
+		source     VARCHAR(255) PRIMARY KEY,
+		token      TEXT NOT NULL
+	)`,
+}
+
+func (t *todoDB) migrate() error {
+	var version int
+
+	if err := t.conn().QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := t.conn().Exec(migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+
+		// PRAGMA doesn't accept placeholders.
+		if _, err := t.conn().Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (t *todoDB) Close() error {
 	return t.db.Close()
 }
 
-func getTodosHelper(functionName string, db *sql.DB, predicate string, filters ...any) ([]Todo, error) {
+// Begin returns a todoDB whose changes are made in a single transaction, they
+// are saved by its Commit and discarded by its Rollback.
+func (t *todoDB) Begin() (*todoDB, error) {
+	if t.tx != nil {
+		return nil, fmt.Errorf("%w: the transaction has already begun", ErrStorage)
+	}
+
+	tx, err := t.db.Begin()
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return &todoDB{db: t.db, tx: tx}, nil
+}
+
+// Commit saves the changes of a todoDB returned by Begin.
+func (t *todoDB) Commit() error {
+	if t.tx == nil {
+		return fmt.Errorf("%w: there isn't a transaction to commit", ErrStorage)
+	}
+
+	if err := t.tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	moved := t.moved
+	t.moved = nil
+
+	return (&todoDB{db: t.db}).stateChanged(moved)
+}
+
+// Rollback discards the changes of a todoDB returned by Begin, it does nothing
+// after Commit so it can be deferred.
+func (t *todoDB) Rollback() error {
+	if t.tx == nil {
+		return nil
+	}
+
+	if err := t.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+// conn returns the transaction of the todoDB or, outside of one, the database.
+func (t *todoDB) conn() queryExecer {
+	if t.tx != nil {
+		return t.tx
+	}
+
+	return t.db
+}
+
+// transaction is a transaction started by a method of the todoDB, inside the
+// one of Begin it is that one and only the Commit of the todoDB ends it.
+type transaction struct {
+	*sql.Tx
+	joined bool
+}
+
+func (t *todoDB) begin() (transaction, error) {
+	if t.tx != nil {
+		return transaction{Tx: t.tx, joined: true}, nil
+	}
+
+	tx, err := t.db.Begin()
+
+	return transaction{Tx: tx}, err
+}
+
+func (tx transaction) Commit() error {
+	if tx.joined {
+		return nil
+	}
+
+	return tx.Tx.Commit()
+}
+
+func (tx transaction) Rollback() error {
+	if tx.joined {
+		return nil
+	}
+
+	return tx.Tx.Rollback()
+}
+
+func getTodosHelper(functionName string, db queryExecer, predicate string, filters ...any) ([]Todo, error) {
 	var todos []Todo
 
 	rows, err := db.Query(predicate, filters...)
 	if err != nil {
-		return nil, fmt.Errorf("%q: %w", functionName, err)
+		return nil, fmt.Errorf("%q: %w", functionName, storageError(err))
 	}
 	defer rows.Close()
 
@@ -114,16 +294,26 @@ func getTodosHelper(functionName string, db *sql.DB, predicate string, filters .
 			&todo.Tag,
 			&todo.DateCreated,
 			&todo.DateCompleted,
+			&todo.DateDue,
+			&todo.DateReviewed,
+			&todo.Priority,
+			&todo.DateModified,
+			&todo.UID,
 		)
 
 		if err != nil {
-			return nil, fmt.Errorf("%q: %w", functionName, err)
+			return nil, fmt.Errorf("%q: %w", functionName, storageError(err))
 		}
+
+		if !todo.State.Valid() {
+			return nil, fmt.Errorf("%q: todo with id %d: %w %d", functionName, todo.ID, ErrInvalidState, todo.State)
+		}
+
 		todos = append(todos, todo)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%q: %w", functionName, err)
+		return nil, fmt.Errorf("%q: %w", functionName, storageError(err))
 	}
 
 	return todos, nil
@@ -131,84 +321,358 @@ func getTodosHelper(functionName string, db *sql.DB, predicate string, filters .
 
 func (t *todoDB) GetTasks(tag string) ([]Todo, error) {
 	if tag != "" {
-		return getTodosHelper("GetTasks", t.db, "SELECT * FROM todos WHERE tag = ?", tag)
+		return getTodosHelper("GetTasks", t.conn(), "SELECT "+todoColumns+" FROM todos WHERE tag = ?", tag)
 	}
-	return getTodosHelper("GetTasks", t.db, "SELECT * FROM todos")
+	return getTodosHelper("GetTasks", t.conn(), "SELECT "+todoColumns+" FROM todos")
 }
 
-func (t *todoDB) GetFilteredTasksByState(state status, tag string) ([]Todo, error) {
+func (t *todoDB) GetFilteredTasksByState(state Status, tag string) ([]Todo, error) {
 	if tag != "" {
-		return getTodosHelper("GetFilteredTasksByState", t.db, "SELECT * FROM todos WHERE state = ? AND tag = ?", state, tag)
+		return getTodosHelper("GetFilteredTasksByState", t.conn(), "SELECT "+todoColumns+" FROM todos WHERE state = ? AND tag = ?", state, tag)
 	}
-	return getTodosHelper("GetFilteredTasksByState", t.db, "SELECT * FROM todos WHERE state = ?", state)
+	return getTodosHelper("GetFilteredTasksByState", t.conn(), "SELECT "+todoColumns+" FROM todos WHERE state = ?", state)
 }
 
 func (t *todoDB) GetFilteredTasksByCreationDate(time time.Time, tag string) ([]Todo, error) {
 	if tag != "" {
-		return getTodosHelper("GetFilteredTasksByCreationDate", t.db, "SELECT * FROM todos WHERE date(date_created) = date(?) AND tag = ?", time, tag)
+		return getTodosHelper("GetFilteredTasksByCreationDate", t.conn(), "SELECT "+todoColumns+" FROM todos WHERE date(date_created) = date(?) AND tag = ?", time, tag)
 	}
-	return getTodosHelper("GetFilteredTasksByCreationDate", t.db, "SELECT * FROM todos WHERE date(date_created) = date(?)", time)
+	return getTodosHelper("GetFilteredTasksByCreationDate", t.conn(), "SELECT "+todoColumns+" FROM todos WHERE date(date_created) = date(?)", time)
 }
 
-func (t *todoDB) GetFilteredTasksByStateAndDate(state status, time time.Time, tag string) ([]Todo, error) {
+func (t *todoDB) GetFilteredTasksByStateAndDate(state Status, time time.Time, tag string) ([]Todo, error) {
 	if tag != "" {
-		return getTodosHelper("GetFilteredTasksByState", t.db, "SELECT * FROM todos WHERE state = ? AND date(date_created) = date(?) AND tag = ?", state, time, tag)
+		return getTodosHelper("GetFilteredTasksByState", t.conn(), "SELECT "+todoColumns+" FROM todos WHERE state = ? AND date(date_created) = date(?) AND tag = ?", state, time, tag)
 	}
-	return getTodosHelper("GetFilteredTasksByState", t.db, "SELECT * FROM todos WHERE state = ? AND date(date_created) = date(?)", state, time)
+	return getTodosHelper("GetFilteredTasksByState", t.conn(), "SELECT "+todoColumns+" FROM todos WHERE state = ? AND date(date_created) = date(?)", state, time)
+}
+
+// Filter narrows the todos returned by GetTasksByFilter, the zero value matches
+// every todo. The dates are compared as instants, From is inclusive and To
+// exclusive, and the zero times are ignored. The todos without due or
+// completion date don't match the filters on those dates. Open and Closed
+// match the todos in the open or closed states of the workflow stored in the
+// database, they are read by the query so they don't depend on the workflow
+// loaded by NewTodoDB.
+type Filter struct {
+	IDs           []int
+	States        []Status
+	Open          bool
+	Closed        bool
+	Tag           string
+	CreatedFrom   time.Time
+	CreatedTo     time.Time
+	CompletedFrom time.Time
+	CompletedTo   time.Time
+	DueFrom       time.Time
+	DueTo         time.Time
+}
+
+func (t *todoDB) GetTasksByFilter(filter Filter) ([]Todo, error) {
+	conditions := []string{}
+	args := []any{}
+
+	if len(filter.IDs) > 0 {
+		conditions = append(conditions, "id IN ("+placeholders(len(filter.IDs))+")")
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+
+	if len(filter.States) > 0 {
+		conditions = append(conditions, "state IN ("+placeholders(len(filter.States))+")")
+		for _, state := range filter.States {
+			args = append(args, state)
+		}
+	}
+
+	for _, workflow := range []struct {
+		match  bool
+		closed bool
+	}{
+		{filter.Open, false},
+		{filter.Closed, true},
+	} {
+		if workflow.match {
+			conditions = append(conditions, "state IN (SELECT id FROM states WHERE closed = ?)")
+			args = append(args, workflow.closed)
+		}
+	}
+
+	if filter.Tag != "" {
+		conditions = append(conditions, "tag = ?")
+		args = append(args, filter.Tag)
+	}
+
+	for _, period := range []struct {
+		column   string
+		from, to time.Time
+	}{
+		{"date_created", filter.CreatedFrom, filter.CreatedTo},
+		{"date_completed", filter.CompletedFrom, filter.CompletedTo},
+		{"date_due", filter.DueFrom, filter.DueTo},
+	} {
+		if !period.from.IsZero() {
+			conditions = append(conditions, "julianday("+period.column+") >= julianday(?)")
+			args = append(args, period.from)
+		}
+
+		if !period.to.IsZero() {
+			conditions = append(conditions, "julianday("+period.column+") < julianday(?)")
+			args = append(args, period.to)
+		}
+	}
+
+	query := "SELECT " + todoColumns + " FROM todos"
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return getTodosHelper("GetTasksByFilter", t.conn(), query, args...)
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
 }
 
 func (t *todoDB) CreateTodo(title string, tag string) error {
-	_, err := t.db.Exec(`
-		INSERT INTO todos
-			(todo, state, tag, date_created)
-		VALUES
-			(?,?,?,?)
-	`, title, Pending, tag, time.Now())
+	_, err := t.AddTodo(Todo{Todo: title, State: Pending, Tag: tag, DateCreated: time.Now()})
 
 	return err
 }
 
-func (t *todoDB) CompleteTodo(todoId int) error {
-	var state status
+// AddTodo inserts the todo with all its fields but the id, which is returned.
+func (t *todoDB) AddTodo(todo Todo) (int, error) {
+	return insertTodo(t.conn(), todo)
+}
 
-	row := t.db.QueryRow("SELECT state FROM todos WHERE id = ?", todoId)
-	err := row.Scan(&state)
+// ImportTodos inserts the todos in a single transaction, none of them is
+// inserted when any fails. The ids of the new todos are returned.
+func (t *todoDB) ImportTodos(todos []Todo) ([]int, error) {
+	tx, err := t.begin()
 
 	if err != nil {
-		return err
+		return nil, storageError(err)
 	}
 
-	if state == Done {
-		return nil
+	defer tx.Rollback()
+
+	ids := make([]int, 0, len(todos))
+
+	for _, todo := range todos {
+		if !todo.State.Valid() {
+			return nil, fmt.Errorf("%w %d", ErrInvalidState, todo.State)
+		}
+
+		id, err := insertTodo(tx, todo)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
 	}
 
-	_, err = t.db.Exec(`
-		UPDATE todos SET state = ?, date_completed = ? WHERE id = ?
-	`, Done, time.Now(), todoId)
+	if err := tx.Commit(); err != nil {
+		return nil, storageError(err)
+	}
 
-	return err
+	return ids, nil
+}
+
+// insertTodo inserts the todo, it is modified now unless it says otherwise and
+// gets a new uid when it doesn't have one.
+func insertTodo(db queryExecer, todo Todo) (int, error) {
+	if todo.DateModified.IsZero() {
+		todo.DateModified = time.Now()
+	}
+
+	if todo.UID == "" {
+		uid, err := newUID()
+
+		if err != nil {
+			return 0, err
+		}
+
+		todo.UID = uid
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO todos
+			(todo, state, tag, date_created, date_completed, date_due, date_reviewed, priority, date_modified, uid)
+		VALUES
+			(?,?,?,?,?,?,?,?,?,?)
+	`, todo.Todo, todo.State, todo.Tag, todo.DateCreated, todo.DateCompleted, todo.DateDue, todo.DateReviewed, todo.Priority, todo.DateModified, todo.UID)
+
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	id, err := result.LastInsertId()
+
+	return int(id), storageError(err)
+}
+
+// newUID returns a random version 4 UUID.
+func newUID() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// queryExecer is implemented by both *sql.DB and *sql.Tx so the same statements
+// can change a single todo or many of them inside a transaction.
+type queryExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (t *todoDB) CompleteTodo(todoId int) error {
+	return t.MoveTodo(todoId, Done)
 }
 
 func (t *todoDB) UncompleteTodo(todoId int) error {
-	_, err := t.db.Exec(`
-		UPDATE todos SET state = ?, date_completed = null WHERE id = ?
-	`, Pending, todoId)
+	return t.MoveTodo(todoId, Pending)
+}
 
-	return err
+func (t *todoDB) DeleteTodo(todoId int) error {
+	return deleteTodo(t.conn(), todoId)
+}
+
+// Result is the outcome of a bulk operation for one of the todos.
+type Result struct {
+	ID  int
+	Err error
+}
+
+// CompleteTodos marks the todos as done in a single transaction, the todos
+// that couldn't be found are reported in the results and the rest are still
+// completed.
+func (t *todoDB) CompleteTodos(todoIds []int) ([]Result, error) {
+	return t.MoveTodos(todoIds, Done)
+}
+
+// UncompleteTodos marks the todos as pending in a single transaction.
+func (t *todoDB) UncompleteTodos(todoIds []int) ([]Result, error) {
+	return t.MoveTodos(todoIds, Pending)
+}
+
+// DeleteTodos deletes the todos in a single transaction.
+func (t *todoDB) DeleteTodos(todoIds []int) ([]Result, error) {
+	return t.bulk(todoIds, deleteTodo)
+}
+
+// bulk runs the action for every todo in a single transaction, a storage error
+// rolls back the whole transaction.
+func (t *todoDB) bulk(todoIds []int, action func(queryExecer, int) error) ([]Result, error) {
+	tx, err := t.begin()
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	results := make([]Result, 0, len(todoIds))
+
+	for _, id := range todoIds {
+		err := action(tx, id)
+
+		if errors.Is(err, ErrStorage) {
+			tx.Rollback()
+			return nil, err
+		}
+
+		results = append(results, Result{ID: id, Err: err})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, storageError(err)
+	}
+
+	return results, nil
 }
 
 func (t *todoDB) ChangeTodoName(todoId int, newName string) error {
-	_, err := t.db.Exec(`
-		UPDATE todos SET todo = ? WHERE id = ?
-	`, newName, todoId)
+	result, err := t.conn().Exec(`
+		UPDATE todos SET todo = ?, date_modified = ? WHERE id = ?
+	`, newName, time.Now(), todoId)
 
-	return err
+	return checkAffected(result, err)
 }
 
-func (t *todoDB) DeleteTodo(todoId int) error {
-	_, err := t.db.Exec(`
+func (t *todoDB) ChangeTodoTag(todoId int, tag string) error {
+	result, err := t.conn().Exec(`
+		UPDATE todos SET tag = ?, date_modified = ? WHERE id = ?
+	`, tag, time.Now(), todoId)
+
+	return checkAffected(result, err)
+}
+
+// MarkReviewed records that the todo has just been reviewed.
+func (t *todoDB) MarkReviewed(todoId int) error {
+	result, err := t.conn().Exec(`
+		UPDATE todos SET date_reviewed = ? WHERE id = ?
+	`, time.Now(), todoId)
+
+	return checkAffected(result, err)
+}
+
+// SetDueDate sets the date the todo is due, an invalid date removes it.
+func (t *todoDB) SetDueDate(todoId int, due sql.NullTime) error {
+	result, err := t.conn().Exec(`
+		UPDATE todos SET date_due = ?, date_modified = ? WHERE id = ?
+	`, due, time.Now(), todoId)
+
+	return checkAffected(result, err)
+}
+
+// UpdateTodo saves every field of the todo but the id and the review date, the
+// todo is modified now.
+func (t *todoDB) UpdateTodo(todo Todo) error {
+	if !todo.State.Valid() {
+		return fmt.Errorf("%w %d", ErrInvalidState, todo.State)
+	}
+
+	result, err := t.conn().Exec(`
+		UPDATE todos
+		SET todo = ?, state = ?, tag = ?, date_created = ?, date_completed = ?, date_due = ?, priority = ?, date_modified = ?
+		WHERE id = ?
+	`, todo.Todo, todo.State, todo.Tag, todo.DateCreated, todo.DateCompleted, todo.DateDue, todo.Priority, time.Now(), todo.ID)
+
+	return checkAffected(result, err)
+}
+
+func deleteTodo(db queryExecer, todoId int) error {
+	result, err := db.Exec(`
 		DELETE FROM todos WHERE id = ?
 	`, todoId)
 
-	return err
+	return checkAffected(result, err)
+}
+
+// checkAffected returns ErrNotFound when the statement didn't change any todo.
+func checkAffected(result sql.Result, err error) error {
+	if err != nil {
+		return storageError(err)
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
 }
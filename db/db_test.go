@@ -0,0 +1,507 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDB opens a database in a temporary home directory.
+func newTestDB(t *testing.T) *todoDB {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	todoDB, err := NewTodoDB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		todoDB.Close()
+		states = defaultStates
+	})
+
+	return todoDB
+}
+
+func addTestTodo(t *testing.T, todoDB *todoDB, todo Todo) int {
+	t.Helper()
+
+	if todo.DateCreated.IsZero() {
+		todo.DateCreated = time.Now()
+	}
+
+	id, err := todoDB.AddTodo(todo)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func getTestTodo(t *testing.T, todoDB *todoDB, id int) Todo {
+	t.Helper()
+
+	todos, err := todoDB.GetTasksByFilter(Filter{IDs: []int{id}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 1 {
+		t.Fatalf("todo %d not found", id)
+	}
+
+	return todos[0]
+}
+
+func TestNotFound(t *testing.T) {
+	todoDB := newTestDB(t)
+	id := addTestTodo(t, todoDB, Todo{Todo: "Buy milk"})
+
+	tests := []struct {
+		name   string
+		change func(id int) error
+	}{
+		{"ChangeTodoName", func(id int) error { return todoDB.ChangeTodoName(id, "Buy oat milk") }},
+		{"ChangeTodoTag", func(id int) error { return todoDB.ChangeTodoTag(id, "home") }},
+		{"MarkReviewed", todoDB.MarkReviewed},
+		{"SetDueDate", func(id int) error { return todoDB.SetDueDate(id, sql.NullTime{}) }},
+		{"UpdateTodo", func(id int) error { return todoDB.UpdateTodo(Todo{ID: id, Todo: "Buy milk"}) }},
+		{"CompleteTodo", todoDB.CompleteTodo},
+		{"DeleteTodo", todoDB.DeleteTodo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(id + 100); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s() of a missing todo = %v, want ErrNotFound", tt.name, err)
+			}
+
+			if err := tt.change(id); err != nil {
+				t.Errorf("%s() = %v, want nil", tt.name, err)
+			}
+		})
+	}
+}
+
+func TestStorageError(t *testing.T) {
+	todoDB := newTestDB(t)
+	todoDB.Close()
+
+	if _, err := todoDB.GetTasks(""); !errors.Is(err, ErrStorage) {
+		t.Errorf("GetTasks() of a closed database = %v, want ErrStorage", err)
+	}
+
+	if err := todoDB.ChangeTodoName(1, "Buy milk"); !errors.Is(err, ErrStorage) || errors.Is(err, ErrNotFound) {
+		t.Errorf("ChangeTodoName() of a closed database = %v, want ErrStorage", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if err := os.MkdirAll(filepath.Join(home, todoDirectory), 0o770); err != nil {
+		t.Fatal(err)
+	}
+
+	// A database made before the first migration.
+	old, err := sql.Open("sqlite3", "file:"+filepath.Join(home, todoDirectory, "todos.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&todoDB{db: old}).setupTodoSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	_, err = old.Exec(`
+		INSERT INTO todos (todo, state, tag, date_created) VALUES ('Buy milk', 0, 'home', ?), ('Call Ana', 0, '', ?)
+	`, created, created)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old.Close()
+
+	todoDB, err := NewTodoDB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		todoDB.Close()
+		states = defaultStates
+	})
+
+	var version int
+
+	if err := todoDB.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+
+	if version != len(migrations) {
+		t.Errorf("user_version = %d, want %d", version, len(migrations))
+	}
+
+	todos, err := todoDB.GetTasks("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 2 {
+		t.Fatalf("GetTasks() = %d todos, want 2", len(todos))
+	}
+
+	for _, todo := range todos {
+		if !todo.DateModified.Equal(created) {
+			t.Errorf("todo %d modified %v, want the creation date %v", todo.ID, todo.DateModified, created)
+		}
+
+		if len(todo.UID) != 36 || todo.UID[14] != '4' {
+			t.Errorf("todo %d uid = %q, want a version 4 UUID", todo.ID, todo.UID)
+		}
+	}
+
+	if todos[0].UID == todos[1].UID {
+		t.Errorf("both todos have the uid %q", todos[0].UID)
+	}
+
+	// Opening it again doesn't run the migrations twice.
+	todoDB.Close()
+
+	todoDB, err = NewTodoDB()
+
+	if err != nil {
+		t.Fatalf("NewTodoDB() of a migrated database: %v", err)
+	}
+
+	todoDB.Close()
+}
+
+func TestBulk(t *testing.T) {
+	todoDB := newTestDB(t)
+	first := addTestTodo(t, todoDB, Todo{Todo: "Buy milk"})
+	second := addTestTodo(t, todoDB, Todo{Todo: "Call Ana"})
+
+	results, err := todoDB.CompleteTodos([]int{first, 100, second})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Result{{ID: first}, {ID: 100, Err: ErrNotFound}, {ID: second}}
+
+	if len(results) != len(want) {
+		t.Fatalf("CompleteTodos() = %v, want %v", results, want)
+	}
+
+	for i, result := range results {
+		if result.ID != want[i].ID || !errors.Is(result.Err, want[i].Err) {
+			t.Errorf("CompleteTodos() result %d = %v, want %v", i, result, want[i])
+		}
+	}
+
+	for _, id := range []int{first, second} {
+		if todo := getTestTodo(t, todoDB, id); todo.State != Done {
+			t.Errorf("todo %d is %s, want done", id, todo.State)
+		}
+	}
+
+	// A storage error rolls back the changes made to the todos before it.
+	failure := errors.New("disk full")
+
+	_, err = todoDB.bulk([]int{first, second}, func(db queryExecer, id int) error {
+		if id == second {
+			return storageError(failure)
+		}
+
+		return deleteTodo(db, id)
+	})
+
+	if !errors.Is(err, ErrStorage) || !errors.Is(err, failure) {
+		t.Errorf("bulk() = %v, want the storage error", err)
+	}
+
+	getTestTodo(t, todoDB, first)
+}
+
+func TestGetTasksByFilterDates(t *testing.T) {
+	todoDB := newTestDB(t)
+	madrid := time.FixedZone("CET", 60*60)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// The dates are compared as instants, whatever zone they were saved in.
+	ids := map[string]int{}
+
+	for _, todo := range []Todo{
+		{Todo: "before", DateCreated: time.Date(2024, 2, 29, 23, 59, 0, 0, time.UTC)},
+		{Todo: "first instant", DateCreated: day},
+		{Todo: "late in Madrid", DateCreated: time.Date(2024, 3, 2, 0, 30, 0, 0, madrid)},
+		{Todo: "early in Madrid", DateCreated: time.Date(2024, 3, 1, 0, 30, 0, 0, madrid)},
+		{Todo: "next day", DateCreated: day.AddDate(0, 0, 1)},
+	} {
+		ids[todo.Todo] = addTestTodo(t, todoDB, todo)
+	}
+
+	due := addTestTodo(t, todoDB, Todo{Todo: "due", DateCreated: day, DateDue: sql.NullTime{Time: day.Add(12 * time.Hour), Valid: true}})
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int
+	}{
+		{"created that day", Filter{CreatedFrom: day, CreatedTo: day.AddDate(0, 0, 1)}, []int{ids["first instant"], ids["late in Madrid"], due}},
+		{"created from the day", Filter{CreatedFrom: day.AddDate(0, 0, 1)}, []int{ids["next day"]}},
+		{"created before the day", Filter{CreatedTo: day}, []int{ids["before"], ids["early in Madrid"]}},
+		{"due that day", Filter{DueFrom: day, DueTo: day.AddDate(0, 0, 1)}, []int{due}},
+		{"completed that day", Filter{CompletedFrom: day}, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos, err := todoDB.GetTasksByFilter(tt.filter)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []int{}
+			for _, todo := range todos {
+				got = append(got, todo.ID)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GetTasksByFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStates(t *testing.T) {
+	todoDB := newTestDB(t)
+
+	if err := todoDB.AddState("qa", 3, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := todoDB.AddState("archived", -1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, state := range States() {
+		names = append(names, state.Name)
+	}
+
+	want := "[backlog todo in-progress qa review blocked done cancelled archived]"
+
+	if fmt.Sprint(names) != want {
+		t.Errorf("States() = %v, want %v", names, want)
+	}
+
+	archived, err := ParseStatus("archived")
+
+	if err != nil || !archived.Closed() {
+		t.Errorf("ParseStatus(archived) = %v, %v, want a closed state", archived, err)
+	}
+
+	for _, name := range []string{"qa", "QA", "", "on hold"} {
+		if err := todoDB.AddState(name, 0, false); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("AddState(%q) = %v, want ErrInvalidState", name, err)
+		}
+	}
+
+	id := addTestTodo(t, todoDB, Todo{Todo: "Buy milk", State: archived})
+
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"todo", ErrInvalidState},
+		{"done", ErrInvalidState},
+		{"archived", ErrInvalidState},
+		{"unknown", ErrInvalidState},
+		{"qa", nil},
+	}
+
+	for _, tt := range tests {
+		if err := todoDB.RemoveState(tt.name); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("RemoveState(%q) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := ParseStatus("qa"); err == nil {
+		t.Error("qa is still a state after removing it")
+	}
+
+	// The workflow is read back when the database is opened again.
+	if err := todoDB.DeleteTodo(id); err != nil {
+		t.Fatal(err)
+	}
+
+	states = defaultStates
+
+	reopened, err := NewTodoDB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer reopened.Close()
+
+	if !archived.Valid() || !archived.Closed() {
+		t.Errorf("archived isn't a closed state after opening the database again")
+	}
+}
+
+func TestMoveTodo(t *testing.T) {
+	todoDB := newTestDB(t)
+	closed := sql.NullTime{Time: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), Valid: true}
+
+	tests := []struct {
+		name      string
+		from      Status
+		completed sql.NullTime
+		to        Status
+		// completion is "kept", "now" or "none".
+		completion string
+	}{
+		{"done", Pending, sql.NullTime{}, Done, "now"},
+		{"done again", Done, closed, Done, "kept"},
+		{"cancelled", InProgress, sql.NullTime{}, Cancelled, "none"},
+		{"done to cancelled", Done, closed, Cancelled, "kept"},
+		{"cancelled to done", Cancelled, closed, Done, "kept"},
+		{"cancelled without a date to done", Cancelled, sql.NullTime{}, Done, "now"},
+		{"reopened", Done, closed, Pending, "none"},
+		{"started", Pending, sql.NullTime{}, InProgress, "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := addTestTodo(t, todoDB, Todo{Todo: "Buy milk", State: tt.from, DateCompleted: tt.completed})
+			before := time.Now()
+
+			if err := todoDB.MoveTodo(id, tt.to); err != nil {
+				t.Fatal(err)
+			}
+
+			todo := getTestTodo(t, todoDB, id)
+
+			if todo.State != tt.to {
+				t.Errorf("state = %s, want %s", todo.State, tt.to)
+			}
+
+			switch completed := todo.DateCompleted; tt.completion {
+			case "kept":
+				if !completed.Valid || !completed.Time.Equal(closed.Time) {
+					t.Errorf("completed = %v, want %v", completed, closed.Time)
+				}
+			case "now":
+				if !completed.Valid || completed.Time.Before(before.Add(-time.Second)) {
+					t.Errorf("completed = %v, want now", completed)
+				}
+			case "none":
+				if completed.Valid {
+					t.Errorf("completed = %v, want none", completed.Time)
+				}
+			}
+		})
+	}
+
+	if err := todoDB.MoveTodo(100, Done); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MoveTodo() of a missing todo = %v, want ErrNotFound", err)
+	}
+
+	if err := todoDB.MoveTodo(1, Status(100)); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("MoveTodo() to a missing state = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestStateHooks(t *testing.T) {
+	todoDB := newTestDB(t)
+
+	var got [][]int
+
+	stateHooks = []StateHook{func(store SyncStore, todoIds []int) error {
+		got = append(got, todoIds)
+		return nil
+	}}
+
+	t.Cleanup(func() { stateHooks = nil })
+
+	first := addTestTodo(t, todoDB, Todo{Todo: "Buy milk"})
+	second := addTestTodo(t, todoDB, Todo{Todo: "Call Ana"})
+
+	if err := todoDB.CompleteTodo(first); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := todoDB.UncompleteTodos([]int{first, 100, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Inside a transaction the hooks wait for the commit, and don't run after
+	// a rollback.
+	tx, err := todoDB.Begin()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.MoveTodo(second, InProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Errorf("the hooks ran before the commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = todoDB.Begin()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.MoveTodo(first, Done); err != nil {
+		t.Fatal(err)
+	}
+
+	tx.Rollback()
+
+	want := fmt.Sprint([][]int{{first}, {first, second}, {second}})
+
+	if fmt.Sprint(got) != want {
+		t.Errorf("hooks ran with %v, want %v", got, want)
+	}
+
+	// The error of a hook is returned once the state changed.
+	failure := errors.New("the note couldn't be written")
+	stateHooks = []StateHook{func(SyncStore, []int) error { return failure }}
+
+	if err := todoDB.CompleteTodo(first); !errors.Is(err, failure) {
+		t.Errorf("CompleteTodo() = %v, want the error of the hook", err)
+	}
+
+	if todo := getTestTodo(t, todoDB, first); todo.State != Done {
+		t.Errorf("todo is %s after the hook failed, want done", todo.State)
+	}
+}
@@ -0,0 +1,346 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// State is a step of the workflow the todos go through, the states are stored
+// in the states table so new ones can be added. Todos in a closed state are
+// finished, either done or discarded.
+type State struct {
+	ID       Status
+	Name     string
+	Position int
+	Closed   bool
+}
+
+// The ids of the states every workflow has, todos are created as Pending and
+// CompleteTodo moves them to Done.
+const (
+	Pending Status = iota
+	Done
+	Backlog
+	InProgress
+	Review
+	Blocked
+	Cancelled
+)
+
+var defaultStates = []State{
+	{ID: Backlog, Name: "backlog", Position: 0},
+	{ID: Pending, Name: "todo", Position: 1},
+	{ID: InProgress, Name: "in-progress", Position: 2},
+	{ID: Review, Name: "review", Position: 3},
+	{ID: Blocked, Name: "blocked", Position: 4},
+	{ID: Done, Name: "done", Position: 5, Closed: true},
+	{ID: Cancelled, Name: "cancelled", Position: 6, Closed: true},
+}
+
+// states is the workflow loaded from the database by NewTodoDB, sorted by
+// position. Until then the default workflow is used, so the states have to be
+// parsed and checked after opening the database, and the queries use
+// Filter.Open and Filter.Closed instead of the states loaded.
+var states = defaultStates
+
+func (s Status) String() string {
+	if state, ok := s.State(); ok {
+		return state.Name
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// State returns the workflow state with this id, false if it doesn't exist.
+func (s Status) State() (State, bool) {
+	for _, state := range states {
+		if state.ID == s {
+			return state, true
+		}
+	}
+	return State{}, false
+}
+
+// Valid reports whether the state exists in the workflow.
+func (s Status) Valid() bool {
+	_, ok := s.State()
+	return ok
+}
+
+// Closed reports whether the todos in this state are finished.
+func (s Status) Closed() bool {
+	state, _ := s.State()
+	return state.Closed
+}
+
+// Position returns the place of the state in the workflow.
+func (s Status) Position() int {
+	state, _ := s.State()
+	return state.Position
+}
+
+// States returns the workflow states sorted by position.
+func States() []State {
+	return append([]State{}, states...)
+}
+
+// ParseStatus returns the state with the given name.
+func ParseStatus(name string) (Status, error) {
+	for _, state := range states {
+		if strings.EqualFold(state.Name, name) {
+			return state.ID, nil
+		}
+	}
+
+	names := []string{}
+	for _, state := range states {
+		names = append(names, state.Name)
+	}
+
+	return 0, fmt.Errorf("%w %q, use one of: %s", ErrInvalidState, name, strings.Join(names, ", "))
+}
+
+func (t *todoDB) setupStatesSchema() error {
+	_, err := t.conn().Exec(`
+		CREATE TABLE IF NOT EXISTS states (
+			id         INTEGER PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL UNIQUE,
+			position   INTEGER NOT NULL,
+			closed     BOOLEAN NOT NULL
+		);
+	`)
+
+	if err != nil {
+		return err
+	}
+
+	for _, state := range defaultStates {
+		_, err := t.conn().Exec(`
+			INSERT OR IGNORE INTO states
+				(id, name, position, closed)
+			VALUES
+				(?,?,?,?)
+		`, state.ID, state.Name, state.Position, state.Closed)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.loadStates()
+}
+
+// loadStates reads the workflow from the database.
+func (t *todoDB) loadStates() error {
+	rows, err := t.conn().Query("SELECT id, name, position, closed FROM states ORDER BY position, id")
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	loaded := []State{}
+
+	for rows.Next() {
+		var state State
+
+		if err := rows.Scan(&state.ID, &state.Name, &state.Position, &state.Closed); err != nil {
+			return err
+		}
+
+		loaded = append(loaded, state)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	states = loaded
+
+	return nil
+}
+
+// AddState adds a new state to the workflow at the given position, moving the
+// following states one place. A negative position adds it at the end.
+func (t *todoDB) AddState(name string, position int, closed bool) error {
+	name = strings.TrimSpace(name)
+
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("%w: the name of a state can't be empty nor contain spaces", ErrInvalidState)
+	}
+
+	if _, err := ParseStatus(name); err == nil {
+		return fmt.Errorf("%w: the state %q already exists", ErrInvalidState, name)
+	}
+
+	tx, err := t.begin()
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	defer tx.Rollback()
+
+	var id, last int
+
+	row := tx.QueryRow("SELECT max(id) + 1, max(position) + 1 FROM states")
+
+	if err := row.Scan(&id, &last); err != nil {
+		return storageError(err)
+	}
+
+	if position < 0 || position > last {
+		position = last
+	}
+
+	if _, err := tx.Exec("UPDATE states SET position = position + 1 WHERE position >= ?", position); err != nil {
+		return storageError(err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO states
+			(id, name, position, closed)
+		VALUES
+			(?,?,?,?)
+	`, id, name, position, closed)
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storageError(err)
+	}
+
+	return storageError(t.loadStates())
+}
+
+// RemoveState removes a state from the workflow, the states used by CreateTodo
+// and CompleteTodo and the ones that still have todos can't be removed.
+func (t *todoDB) RemoveState(name string) error {
+	state, err := ParseStatus(name)
+
+	if err != nil {
+		return err
+	}
+
+	if state == Pending || state == Done {
+		return fmt.Errorf("%w: the state %q can't be removed", ErrInvalidState, name)
+	}
+
+	var count int
+
+	if err := t.conn().QueryRow("SELECT count(*) FROM todos WHERE state = ?", state).Scan(&count); err != nil {
+		return storageError(err)
+	}
+
+	if count > 0 {
+		return fmt.Errorf("%w: the state %q still has %d todos", ErrInvalidState, name, count)
+	}
+
+	if _, err := t.conn().Exec("DELETE FROM states WHERE id = ?", state); err != nil {
+		return storageError(err)
+	}
+
+	return storageError(t.loadStates())
+}
+
+// CountByState returns how many todos are in every state.
+func (t *todoDB) CountByState() (map[Status]int, error) {
+	rows, err := t.conn().Query("SELECT state, count(*) FROM todos GROUP BY state")
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	defer rows.Close()
+
+	counts := map[Status]int{}
+
+	for rows.Next() {
+		var state Status
+		var count int
+
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, storageError(err)
+		}
+
+		counts[state] = count
+	}
+
+	return counts, storageError(rows.Err())
+}
+
+func (t *todoDB) MoveTodo(todoId int, state Status) error {
+	if err := moveTodo(t.conn(), todoId, state); err != nil {
+		return err
+	}
+
+	return t.stateChanged([]int{todoId})
+}
+
+// MoveTodos moves the todos to the state in a single transaction.
+func (t *todoDB) MoveTodos(todoIds []int, state Status) ([]Result, error) {
+	results, err := t.bulk(todoIds, func(db queryExecer, todoId int) error {
+		return moveTodo(db, todoId, state)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	moved := []int{}
+
+	for _, result := range results {
+		if result.Err == nil {
+			moved = append(moved, result.ID)
+		}
+	}
+
+	return results, t.stateChanged(moved)
+}
+
+// moveTodo changes the state of the todo, the completion date is set when it
+// is moved to Done, kept when it moves between closed states, like from Done to
+// Cancelled, and cleared when it is opened again. Moving a todo to the state
+// it already is in doesn't change it, so completing a done todo again works.
+func moveTodo(db queryExecer, todoId int, state Status) error {
+	if !state.Valid() {
+		return fmt.Errorf("%w %d", ErrInvalidState, state)
+	}
+
+	var current Status
+	var completed sql.NullTime
+
+	err := db.QueryRow("SELECT state, date_completed FROM todos WHERE id = ?", todoId).Scan(&current, &completed)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	if current == state {
+		return nil
+	}
+
+	switch {
+	case state.Closed() && current.Closed() && completed.Valid:
+		// The todo keeps the date it was closed.
+	case state == Done:
+		completed = sql.NullTime{Time: time.Now(), Valid: true}
+	default:
+		completed = sql.NullTime{}
+	}
+
+	_, err = db.Exec(`
+		UPDATE todos SET state = ?, date_completed = ?, date_modified = ? WHERE id = ?
+	`, state, completed, time.Now(), todoId)
+
+	return storageError(err)
+}
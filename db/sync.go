@@ -0,0 +1,172 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// SyncItem links a todo to its copy in a source it is synced with, like a
+// todo.txt file or a CalDAV calendar. Snapshot is how the todo looked after the
+// last sync, a change is detected comparing it with the todo, and ETag is the
+// version of the remote copy when the source has them.
+type SyncItem struct {
+	TodoID   int
+	RemoteID string
+	Snapshot string
+	ETag     string
+}
+
+// SyncItems returns the todos synced with the source in the last sync.
+func (t *todoDB) SyncItems(source string) ([]SyncItem, error) {
+	rows, err := t.conn().Query(`
+		SELECT todo_id, remote_id, snapshot, etag FROM sync_items WHERE source = ? ORDER BY todo_id
+	`, source)
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	defer rows.Close()
+
+	items := []SyncItem{}
+
+	for rows.Next() {
+		var item SyncItem
+
+		if err := rows.Scan(&item.TodoID, &item.RemoteID, &item.Snapshot, &item.ETag); err != nil {
+			return nil, storageError(err)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, storageError(rows.Err())
+}
+
+// SaveSyncItems replaces the todos synced with the source in a single
+// transaction.
+func (t *todoDB) SaveSyncItems(source string, items []SyncItem) error {
+	tx, err := t.begin()
+
+	if err != nil {
+		return storageError(err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM sync_items WHERE source = ?", source); err != nil {
+		return storageError(err)
+	}
+
+	for _, item := range items {
+		_, err := tx.Exec(`
+			INSERT INTO sync_items
+				(source, todo_id, remote_id, snapshot, etag)
+			VALUES
+				(?,?,?,?,?)
+		`, source, item.TodoID, item.RemoteID, item.Snapshot, item.ETag)
+
+		if err != nil {
+			return storageError(err)
+		}
+	}
+
+	return storageError(tx.Commit())
+}
+
+// SyncToken returns the token the source gave in the last sync to ask only for
+// the changes made since, empty when there is none.
+func (t *todoDB) SyncToken(source string) (string, error) {
+	var token string
+
+	err := t.conn().QueryRow("SELECT token FROM sync_tokens WHERE source = ?", source).Scan(&token)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return token, storageError(err)
+}
+
+// SaveSyncToken saves the token of the source, an empty one removes it.
+func (t *todoDB) SaveSyncToken(source, token string) error {
+	if token == "" {
+		_, err := t.conn().Exec("DELETE FROM sync_tokens WHERE source = ?", source)
+		return storageError(err)
+	}
+
+	_, err := t.conn().Exec(`
+		INSERT INTO sync_tokens (source, token) VALUES (?,?)
+		ON CONFLICT (source) DO UPDATE SET token = excluded.token
+	`, source, token)
+
+	return storageError(err)
+}
+
+// SyncSources returns the sources the todos were synced with.
+func (t *todoDB) SyncSources() ([]string, error) {
+	rows, err := t.conn().Query("SELECT DISTINCT source FROM sync_items ORDER BY source")
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	defer rows.Close()
+
+	sources := []string{}
+
+	for rows.Next() {
+		var source string
+
+		if err := rows.Scan(&source); err != nil {
+			return nil, storageError(err)
+		}
+
+		sources = append(sources, source)
+	}
+
+	return sources, storageError(rows.Err())
+}
+
+// SyncStore is the part of the database the state hooks use to update the
+// sources the todos are synced with.
+type SyncStore interface {
+	GetTasksByFilter(filter Filter) ([]Todo, error)
+	SyncSources() ([]string, error)
+	SyncItems(source string) ([]SyncItem, error)
+	SaveSyncItems(source string, items []SyncItem) error
+}
+
+// StateHook is run with the ids of the todos whose state changed, like the
+// ones completed with CompleteTodo, so a sync can copy the change to its
+// source without waiting for the next sync.
+type StateHook func(store SyncStore, todoIds []int) error
+
+var stateHooks []StateHook
+
+// OnStateChange adds a hook run every time todos change state, after the
+// change is committed.
+func OnStateChange(hook StateHook) {
+	stateHooks = append(stateHooks, hook)
+}
+
+// stateChanged runs the state hooks for the todos, inside a transaction they
+// wait until it is committed.
+func (t *todoDB) stateChanged(todoIds []int) error {
+	if len(todoIds) == 0 {
+		return nil
+	}
+
+	if t.tx != nil {
+		t.moved = append(t.moved, todoIds...)
+		return nil
+	}
+
+	var errs []error
+
+	for _, hook := range stateHooks {
+		errs = append(errs, hook(t, todoIds))
+	}
+
+	return errors.Join(errs...)
+}
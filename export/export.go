@@ -0,0 +1,193 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"todo/db"
+	"todo/ical"
+	"todo/org"
+	"todo/output"
+	"todo/taskwarrior"
+	"todo/todotxt"
+)
+
+type Format string
+
+const (
+	JSON        Format = "json"
+	CSV         Format = "csv"
+	Markdown    Format = "markdown"
+	TodoTxt     Format = "todotxt"
+	ICS         Format = "ics"
+	Taskwarrior Format = "taskwarrior"
+	Org         Format = "org"
+)
+
+var formats = []Format{JSON, CSV, Markdown, TodoTxt, ICS, Taskwarrior, Org}
+
+// ParseFormat parses the value of the --format flag of the export.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+
+	names := []string{}
+	for _, f := range formats {
+		names = append(names, string(f))
+	}
+
+	return "", errors.New("Not valid format, use one of: " + strings.Join(names, ", "))
+}
+
+// CSVHeader are the columns of the csv export, in this order. The dates use
+// RFC 3339 and are empty when the todo doesn't have them.
+var CSVHeader = []string{"id", "todo", "state", "tag", "date_created", "date_completed", "date_due", "date_reviewed", "priority", "uid"}
+
+// Write writes the todos in the format. The json export is an array of
+// output.Record, the csv one has a line for every todo with the CSVHeader
+// columns, the markdown one is a checklist grouped by tag, the todotxt one
+// has a todo.txt line for every todo, the ics one is an iCalendar with a
+// VTODO for every todo, the taskwarrior one is what `task import` reads and
+// the org one has an Org headline for every todo.
+func Write(w io.Writer, format Format, todos []db.Todo) error {
+	switch format {
+	case JSON:
+		return writeJSON(w, todos)
+	case CSV:
+		return writeCSV(w, todos)
+	case Markdown:
+		return writeMarkdown(w, todos)
+	case TodoTxt:
+		return todotxt.Write(w, todos)
+	case ICS:
+		return ical.Write(w, todos)
+	case Taskwarrior:
+		return taskwarrior.Write(w, todos)
+	case Org:
+		return org.Write(w, todos)
+	default:
+		return fmt.Errorf("format %q can't be exported", format)
+	}
+}
+
+func writeJSON(w io.Writer, todos []db.Todo) error {
+	records := []output.Record{}
+
+	for _, todo := range todos {
+		records = append(records, output.NewRecord(todo))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(records)
+}
+
+func writeCSV(w io.Writer, todos []db.Todo) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(CSVHeader); err != nil {
+		return err
+	}
+
+	for _, todo := range todos {
+		record := output.NewRecord(todo)
+
+		err := writer.Write([]string{
+			strconv.Itoa(record.ID),
+			record.Todo,
+			record.State,
+			record.Tag,
+			record.DateCreated.Format(time.RFC3339Nano),
+			formatTime(record.DateCompleted),
+			formatTime(record.DateDue),
+			formatTime(record.DateReviewed),
+			record.Priority,
+			record.UID,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// writeMarkdown writes a checklist for every tag, the tags sorted by name and
+// the todos without tag last. The closed todos are checked and the ones closed
+// without being done are also struck through.
+func writeMarkdown(w io.Writer, todos []db.Todo) error {
+	tags := map[string][]db.Todo{}
+
+	for _, todo := range todos {
+		tags[todo.Tag] = append(tags[todo.Tag], todo)
+	}
+
+	names := []string{}
+	for tag := range tags {
+		names = append(names, tag)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "" || names[j] == "" {
+			return names[j] == ""
+		}
+		return names[i] < names[j]
+	})
+
+	for i, tag := range names {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		title := tag
+		if title == "" {
+			title = "No tag"
+		}
+
+		fmt.Fprintf(w, "## %s\n\n", title)
+
+		for _, todo := range tags[tag] {
+			check := " "
+			if todo.State.Closed() {
+				check = "x"
+			}
+
+			text := todo.Todo
+			if todo.State.Closed() && todo.State != db.Done {
+				text = "~~" + text + "~~"
+			}
+
+			line := fmt.Sprintf("- [%s] %s", check, text)
+
+			if todo.DateDue.Valid {
+				line += fmt.Sprintf(" (due %s)", todo.DateDue.Time.Format("2006-01-02"))
+			}
+
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+var update = flag.Bool("update", false, "write the golden files of the export")
+
+// goldenTodos have every field set and missing, with dates in other zones than
+// UTC so the offsets are checked too.
+func goldenTodos() []db.Todo {
+	madrid := time.FixedZone("CET", 60*60)
+	created := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+
+	return []db.Todo{
+		{
+			ID:            1,
+			Todo:          "Fix the login",
+			State:         db.Done,
+			Tag:           "web",
+			DateCreated:   created,
+			DateCompleted: sql.NullTime{Time: time.Date(2024, 1, 5, 10, 30, 15, 500000000, madrid), Valid: true},
+			DateDue:       sql.NullTime{Time: time.Date(2024, 1, 10, 0, 0, 0, 0, madrid), Valid: true},
+			DateReviewed:  sql.NullTime{Time: time.Date(2024, 1, 3, 8, 0, 0, 0, time.UTC), Valid: true},
+			Priority:      "B",
+			UID:           "5f0c2b1e-8d4a-4c3e-9b7f-2a6d1e0c9f31",
+		},
+		{
+			ID:          2,
+			Todo:        `Write "the notes", then ship`,
+			State:       db.Pending,
+			DateCreated: created.Add(time.Hour),
+			UID:         "0b6b1a2c-3d4e-4f50-8a6b-7c8d9e0f1a2b",
+		},
+	}
+}
+
+func TestWriteGolden(t *testing.T) {
+	for _, tt := range []struct {
+		format Format
+		golden string
+	}{
+		{JSON, "todos.json"},
+		{CSV, "todos.csv"},
+	} {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var b bytes.Buffer
+
+			if err := Write(&b, tt.format, goldenTodos()); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", tt.golden)
+
+			if *update {
+				if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := b.String(); got != string(want) {
+				t.Errorf("Write(%s) =\n%s\nwant\n%s", tt.format, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package ical
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"todo/db"
+)
+
+// prodID identifies the app that made the calendar.
+const prodID = "-//todo//todo//EN"
+
+// stateProperty keeps the state of the todos whose STATUS isn't enough to tell
+// it, like the backlog or blocked ones.
+const stateProperty = "X-TODO-STATE"
+
+const (
+	utcLayout   = "20060102T150405Z"
+	localLayout = "20060102T150405"
+	dateLayout  = "20060102"
+)
+
+// maxLineLength is the length in bytes the lines are folded at, RFC 5545
+// section 3.1.
+const maxLineLength = 75
+
+// Write writes an iCalendar with a VTODO for every todo, the tag is its
+// category and the priorities A to I are the PRIORITY 1 to 9.
+func Write(w io.Writer, todos []db.Todo) error {
+	bw := bufio.NewWriter(w)
+
+	line := func(name, value string) {
+		writeLine(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", prodID)
+
+	for _, todo := range todos {
+		modified := todo.DateModified
+		if modified.IsZero() {
+			modified = todo.DateCreated
+		}
+
+		line("BEGIN", "VTODO")
+		line("UID", escape(todo.UID))
+		line("DTSTAMP", modified.UTC().Format(utcLayout))
+		line("CREATED", todo.DateCreated.UTC().Format(utcLayout))
+		line("LAST-MODIFIED", modified.UTC().Format(utcLayout))
+		line("SUMMARY", escape(todo.Todo))
+
+		status := statusOf(todo.State)
+		line("STATUS", status)
+
+		if stateOf(status) != todo.State {
+			line(stateProperty, escape(todo.State.String()))
+		}
+
+		if todo.DateCompleted.Valid {
+			line("COMPLETED", todo.DateCompleted.Time.UTC().Format(utcLayout))
+		}
+
+		if todo.DateDue.Valid {
+			line("DUE;VALUE=DATE", todo.DateDue.Time.Local().Format(dateLayout))
+		}
+
+		if todo.Tag != "" {
+			line("CATEGORIES", escape(todo.Tag))
+		}
+
+		if todo.Priority != "" {
+			line("PRIORITY", strconv.Itoa(min(int(todo.Priority[0]-'A')+1, 9)))
+		}
+
+		line("END", "VTODO")
+	}
+
+	line("END", "VCALENDAR")
+
+	return bw.Flush()
+}
+
+// writeLine writes a content line folded at maxLineLength bytes, without
+// splitting a character.
+func writeLine(w *bufio.Writer, s string) {
+	limit := maxLineLength
+
+	for len(s) > limit {
+		cut := limit
+		for !isRuneStart(s[cut]) {
+			cut--
+		}
+
+		w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// The space starting the next lines counts for their length.
+		limit = maxLineLength - 1
+	}
+
+	w.WriteString(s + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xc0 != 0x80
+}
+
+// statusOf returns the STATUS of the todos in the state.
+func statusOf(state db.Status) string {
+	switch {
+	case state == db.InProgress:
+		return "IN-PROCESS"
+	case state == db.Done:
+		return "COMPLETED"
+	case state.Closed():
+		return "CANCELLED"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+// stateOf returns the state of the todos with the STATUS.
+func stateOf(status string) db.Status {
+	switch status {
+	case "IN-PROCESS":
+		return db.InProgress
+	case "COMPLETED":
+		return db.Done
+	case "CANCELLED":
+		return db.Cancelled
+	default:
+		return db.Pending
+	}
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
+
+// property is a content line, the parameters are only the ones used.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Read reads the VTODO components of an iCalendar, the events and the rest of
+// components are skipped. The creation date is zero when the task doesn't
+// have one.
+func Read(r io.Reader) ([]db.Todo, error) {
+	lines, err := unfold(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []db.Todo{}
+
+	var todo *db.Todo
+	var status, state string
+	// nested counts the components open inside the VTODO, like its alarms.
+	nested := 0
+
+	for n, line := range lines {
+		p, ok := parseProperty(line)
+
+		if !ok {
+			return nil, fmt.Errorf("line %d: Not valid iCalendar line %q", n+1, line)
+		}
+
+		switch {
+		case p.name == "BEGIN" && todo == nil && strings.EqualFold(p.value, "VTODO"):
+			todo = &db.Todo{}
+			status, state = "", ""
+		case todo == nil:
+		case p.name == "BEGIN":
+			nested++
+		case p.name == "END" && nested > 0:
+			nested--
+		case nested > 0:
+		case p.name == "END":
+			if err := finish(todo, status, state); err != nil {
+				return nil, fmt.Errorf("task %d: %w", len(todos)+1, err)
+			}
+
+			todos = append(todos, *todo)
+			todo = nil
+		case p.name == "STATUS":
+			status = strings.ToUpper(p.value)
+		case p.name == stateProperty:
+			state = unescape(p.value)
+		default:
+			if err := setProperty(todo, p); err != nil {
+				return nil, fmt.Errorf("task %d: %w", len(todos)+1, err)
+			}
+		}
+	}
+
+	return todos, nil
+}
+
+// finish sets the state of the todo, the one of stateProperty when there is
+// one and the one of the STATUS otherwise.
+func finish(todo *db.Todo, status, state string) error {
+	if todo.Todo == "" {
+		return errors.New("the SUMMARY is empty")
+	}
+
+	todo.State = stateOf(status)
+
+	if status == "" && todo.DateCompleted.Valid {
+		todo.State = db.Done
+	}
+
+	// The workflow may not have the state of the status.
+	if !todo.State.Valid() {
+		todo.State = db.Pending
+
+		if status == "CANCELLED" {
+			todo.State = db.Done
+		}
+	}
+
+	if state != "" {
+		s, err := db.ParseStatus(state)
+
+		if err != nil {
+			return err
+		}
+
+		todo.State = s
+	}
+
+	if !todo.State.Closed() {
+		todo.DateCompleted = sql.NullTime{}
+	}
+
+	return nil
+}
+
+func setProperty(todo *db.Todo, p property) error {
+	var err error
+
+	switch p.name {
+	case "UID":
+		todo.UID = unescape(p.value)
+	case "SUMMARY":
+		todo.Todo = strings.TrimSpace(unescape(p.value))
+	case "CATEGORIES":
+		todo.Tag = strings.TrimSpace(unescape(splitList(p.value)[0]))
+	case "PRIORITY":
+		priority, err := strconv.Atoi(p.value)
+
+		if err != nil || priority < 0 || priority > 9 {
+			return fmt.Errorf("Not valid PRIORITY %q", p.value)
+		}
+
+		if priority > 0 {
+			todo.Priority = string(rune('A' + priority - 1))
+		}
+	case "CREATED":
+		todo.DateCreated, err = parseTime(p)
+	case "LAST-MODIFIED":
+		todo.DateModified, err = parseTime(p)
+	case "COMPLETED":
+		todo.DateCompleted.Time, err = parseTime(p)
+		todo.DateCompleted.Valid = err == nil
+	case "DUE":
+		todo.DateDue.Time, err = parseTime(p)
+		todo.DateDue.Valid = err == nil
+	}
+
+	return err
+}
+
+// splitList splits a value at the commas that aren't escaped.
+func splitList(s string) []string {
+	values := []string{}
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(values, s[start:])
+}
+
+// parseTime parses a date, a time in UTC or a time in the time zone of the
+// TZID parameter, the local one when there is no TZID or it is unknown.
+func parseTime(p property) (time.Time, error) {
+	location := time.Local
+
+	if tzid, ok := p.params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			location = l
+		}
+	}
+
+	for _, layout := range []string{utcLayout, localLayout, dateLayout} {
+		if len(layout) != len(p.value) {
+			continue
+		}
+
+		// The Z of the layout is a literal, it doesn't tell the time zone.
+		if layout == utcLayout {
+			location = time.UTC
+		}
+
+		if t, err := time.ParseInLocation(layout, p.value, location); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Not valid %s %q", p.name, p.value)
+}
+
+// unfold returns the content lines, joining the ones folded, RFC 5545 section
+// 3.1. The blank lines are skipped.
+func unfold(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseProperty splits a content line in its name, parameters and value, the
+// colons and semicolons inside quoted parameters don't count.
+func parseProperty(line string) (property, bool) {
+	p := property{params: map[string]string{}}
+	quoted := false
+	parts := []string{}
+	start := 0
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ';', ':':
+			if quoted {
+				continue
+			}
+
+			parts = append(parts, line[start:i])
+			start = i + 1
+
+			if line[i] == ':' {
+				p.name = strings.ToUpper(parts[0])
+				p.value = line[start:]
+
+				for _, param := range parts[1:] {
+					name, value, _ := strings.Cut(param, "=")
+					p.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+				}
+
+				return p, p.name != ""
+			}
+		}
+	}
+
+	return p, false
+}
@@ -0,0 +1,219 @@
+package ical
+
+import (
+	"bytes"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"todo/db"
+	"unicode/utf8"
+)
+
+func TestWriteFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+	}{
+		{name: "short", title: "Buy milk"},
+		{name: "long", title: strings.Repeat("abcdefghij", 20)},
+		{name: "multibyte", title: strings.Repeat("ñandú €", 30)},
+		{name: "escaped", title: strings.Repeat(`a,b;c\d`, 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			todo := db.Todo{Todo: tt.title, State: db.Pending, DateCreated: time.Now()}
+
+			if err := Write(&b, []db.Todo{todo}); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, line := range strings.SplitAfter(b.String(), "\r\n") {
+				line = strings.TrimSuffix(line, "\r\n")
+
+				if len(line) > maxLineLength {
+					t.Errorf("line of %d bytes %q, want at most %d", len(line), line, maxLineLength)
+				}
+
+				if !utf8.ValidString(line) {
+					t.Errorf("line %q splits a character", line)
+				}
+			}
+
+			todos, err := Read(&b)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(todos) != 1 || todos[0].Todo != tt.title {
+				t.Errorf("Read(Write()) = %+v, want the title %q", todos, tt.title)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		s, escaped string
+	}{
+		{s: "plain", escaped: "plain"},
+		{s: "a,b;c", escaped: `a\,b\;c`},
+		{s: `back\slash`, escaped: `back\\slash`},
+		{s: "two\nlines", escaped: `two\nlines`},
+		{s: `\n is not a newline`, escaped: `\\n is not a newline`},
+	}
+
+	for _, tt := range tests {
+		if got := escape(tt.s); got != tt.escaped {
+			t.Errorf("escape(%q) = %q, want %q", tt.s, got, tt.escaped)
+		}
+
+		if got := unescape(tt.escaped); got != tt.s {
+			t.Errorf("unescape(%q) = %q, want %q", tt.escaped, got, tt.s)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	completed := sql.NullTime{Time: time.Date(2024, 3, 2, 18, 0, 0, 0, time.UTC), Valid: true}
+	// The due dates are saved at the local midnight, which may be another day
+	// in UTC.
+	due := sql.NullTime{Time: time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local).UTC(), Valid: true}
+
+	todos := []db.Todo{
+		{UID: "a", Todo: "Pending, with; separators", Tag: "work, home", State: db.Pending, Priority: "B", DateCreated: created, DateModified: created, DateDue: due},
+		{UID: "b", Todo: "Done", State: db.Done, DateCreated: created, DateModified: created, DateCompleted: completed},
+		{UID: "c", Todo: "In progress", State: db.InProgress, DateCreated: created, DateModified: created},
+		{UID: "d", Todo: "Blocked", State: db.Blocked, DateCreated: created, DateModified: created},
+		{UID: "e", Todo: "Cancelled", State: db.Cancelled, DateCreated: created, DateModified: created, DateCompleted: completed},
+	}
+
+	var b bytes.Buffer
+
+	if err := Write(&b, todos); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := Read(&b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read) != len(todos) {
+		t.Fatalf("Read(Write()) has %d todos, want %d", len(read), len(todos))
+	}
+
+	for i := range todos {
+		got, want := read[i], todos[i]
+
+		// The times are read in UTC and the due date at midnight.
+		if !got.DateCreated.Equal(want.DateCreated) || !got.DateModified.Equal(want.DateModified) ||
+			got.DateCompleted.Valid != want.DateCompleted.Valid || !got.DateCompleted.Time.Equal(want.DateCompleted.Time) ||
+			got.DateDue.Valid != want.DateDue.Valid || !got.DateDue.Time.Equal(want.DateDue.Time) {
+			t.Errorf("todo %d dates = %+v, want %+v", i, got, want)
+		}
+
+		got.DateCreated, got.DateModified, got.DateCompleted, got.DateDue = want.DateCreated, want.DateModified, want.DateCompleted, want.DateDue
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("todo %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+
+	if err != nil {
+		t.Skip("the time zone database isn't available")
+	}
+
+	tests := []struct {
+		name    string
+		ics     string
+		want    []db.Todo
+		wantErr bool
+	}{
+		{
+			name: "folded and escaped",
+			ics: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:1\r\nSUMMARY:Call Ana\\, Luis\r\n  and \\;others\\nlater\r\n" +
+				"CATEGORIES:home,work\r\nPRIORITY:1\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+			want: []db.Todo{{UID: "1", Todo: "Call Ana, Luis and ;others\nlater", Tag: "home", Priority: "A", State: db.Pending}},
+		},
+		{
+			name: "time zones and dates",
+			ics: "BEGIN:VTODO\nSUMMARY:a\nCREATED;TZID=Europe/Madrid:20240301T100000\nDUE;VALUE=DATE:20240310\n" +
+				"COMPLETED:20240302T080000Z\nSTATUS:COMPLETED\nEND:VTODO\n",
+			want: []db.Todo{{
+				Todo:          "a",
+				State:         db.Done,
+				DateCreated:   time.Date(2024, 3, 1, 10, 0, 0, 0, madrid),
+				DateDue:       sql.NullTime{Time: time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local), Valid: true},
+				DateCompleted: sql.NullTime{Time: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC), Valid: true},
+			}},
+		},
+		{
+			name: "alarms and events skipped",
+			ics: "BEGIN:VEVENT\nSUMMARY:event\nEND:VEVENT\nBEGIN:VTODO\nSUMMARY:task\nBEGIN:VALARM\nSUMMARY:alarm\n" +
+				"END:VALARM\nEND:VTODO\n",
+			want: []db.Todo{{Todo: "task", State: db.Pending}},
+		},
+		{
+			name: "completion without status",
+			ics:  "BEGIN:VTODO\nSUMMARY:a\nCOMPLETED:20240302T080000Z\nEND:VTODO\n",
+			want: []db.Todo{{Todo: "a", State: db.Done, DateCompleted: sql.NullTime{Time: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC), Valid: true}}},
+		},
+		{
+			name: "state property",
+			ics:  "BEGIN:VTODO\nSUMMARY:a\nSTATUS:NEEDS-ACTION\nX-TODO-STATE:blocked\nEND:VTODO\n",
+			want: []db.Todo{{Todo: "a", State: db.Blocked}},
+		},
+		{name: "empty summary", ics: "BEGIN:VTODO\nSUMMARY: \nEND:VTODO\n", wantErr: true},
+		{name: "not valid priority", ics: "BEGIN:VTODO\nSUMMARY:a\nPRIORITY:10\nEND:VTODO\n", wantErr: true},
+		{name: "not valid date", ics: "BEGIN:VTODO\nSUMMARY:a\nDUE:2024-03-10\nEND:VTODO\n", wantErr: true},
+		{name: "not valid line", ics: "BEGIN:VTODO\nSUMMARY\nEND:VTODO\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos, err := Read(strings.NewReader(tt.ics))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(todos) != len(tt.want) {
+				t.Fatalf("Read() = %+v, want %+v", todos, tt.want)
+			}
+
+			for i := range todos {
+				if !equal(todos[i], tt.want[i]) {
+					t.Errorf("Read()[%d] = %+v, want %+v", i, todos[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// equal compares the todos with the times compared as instants.
+func equal(a, b db.Todo) bool {
+	if !a.DateCreated.Equal(b.DateCreated) || a.DateCompleted.Valid != b.DateCompleted.Valid || !a.DateCompleted.Time.Equal(b.DateCompleted.Time) ||
+		a.DateDue.Valid != b.DateDue.Valid || !a.DateDue.Time.Equal(b.DateDue.Time) {
+		return false
+	}
+
+	a.DateCreated, a.DateCompleted, a.DateDue = b.DateCreated, b.DateCompleted, b.DateDue
+
+	return reflect.DeepEqual(a, b)
+}
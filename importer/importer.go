@@ -0,0 +1,414 @@
+package importer
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"todo/db"
+	"todo/ical"
+	"todo/org"
+	"todo/taskwarrior"
+	"todo/todotxt"
+)
+
+type Format string
+
+const (
+	JSON        Format = "json"
+	CSV         Format = "csv"
+	TodoTxt     Format = "todotxt"
+	ICS         Format = "ics"
+	Taskwarrior Format = "taskwarrior"
+	Org         Format = "org"
+)
+
+var formats = []Format{JSON, CSV, TodoTxt, ICS, Taskwarrior, Org}
+
+// DetectFormat returns the format of the --format flag or, when it is empty,
+// the one of the extension of the file, the .txt files are todo.txt ones.
+func DetectFormat(path, flag string) (Format, error) {
+	name := flag
+
+	if name == "" {
+		name = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	if name == "txt" {
+		name = string(TodoTxt)
+	}
+
+	for _, f := range formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+
+	names := []string{}
+	for _, f := range formats {
+		names = append(names, string(f))
+	}
+
+	return "", fmt.Errorf("Not valid format %q, use --format with one of: %s", name, strings.Join(names, ", "))
+}
+
+// fields are the fields of a todo that can be imported, the names used by the
+// export.
+var fields = []string{"todo", "state", "tag", "date_created", "date_completed", "date_due", "date_reviewed", "priority", "uid"}
+
+// Mapping maps the fields of a todo to the columns of the csv file, or the keys
+// of the json objects, they are read from. The fields not mapped are read from
+// the column with their own name.
+type Mapping map[string]string
+
+// ParseMapping parses the values of the --map flag, field=column.
+func ParseMapping(values []string) (Mapping, error) {
+	mapping := Mapping{}
+
+	for _, value := range values {
+		field, column, ok := strings.Cut(value, "=")
+
+		if !ok || column == "" {
+			return nil, fmt.Errorf("Not valid map %q, use field=column", value)
+		}
+
+		if !validField(field) {
+			return nil, fmt.Errorf("Not valid field %q, use one of: %s", field, strings.Join(fields, ", "))
+		}
+
+		mapping[field] = column
+	}
+
+	return mapping, nil
+}
+
+func validField(field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Mapping) column(field string) string {
+	if column, ok := m[field]; ok {
+		return column
+	}
+	return field
+}
+
+// Read reads the todos of an export, or of any file with the columns given
+// by the mapping. The dates and the state are kept as they are, a todo
+// without creation date keeps the zero time until New creates it now and one
+// without state is done when it has a completion date and pending otherwise. The mapping isn't used by the
+// todo.txt, iCalendar, Taskwarrior and Org files, which don't have columns.
+//
+// The notes tell what couldn't be imported, only Taskwarrior has them.
+func Read(r io.Reader, format Format, mapping Mapping) ([]db.Todo, []string, error) {
+	var records []map[string]string
+	var err error
+
+	switch format {
+	case TodoTxt:
+		todos, err := todotxt.Read(r)
+		return todos, nil, err
+	case ICS:
+		todos, err := ical.Read(r)
+		return todos, nil, err
+	case Org:
+		todos, err := org.Read(r)
+		return todos, nil, err
+	case Taskwarrior:
+		return taskwarrior.Read(r)
+	case JSON:
+		records, err = readJSON(r)
+	case CSV:
+		records, err = readCSV(r)
+	default:
+		return nil, nil, fmt.Errorf("format %q can't be imported", format)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	todos := []db.Todo{}
+
+	for i, record := range records {
+		todo, err := newTodo(record, mapping)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos, nil, nil
+}
+
+func readJSON(r io.Reader) ([]map[string]string, error) {
+	var objects []map[string]any
+
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("Not valid json, an array of tasks was expected: %w", err)
+	}
+
+	records := []map[string]string{}
+
+	for _, object := range objects {
+		record := map[string]string{}
+
+		for key, value := range object {
+			switch value := value.(type) {
+			case nil:
+			case string:
+				record[key] = value
+			default:
+				record[key] = fmt.Sprint(value)
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+
+	if err != nil {
+		return nil, fmt.Errorf("Not valid csv: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("Not valid csv, the header is missing")
+	}
+
+	records := []map[string]string{}
+
+	for _, row := range rows[1:] {
+		record := map[string]string{}
+
+		for i, column := range rows[0] {
+			if i < len(row) {
+				record[strings.TrimSpace(column)] = row[i]
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func newTodo(record map[string]string, mapping Mapping) (db.Todo, error) {
+	value := func(field string) string {
+		return strings.TrimSpace(record[mapping.column(field)])
+	}
+
+	todo := db.Todo{Todo: value("todo"), Tag: value("tag"), Priority: strings.ToUpper(value("priority")), UID: value("uid")}
+
+	if todo.Todo == "" {
+		return todo, fmt.Errorf("the column %q is empty", mapping.column("todo"))
+	}
+
+	if !db.ValidPriority(todo.Priority) {
+		return todo, fmt.Errorf("Not valid priority %q, use a letter from A to Z", todo.Priority)
+	}
+
+	for _, date := range []struct {
+		field string
+		time  *sql.NullTime
+	}{
+		{"date_completed", &todo.DateCompleted},
+		{"date_due", &todo.DateDue},
+		{"date_reviewed", &todo.DateReviewed},
+	} {
+		t, err := parseTime(value(date.field))
+
+		if err != nil {
+			return todo, fmt.Errorf("%s: %w", date.field, err)
+		}
+
+		*date.time = sql.NullTime{Time: t, Valid: !t.IsZero()}
+	}
+
+	created, err := parseTime(value("date_created"))
+
+	if err != nil {
+		return todo, fmt.Errorf("date_created: %w", err)
+	}
+
+	todo.DateCreated = created
+
+	switch state := value("state"); {
+	case state != "":
+		todo.State, err = db.ParseStatus(state)
+
+		if err != nil {
+			return todo, err
+		}
+	case todo.DateCompleted.Valid:
+		todo.State = db.Done
+	default:
+		todo.State = db.Pending
+	}
+
+	return todo, nil
+}
+
+// timeLayouts are the layouts accepted for the dates, the first one is the one
+// used by the export.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseTime parses a date, the zero time is returned for an empty one.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Not valid date %q, use RFC 3339 or YYYY-MM-DD", s)
+}
+
+// Entry is a todo of the file, Duplicate is the todo it duplicates when it
+// isn't imported.
+type Entry struct {
+	Todo      db.Todo
+	Duplicate *db.Todo
+}
+
+// Plan decides which todos are imported, the ones with the same uid, or the
+// same title, tag and creation day, as an existing todo or a previous todo of
+// the file are duplicates. The todos without creation date are duplicates of
+// the ones with the same title and tag created any day, so importing the same
+// file another day doesn't import them again.
+func Plan(incoming, existing []db.Todo) []Entry {
+	seen := map[string]*db.Todo{}
+	undated := map[string]*db.Todo{}
+	uids := map[string]*db.Todo{}
+
+	add := func(todo *db.Todo) {
+		seen[dedupeKey(*todo)] = todo
+
+		if undated[titleKey(*todo)] == nil {
+			undated[titleKey(*todo)] = todo
+		}
+
+		if todo.UID != "" {
+			uids[todo.UID] = todo
+		}
+	}
+
+	for i := range existing {
+		add(&existing[i])
+	}
+
+	entries := []Entry{}
+
+	for i := range incoming {
+		duplicate := seen[dedupeKey(incoming[i])]
+
+		if incoming[i].DateCreated.IsZero() {
+			duplicate = undated[titleKey(incoming[i])]
+		}
+
+		if incoming[i].UID != "" && uids[incoming[i].UID] != nil {
+			duplicate = uids[incoming[i].UID]
+		}
+
+		entries = append(entries, Entry{Todo: incoming[i], Duplicate: duplicate})
+
+		if duplicate == nil {
+			add(&incoming[i])
+		}
+	}
+
+	return entries
+}
+
+func dedupeKey(todo db.Todo) string {
+	return titleKey(todo) + "\x00" + todo.DateCreated.Local().Format("2006-01-02")
+}
+
+func titleKey(todo db.Todo) string {
+	return strings.ToLower(strings.TrimSpace(todo.Todo)) + "\x00" + strings.ToLower(todo.Tag)
+}
+
+// New returns the todos of the entries that aren't duplicates, the ones
+// without creation date are created now.
+func New(entries []Entry) []db.Todo {
+	todos := []db.Todo{}
+	now := time.Now()
+
+	for _, entry := range entries {
+		if entry.Duplicate == nil {
+			todo := entry.Todo
+
+			if todo.DateCreated.IsZero() {
+				todo.DateCreated = now
+			}
+
+			todos = append(todos, todo)
+		}
+	}
+
+	return todos
+}
+
+// WriteDiff writes a line for every entry, starting with + when it is imported
+// and with = when it is a duplicate.
+func WriteDiff(w io.Writer, entries []Entry) error {
+	for _, entry := range entries {
+		line := "+ " + describe(entry.Todo)
+
+		if entry.Duplicate != nil {
+			line = "= " + describe(entry.Todo) + " duplicates "
+
+			if entry.Duplicate.ID != 0 {
+				line += "task " + strconv.Itoa(entry.Duplicate.ID)
+			} else {
+				line += "a previous task of the file"
+			}
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func describe(todo db.Todo) string {
+	created := "today"
+	if !todo.DateCreated.IsZero() {
+		created = todo.DateCreated.Local().Format("2006-01-02")
+	}
+
+	s := fmt.Sprintf("%q %s created %s", todo.Todo, todo.State, created)
+
+	if todo.Tag != "" {
+		s += " tag " + todo.Tag
+	}
+
+	if todo.DateCompleted.Valid {
+		s += " completed " + todo.DateCompleted.Time.Local().Format("2006-01-02")
+	}
+
+	return s
+}
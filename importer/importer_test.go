@@ -0,0 +1,127 @@
+package importer
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+func TestPlan(t *testing.T) {
+	day := func(d, hour int) time.Time {
+		return time.Date(2024, 3, d, hour, 0, 0, 0, time.Local)
+	}
+
+	existing := []db.Todo{
+		{ID: 1, UID: "u1", Todo: "Write the report", Tag: "work", DateCreated: day(1, 9)},
+		{ID: 2, Todo: "Buy milk", Tag: "home", DateCreated: day(2, 9)},
+	}
+
+	tests := []struct {
+		name     string
+		incoming []db.Todo
+		want     []string // the duplicate of every todo, "" when it is imported
+	}{
+		{
+			name:     "same uid",
+			incoming: []db.Todo{{UID: "u1", Todo: "Renamed", DateCreated: day(5, 9)}},
+			want:     []string{"existing 0"},
+		},
+		{
+			name:     "same title, tag and day",
+			incoming: []db.Todo{{Todo: "  buy MILK ", Tag: "Home", DateCreated: day(2, 20)}},
+			want:     []string{"existing 1"},
+		},
+		{
+			name: "another day or tag",
+			incoming: []db.Todo{
+				{Todo: "Buy milk", Tag: "home", DateCreated: day(3, 9)},
+				{Todo: "Buy milk", Tag: "shop", DateCreated: day(2, 9)},
+			},
+			want: []string{"", ""},
+		},
+		{
+			name: "without creation date",
+			incoming: []db.Todo{
+				{Todo: "Buy milk", Tag: "home"},
+				{Todo: "Buy milk", Tag: "shop"},
+			},
+			want: []string{"existing 1", ""},
+		},
+		{
+			name: "repeated in the file",
+			incoming: []db.Todo{
+				{Todo: "Call Ana", DateCreated: day(4, 9)},
+				{Todo: "call ana", DateCreated: day(4, 18)},
+				{UID: "u3", Todo: "Water the plants"},
+				{UID: "u3", Todo: "Water the plants again"},
+				{Todo: "water the plants"},
+			},
+			want: []string{"", "incoming 0", "", "incoming 2", "incoming 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := Plan(tt.incoming, existing)
+
+			got := []string{}
+
+			for _, entry := range entries {
+				got = append(got, origin(entry.Duplicate, existing, tt.incoming))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plan() duplicates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// origin names the todo of existing or incoming the duplicate points to.
+func origin(duplicate *db.Todo, existing, incoming []db.Todo) string {
+	if duplicate == nil {
+		return ""
+	}
+
+	for i := range existing {
+		if duplicate == &existing[i] {
+			return "existing " + strconv.Itoa(i)
+		}
+	}
+
+	for i := range incoming {
+		if duplicate == &incoming[i] {
+			return "incoming " + strconv.Itoa(i)
+		}
+	}
+
+	return "unknown"
+}
+
+func TestNew(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	existing := db.Todo{Todo: "a"}
+
+	entries := []Entry{
+		{Todo: db.Todo{Todo: "dated", DateCreated: created}},
+		{Todo: db.Todo{Todo: "duplicate"}, Duplicate: &existing},
+		{Todo: db.Todo{Todo: "undated"}},
+	}
+
+	before := time.Now()
+	todos := New(entries)
+
+	if len(todos) != 2 || todos[0].Todo != "dated" || todos[1].Todo != "undated" {
+		t.Fatalf("New() = %+v, want the dated and undated todos", todos)
+	}
+
+	if !todos[0].DateCreated.Equal(created) {
+		t.Errorf("New()[0].DateCreated = %v, want %v", todos[0].DateCreated, created)
+	}
+
+	if todos[1].DateCreated.Before(before) {
+		t.Errorf("New()[1].DateCreated = %v, want now", todos[1].DateCreated)
+	}
+}
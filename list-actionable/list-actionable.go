@@ -1,36 +1,44 @@
 package list_actionable
 
 import (
-	"strconv"
+	"fmt"
 	"todo/db"
+	todo_table "todo/todo-table"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
-	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type keyMap struct {
-	Confirm key.Binding
-	Up      key.Binding
-	Down    key.Binding
-	Help    key.Binding
-	Quit    key.Binding
+	Confirm    key.Binding
+	Toggle     key.Binding
+	SelectAll  key.Binding
+	SelectNone key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	Filter     key.Binding
+	Sort       key.Binding
+	Reverse    key.Binding
+	Group      key.Binding
+	Help       key.Binding
+	Quit       key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view. It's part
 // of the key.Map interface.
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Help, k.Quit}
+	return []key.Binding{k.Filter, k.Help, k.Quit}
 }
 
 // FullHelp returns keybindings for the expanded help view. It's part of the
 // key.Map interface.
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Up, k.Down, k.Confirm}, // first column
-		{k.Help, k.Quit},          // second column
+		{k.Up, k.Down, k.Confirm, k.Quit},             // first column
+		{k.Toggle, k.SelectAll, k.SelectNone, k.Help}, // second column
+		{k.Filter, k.Sort, k.Reverse, k.Group},        // third column
 	}
 }
 
@@ -39,14 +47,24 @@ var keys = keyMap{
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "confirm action"),
 	),
-	Up: key.NewBinding(
-		key.WithKeys("up", "k"),
-		key.WithHelp("↑/k", "move up"),
+	Toggle: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "select task"),
 	),
-	Down: key.NewBinding(
-		key.WithKeys("down", "j"),
-		key.WithHelp("↓/j", "move down"),
+	SelectAll: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "select all"),
 	),
+	SelectNone: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "select none"),
+	),
+	Up:      todo_table.Keys.Up,
+	Down:    todo_table.Keys.Down,
+	Filter:  todo_table.Keys.Filter,
+	Sort:    todo_table.Keys.Sort,
+	Reverse: todo_table.Keys.Reverse,
+	Group:   todo_table.Keys.Group,
 	Help: key.NewBinding(
 		key.WithKeys("?"),
 		key.WithHelp("?", "toggle help"),
@@ -61,11 +79,18 @@ var baseStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.NormalBorder()).
 	BorderForeground(lipgloss.Color("240"))
 
+var counterStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("212"))
+
 type Model struct {
-	SelectedId string
-	keys       keyMap
-	help       help.Model
-	table      table.Model
+	// SelectedIds are the ids of the tasks selected when the action was
+	// confirmed, the task under the cursor when none was selected.
+	SelectedIds []int
+	keys        keyMap
+	help        help.Model
+	table       todo_table.Model
+	// width and height are the size of the window, zero until it is known.
+	width, height int
 }
 
 func (m Model) Init() tea.Cmd {
@@ -75,79 +100,77 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.help.Width = msg.Width
+		m.resize()
 	case tea.KeyMsg:
+		if m.table.Filtering() {
+			break
+		}
 		switch {
+		case key.Matches(msg, todo_table.Keys.Cancel) && m.table.HasFilter():
+			break
 		case key.Matches(msg, m.keys.Confirm):
-			if len(m.table.Rows()) > 0 {
-				m.SelectedId = m.table.SelectedRow()[0]
+			m.SelectedIds = m.table.SelectedIDs()
+			if todo, ok := m.table.SelectedTodo(); ok && len(m.SelectedIds) == 0 {
+				m.SelectedIds = []int{todo.ID}
 			}
 			return m, tea.Quit
+		case key.Matches(msg, m.keys.Toggle):
+			m.table.ToggleSelected()
+			return m, nil
+		case key.Matches(msg, m.keys.SelectAll):
+			m.table.SelectAll()
+			return m, nil
+		case key.Matches(msg, m.keys.SelectNone):
+			m.table.SelectNone()
+			return m, nil
 		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
 		case key.Matches(msg, m.keys.Help):
 			m.help.ShowAll = !m.help.ShowAll
+			m.resize()
 		}
 	}
 	m.table, cmd = m.table.Update(msg)
 	return m, cmd
 }
 
-func (m Model) View() string {
-	helpView := m.help.View(m.keys)
-	return baseStyle.Render(m.table.View()) + "\n" + helpView
-}
-
-func NewTodoTable(todos []db.Todo) Model {
-	columns := []table.Column{
-		{Title: "ID", Width: 4},
-		{Title: "Todo", Width: 25},
-		{Title: "Tag", Width: 16},
-		{Title: "State", Width: 8},
-		{Title: "Creation date", Width: 17},
+// resize fits the table in the window, leaving room for the help that is
+// shown, the short or the full one.
+func (m *Model) resize() {
+	if m.height == 0 {
+		return
 	}
 
-	rows := []table.Row{}
+	// The border takes two columns and two lines, two more lines are left
+	// for the status of the table and the selection counter.
+	helpHeight := lipgloss.Height(m.help.View(m.keys))
+	m.table.SetSize(m.width-2, m.height-4-helpHeight)
+}
 
-	for _, todo := range todos {
-		item := []string{
-			strconv.Itoa(todo.ID),
-			todo.Todo,
-			todo.Tag,
-			todo.State.String(),
-			todo.DateCreated.Format("2006-01-02"),
-		}
-		rows = append(rows, item)
+func (m Model) View() string {
+	helpView := m.help.View(m.keys)
+	counter := ""
+
+	if selected := len(m.table.SelectedIDs()); selected > 0 {
+		counter = counterStyle.Render(fmt.Sprintf("%d selected", selected)) + "\n"
 	}
 
-	t := table.New(
-		table.WithColumns(columns),
-		table.WithRows(rows),
-		table.WithFocused(true),
-		table.WithHeight(8),
-	)
-
-	s := table.DefaultStyles()
-	s.Header = s.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		BorderBottom(true).
-		Bold(false)
-
-	s.Selected = s.Selected.
-		Foreground(lipgloss.Color("229")).
-		Background(lipgloss.Color("57")).
-		Bold(false)
-	t.SetStyles(s)
+	return baseStyle.Render(m.table.View()) + "\n" + m.table.StatusView() + counter + helpView
+}
 
+func NewTodoTable(todos []db.Todo, opts ...todo_table.Option) Model {
 	helpView := help.New()
 
 	helpView.ShowAll = true
 
 	m := Model{
-		SelectedId: "",
-		keys:       keys,
-		help:       helpView,
-		table:      t,
+		SelectedIds: []int{},
+		keys:        keys,
+		help:        helpView,
+		table:       todo_table.New(todos, append(opts, todo_table.WithSelection())...),
 	}
 
 	return m
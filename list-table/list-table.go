@@ -1,47 +1,49 @@
 package list_table
 
 import (
-	"strconv"
 	"todo/db"
+	todo_table "todo/todo-table"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
-	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type keyMap struct {
-	Up   key.Binding
-	Down key.Binding
-	Help key.Binding
-	Quit key.Binding
+	Up      key.Binding
+	Down    key.Binding
+	Filter  key.Binding
+	Sort    key.Binding
+	Reverse key.Binding
+	Group   key.Binding
+	Help    key.Binding
+	Quit    key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view. It's part
 // of the key.Map interface.
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Help, k.Quit}
+	return []key.Binding{k.Filter, k.Help, k.Quit}
 }
 
 // FullHelp returns keybindings for the expanded help view. It's part of the
 // key.Map interface.
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Up, k.Down},   // first column
-		{k.Help, k.Quit}, // second column
+		{k.Up, k.Down, k.Filter},     // first column
+		{k.Sort, k.Reverse, k.Group}, // second column
+		{k.Help, k.Quit},             // third column
 	}
 }
 
 var keys = keyMap{
-	Up: key.NewBinding(
-		key.WithKeys("up", "k"),
-		key.WithHelp("↑/k", "move up"),
-	),
-	Down: key.NewBinding(
-		key.WithKeys("down", "j"),
-		key.WithHelp("↓/j", "move down"),
-	),
+	Up:      todo_table.Keys.Up,
+	Down:    todo_table.Keys.Down,
+	Filter:  todo_table.Keys.Filter,
+	Sort:    todo_table.Keys.Sort,
+	Reverse: todo_table.Keys.Reverse,
+	Group:   todo_table.Keys.Group,
 	Help: key.NewBinding(
 		key.WithKeys("?"),
 		key.WithHelp("?", "toggle help"),
@@ -59,7 +61,9 @@ var baseStyle = lipgloss.NewStyle().
 type Model struct {
 	keys  keyMap
 	help  help.Model
-	table table.Model
+	table todo_table.Model
+	// width and height are the size of the window, zero until it is known.
+	width, height int
 }
 
 func (m Model) Init() tea.Cmd {
@@ -69,65 +73,47 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.help.Width = msg.Width
+		m.resize()
 	case tea.KeyMsg:
+		if m.table.Filtering() {
+			break
+		}
 		switch {
+		case key.Matches(msg, todo_table.Keys.Cancel) && m.table.HasFilter():
+			break
 		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
 		case key.Matches(msg, m.keys.Help):
 			m.help.ShowAll = !m.help.ShowAll
+			m.resize()
 		}
 	}
 	m.table, cmd = m.table.Update(msg)
 	return m, cmd
 }
 
-func (m Model) View() string {
-	helpView := m.help.View(m.keys)
-	return baseStyle.Render(m.table.View()) + "\n" + helpView
-}
-
-func NewTodoTable(todos []db.Todo) Model {
-	columns := []table.Column{
-		{Title: "ID", Width: 4},
-		{Title: "Todo", Width: 25},
-		{Title: "Tag", Width: 16},
-		{Title: "State", Width: 8},
-		{Title: "Creation date", Width: 17},
+// resize fits the table in the window, leaving room for the help that is
+// shown, the short or the full one.
+func (m *Model) resize() {
+	if m.height == 0 {
+		return
 	}
 
-	rows := []table.Row{}
-
-	for _, todo := range todos {
-		item := []string{
-			strconv.Itoa(todo.ID),
-			todo.Todo,
-			todo.Tag,
-			todo.State.String(),
-			todo.DateCreated.Format("2006-01-02"),
-		}
-		rows = append(rows, item)
-	}
+	// The border takes two columns and two lines, one more line is left for
+	// the status of the table.
+	helpHeight := lipgloss.Height(m.help.View(m.keys))
+	m.table.SetSize(m.width-2, m.height-3-helpHeight)
+}
 
-	t := table.New(
-		table.WithColumns(columns),
-		table.WithRows(rows),
-		table.WithFocused(true),
-		table.WithHeight(8),
-	)
-
-	s := table.DefaultStyles()
-	s.Header = s.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		BorderBottom(true).
-		Bold(false)
-
-	s.Selected = s.Selected.
-		Foreground(lipgloss.Color("229")).
-		Background(lipgloss.Color("57")).
-		Bold(false)
-	t.SetStyles(s)
+func (m Model) View() string {
+	helpView := m.help.View(m.keys)
+	return baseStyle.Render(m.table.View()) + "\n" + m.table.StatusView() + helpView
+}
 
+func NewTodoTable(todos []db.Todo, opts ...todo_table.Option) Model {
 	helpView := help.New()
 
 	helpView.ShowAll = true
@@ -135,8 +121,20 @@ func NewTodoTable(todos []db.Todo) Model {
 	m := Model{
 		keys:  keys,
 		help:  helpView,
-		table: t,
+		table: todo_table.New(todos, opts...),
 	}
 
 	return m
 }
+
+// Render renders the todos with the style of the table without running it, the
+// width includes the border and zero keeps the default widths of the columns.
+func Render(todos []db.Todo, width int, opts ...todo_table.Option) string {
+	table := todo_table.New(todos, opts...)
+
+	if width > 0 {
+		table.SetSize(width-2, len(todos)+2)
+	}
+
+	return baseStyle.Render(table.Render())
+}
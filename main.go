@@ -1,13 +1,47 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"todo/db"
 )
 
+// Exit codes of todo, scripts can rely on them to react to the failures.
+const (
+	exitOK           = 0 // the command succeeded
+	exitError        = 1 // any other error, like a not valid task id
+	exitUsage        = 2 // the flags couldn't be parsed
+	exitNotFound     = 3 // the task doesn't exist
+	exitInvalidState = 4 // the task is in a state that doesn't allow the action
+	exitStorage      = 5 // the database couldn't be read or written
+)
+
+// usageError is returned when the flags of a command couldn't be parsed.
+type usageError struct {
+	error
+}
+
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageError{}):
+		return exitUsage
+	case errors.Is(err, db.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, db.ErrInvalidState):
+		return exitInvalidState
+	case errors.Is(err, db.ErrStorage):
+		return exitStorage
+	default:
+		return exitError
+	}
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(0) // Maybe it should exit with a 1
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
 	}
 }
@@ -0,0 +1,725 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"todo/db"
+)
+
+// Policy decides which side wins when a task changed both in the database and
+// in the notes since the last sync.
+type Policy string
+
+const (
+	PreferDatabase Policy = "db"
+	PreferFile     Policy = "file"
+	PreferNewer    Policy = "newer"
+)
+
+// ParsePolicy parses the value of the --conflict flag of the sync.
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case PreferDatabase, PreferFile, PreferNewer:
+		return Policy(s), nil
+	default:
+		return "", errors.New("Not valid conflict policy, use one of: db, file, newer")
+	}
+}
+
+// Store is the part of the database used by the sync.
+type Store interface {
+	GetTasksByFilter(filter db.Filter) ([]db.Todo, error)
+	AddTodo(todo db.Todo) (int, error)
+	ChangeTodoName(todoId int, newName string) error
+	CompleteTodo(todoId int) error
+	UncompleteTodo(todoId int) error
+	SyncItems(source string) ([]db.SyncItem, error)
+	SaveSyncItems(source string, items []db.SyncItem) error
+	Commit() error
+}
+
+// Report counts the changes made on each side by a sync.
+type Report struct {
+	Added, Updated, Closed   int // in the database
+	FileUpdated, FileDeleted int
+	Conflicts                int
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"database: %d added, %d updated, %d closed · notes: %d updated, %d removed · %d conflicts",
+		r.Added, r.Updated, r.Closed, r.FileUpdated, r.FileDeleted, r.Conflicts,
+	)
+}
+
+// itemPattern matches a checklist item, its checkbox and the marker with the
+// id of its task.
+var itemPattern = regexp.MustCompile(`^(\s*[-*+]\s+\[)([ xX])(\]\s+)(.*?)(?:\s*<!--\s*todo:(\d+)\s*-->)?\s*$`)
+
+// item is a checklist item of a note.
+type item struct {
+	note    *note
+	line    int
+	prefix  string // the bullet and the checkbox up to the mark
+	suffix  string // the checkbox after the mark
+	checked bool
+	text    string
+	id      int
+}
+
+// format returns the line of the item with the title and state of the task.
+func (i item) format(task db.Todo) string {
+	mark := " "
+	if task.State.Closed() {
+		mark = "x"
+	}
+
+	return fmt.Sprintf("%s%s%s%s <!-- todo:%d -->", i.prefix, mark, i.suffix, task.Todo, task.ID)
+}
+
+// note is a Markdown file of the directory.
+type note struct {
+	path     string
+	name     string // relative to the directory
+	lines    []string
+	modified time.Time
+	changed  bool
+}
+
+// snapshot is how the item looked after the last sync, only the title and
+// whether it is done are synced.
+func snapshot(done bool, title string) string {
+	if done {
+		return "[x] " + title
+	}
+	return "[ ] " + title
+}
+
+// Sync keeps the checklist items of the Markdown notes of the directory in
+// sync with the database. An item without the hidden <!-- todo:N --> marker
+// is a new task tagged with the name of its note, the marker is added so the
+// task is found again in the next syncs.
+//
+// Each side is compared with how the item looked after the last sync, so a
+// title edited or an item checked on one side is copied to the other, the
+// items checked in the notes complete their task with CompleteTodo and the
+// tasks done in the database are checked in the notes, right away by
+// WriteBack when it runs as a state hook. The items removed from the notes
+// close their task, which isn't synced anymore, instead of deleting it, and
+// the tasks deleted remove their item. When an item changed on both sides the
+// policy decides which one wins, newer compares the last modification of the
+// task with the one of the note.
+//
+// The store is a transaction, like the one of db.Begin, it is committed once
+// every note changed is written so a failed sync leaves both sides as they
+// were.
+func Sync(store Store, dir string, policy Policy) (Report, error) {
+	var report Report
+
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return report, err
+	}
+
+	source := "markdown:" + dir
+
+	notes, items, err := readNotes(dir)
+
+	if err != nil {
+		return report, err
+	}
+
+	todos, err := store.GetTasksByFilter(db.Filter{})
+
+	if err != nil {
+		return report, err
+	}
+
+	saved, err := store.SyncItems(source)
+
+	if err != nil {
+		return report, err
+	}
+
+	tasks := map[int]db.Todo{}
+	for _, todo := range todos {
+		tasks[todo.ID] = todo
+	}
+
+	snapshots := map[int]db.SyncItem{}
+	for _, s := range saved {
+		snapshots[s.TodoID] = s
+	}
+
+	synced := map[int]bool{}
+	newItems := []db.SyncItem{}
+	removed := map[*note]map[int]bool{}
+
+	for _, it := range items {
+		task, inDB := tasks[it.id]
+		last, wasSynced := snapshots[it.id]
+		fileSnapshot := snapshot(it.checked, it.text)
+
+		switch {
+		case it.id != 0 && !inDB && wasSynced && !synced[it.id] && (policy == PreferDatabase || fileSnapshot == last.Snapshot):
+			// Deleted from the database, the item goes too unless it was
+			// edited since and the notes win.
+			if fileSnapshot != last.Snapshot {
+				report.Conflicts++
+			}
+
+			if removed[it.note] == nil {
+				removed[it.note] = map[int]bool{}
+			}
+
+			removed[it.note][it.line] = true
+			it.note.changed = true
+			report.FileDeleted++
+			synced[it.id] = true
+			continue
+		case it.id == 0 || synced[it.id] || !inDB:
+			// A new item, a copy of another one or one edited after its task
+			// was deleted from the database.
+			if it.id != 0 && !inDB && wasSynced && !synced[it.id] {
+				report.Conflicts++
+			}
+
+			if it.id != 0 {
+				synced[it.id] = true
+			}
+
+			task = db.Todo{
+				Todo:        it.text,
+				Tag:         tagOf(it.note.name),
+				State:       db.Pending,
+				DateCreated: time.Now(),
+			}
+
+			if it.checked {
+				task.State = db.Done
+				task.DateCompleted.Time, task.DateCompleted.Valid = time.Now(), true
+			}
+
+			task.ID, err = store.AddTodo(task)
+
+			if err != nil {
+				return report, err
+			}
+
+			report.Added++
+		default:
+			fileChanged := fileSnapshot != last.Snapshot
+			dbChanged := snapshot(task.State.Closed(), task.Todo) != last.Snapshot
+			differ := fileSnapshot != snapshot(task.State.Closed(), task.Todo)
+
+			useFile := fileChanged && !dbChanged
+
+			if fileChanged && dbChanged && differ {
+				report.Conflicts++
+				useFile = policy == PreferFile || policy == PreferNewer && it.note.modified.After(task.DateModified)
+			}
+
+			if useFile && differ {
+				if err := apply(store, &task, it); err != nil {
+					return report, err
+				}
+
+				report.Updated++
+			}
+		}
+
+		synced[task.ID] = true
+		line := it.format(task)
+
+		// The line endings of the note are kept.
+		if strings.HasSuffix(it.note.lines[it.line], "\r") {
+			line += "\r"
+		}
+
+		if line != it.note.lines[it.line] {
+			it.note.lines[it.line] = line
+			it.note.changed = true
+			report.FileUpdated++
+		}
+
+		newItems = append(newItems, db.SyncItem{
+			TodoID:   task.ID,
+			RemoteID: it.note.name,
+			Snapshot: snapshot(task.State.Closed(), task.Todo),
+		})
+	}
+
+	// The tasks synced before whose item isn't in the notes anymore were
+	// removed from them, they are closed and unlinked so a note deleted by
+	// mistake doesn't lose its tasks.
+	ids := []int{}
+	for id := range snapshots {
+		if _, inDB := tasks[id]; inDB && !synced[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	byName := map[string]*note{}
+	for _, n := range notes {
+		byName[n.name] = n
+	}
+
+	for _, id := range ids {
+		task, last := tasks[id], snapshots[id]
+		n := byName[last.RemoteID]
+
+		modified := time.Now()
+		if n != nil {
+			modified = n.modified
+		}
+
+		changed := snapshot(task.State.Closed(), task.Todo) != last.Snapshot
+
+		if changed {
+			report.Conflicts++
+		}
+
+		if !changed || policy == PreferFile || policy == PreferNewer && modified.After(task.DateModified) {
+			if !task.State.Closed() {
+				if err := store.CompleteTodo(id); err != nil {
+					return report, err
+				}
+
+				report.Closed++
+			}
+
+			continue
+		}
+
+		// The task changed in the database and wins, its item is added back
+		// at the end of its note.
+		if n == nil {
+			n = &note{path: filepath.Join(dir, filepath.FromSlash(last.RemoteID)), name: last.RemoteID, lines: []string{""}}
+			notes = append(notes, n)
+			byName[n.name] = n
+		}
+
+		it := item{prefix: "- [", suffix: "] "}
+		end := len(n.lines)
+
+		// Before the empty line that follows the last newline.
+		if end > 0 && n.lines[end-1] == "" {
+			end--
+		}
+
+		n.lines = append(n.lines[:end], append([]string{it.format(task)}, n.lines[end:]...)...)
+		n.changed = true
+		report.FileUpdated++
+
+		newItems = append(newItems, db.SyncItem{TodoID: id, RemoteID: n.name, Snapshot: snapshot(task.State.Closed(), task.Todo)})
+	}
+
+	sort.Slice(newItems, func(i, j int) bool {
+		return newItems[i].TodoID < newItems[j].TodoID
+	})
+
+	if err := store.SaveSyncItems(source, newItems); err != nil {
+		return report, err
+	}
+
+	contents := map[string]string{}
+
+	for _, n := range notes {
+		if !n.changed {
+			continue
+		}
+
+		lines := []string{}
+		for i, line := range n.lines {
+			if !removed[n][i] {
+				lines = append(lines, line)
+			}
+		}
+
+		contents[n.path] = strings.Join(lines, "\n")
+	}
+
+	if err := writeFiles(contents); err != nil {
+		return report, err
+	}
+
+	return report, store.Commit()
+}
+
+// apply copies the title and the checkbox of the item to the task.
+func apply(store Store, task *db.Todo, it item) error {
+	if task.Todo != it.text {
+		if err := store.ChangeTodoName(task.ID, it.text); err != nil {
+			return err
+		}
+
+		task.Todo = it.text
+	}
+
+	switch {
+	case it.checked && !task.State.Closed():
+		if err := store.CompleteTodo(task.ID); err != nil {
+			return err
+		}
+
+		task.State = db.Done
+	case !it.checked && task.State.Closed():
+		if err := store.UncompleteTodo(task.ID); err != nil {
+			return err
+		}
+
+		task.State = db.Pending
+	}
+
+	return nil
+}
+
+// WriteBackStore is the part of the database used by WriteBack.
+type WriteBackStore interface {
+	GetTasksByFilter(filter db.Filter) ([]db.Todo, error)
+	SyncSources() ([]string, error)
+	SyncItems(source string) ([]db.SyncItem, error)
+	SaveSyncItems(source string, items []db.SyncItem) error
+}
+
+// WriteBack checks or unchecks the items of the tasks in the notes they were
+// synced with, it is run as a db.StateHook so a task completed with
+// CompleteTodo is checked right away instead of in the next sync. Only the
+// checkbox is written, the snapshots keep the title of the last sync so a title
+// edited on either side is still synced by Sync. The task has just changed so
+// it wins over its item checked or unchecked in the notes since the last sync.
+func WriteBack(store WriteBackStore, todoIds []int) error {
+	sources, err := store.SyncSources()
+
+	if err != nil {
+		return err
+	}
+
+	var tasks map[int]db.Todo
+
+	for _, source := range sources {
+		dir, ok := strings.CutPrefix(source, "markdown:")
+
+		if !ok {
+			continue
+		}
+
+		if tasks == nil {
+			todos, err := store.GetTasksByFilter(db.Filter{IDs: todoIds})
+
+			if err != nil {
+				return err
+			}
+
+			tasks = map[int]db.Todo{}
+			for _, todo := range todos {
+				tasks[todo.ID] = todo
+			}
+		}
+
+		if err := writeBack(store, source, dir, tasks); err != nil {
+			return fmt.Errorf("the notes of %s couldn't be updated: %w", dir, err)
+		}
+	}
+
+	return nil
+}
+
+// writeBack writes the checkboxes of the tasks in the notes of a directory.
+func writeBack(store WriteBackStore, source, dir string, tasks map[int]db.Todo) error {
+	saved, err := store.SyncItems(source)
+
+	if err != nil {
+		return err
+	}
+
+	notes := map[string]*note{}
+	notesItems := map[string][]item{}
+	contents := map[string]string{}
+	changed := false
+
+	for i, synced := range saved {
+		task, ok := tasks[synced.TodoID]
+
+		if !ok {
+			continue
+		}
+
+		n, read := notes[synced.RemoteID]
+
+		if !read {
+			path := filepath.Join(dir, filepath.FromSlash(synced.RemoteID))
+			n, notesItems[synced.RemoteID], err = readNote(path, synced.RemoteID, time.Time{})
+
+			if errors.Is(err, fs.ErrNotExist) {
+				n, err = nil, nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			notes[synced.RemoteID] = n
+		}
+
+		if n == nil {
+			continue
+		}
+
+		done := task.State.Closed()
+		mark := " "
+		if done {
+			mark = "x"
+		}
+
+		found := false
+
+		for _, it := range notesItems[synced.RemoteID] {
+			if it.id != task.ID {
+				continue
+			}
+
+			found = true
+
+			if it.checked != done {
+				line := n.lines[it.line]
+				n.lines[it.line] = line[:len(it.prefix)] + mark + line[len(it.prefix)+1:]
+				contents[n.path] = strings.Join(n.lines, "\n")
+			}
+		}
+
+		// An item removed from the notes is left to Sync, which closes its
+		// task.
+		if !found {
+			continue
+		}
+
+		title := strings.TrimPrefix(strings.TrimPrefix(synced.Snapshot, "[x] "), "[ ] ")
+
+		if s := snapshot(done, title); s != synced.Snapshot {
+			saved[i].Snapshot = s
+			changed = true
+		}
+	}
+
+	// The notes are written first, so a failure leaves the snapshots as they
+	// were and the next sync still finds the task changed.
+	if err := writeFiles(contents); err != nil {
+		return err
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return store.SaveSyncItems(source, saved)
+}
+
+// tagOf returns the tag of the tasks of a note, its file name without the
+// extension.
+func tagOf(name string) string {
+	base := filepath.Base(name)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// readNotes reads the Markdown files of the directory and its subdirectories,
+// the hidden ones like .obsidian or .git are skipped.
+func readNotes(dir string) ([]*note, []item, error) {
+	info, err := os.Stat(dir)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("Not valid notes directory %q, it is a file", dir)
+	}
+
+	notes := []*note{}
+	items := []item{}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if ext := strings.ToLower(filepath.Ext(path)); d.IsDir() || ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+
+		info, err := d.Info()
+
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+
+		if err != nil {
+			return err
+		}
+
+		n, noteItems, err := readNote(path, filepath.ToSlash(name), info.ModTime())
+
+		if err != nil {
+			return err
+		}
+
+		notes = append(notes, n)
+		items = append(items, noteItems...)
+
+		return nil
+	})
+
+	return notes, items, err
+}
+
+// readNote reads the checklist items of a note, the items inside code blocks
+// aren't tasks.
+func readNote(path, name string, modified time.Time) (*note, []item, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := &note{
+		path:     path,
+		name:     name,
+		lines:    strings.Split(string(content), "\n"),
+		modified: modified,
+	}
+
+	items := []item{}
+	fence := ""
+
+	for i, line := range n.lines {
+		trimmed := strings.TrimSpace(line)
+
+		if fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			fence = trimmed[:3]
+			continue
+		}
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+
+			continue
+		}
+
+		m := itemPattern.FindStringSubmatch(strings.TrimSuffix(line, "\r"))
+
+		if m == nil || strings.TrimSpace(m[4]) == "" {
+			continue
+		}
+
+		it := item{
+			note:    n,
+			line:    i,
+			prefix:  m[1],
+			suffix:  m[3],
+			checked: m[2] != " ",
+			text:    strings.TrimSpace(m[4]),
+		}
+
+		if m[5] != "" {
+			it.id, _ = strconv.Atoi(m[5])
+		}
+
+		items = append(items, it)
+	}
+
+	return n, items, nil
+}
+
+// writeFiles replaces the files with their contents through temporary ones,
+// which are all written before renaming any so a failure leaves the files as
+// they were. The permissions of the files are kept.
+func writeFiles(contents map[string]string) error {
+	temps := map[string]string{}
+
+	defer func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+	}()
+
+	for path, content := range contents {
+		tmp, err := writeTemp(path, content)
+
+		if err != nil {
+			return err
+		}
+
+		temps[path] = tmp
+	}
+
+	for path, tmp := range temps {
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+
+		delete(temps, path)
+	}
+
+	return nil
+}
+
+// writeTemp writes the content to a temporary file next to the path, with the
+// permissions of the file, and returns its name.
+func writeTemp(path, content string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	mode := fs.FileMode(0o644)
+
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
@@ -0,0 +1,564 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+type fakeStore struct {
+	todos     map[int]db.Todo
+	nextID    int
+	now       time.Time
+	items     map[string][]db.SyncItem
+	committed int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		todos:  map[int]db.Todo{},
+		nextID: 1,
+		now:    time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		items:  map[string][]db.SyncItem{},
+	}
+}
+
+func (s *fakeStore) tick() time.Time {
+	s.now = s.now.Add(time.Second)
+	return s.now
+}
+
+func (s *fakeStore) GetTasksByFilter(filter db.Filter) ([]db.Todo, error) {
+	todos := []db.Todo{}
+
+	for _, todo := range s.todos {
+		if len(filter.IDs) > 0 && !containsID(filter.IDs, todo.ID) {
+			continue
+		}
+
+		todos = append(todos, todo)
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].ID < todos[j].ID
+	})
+
+	return todos, nil
+}
+
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *fakeStore) AddTodo(todo db.Todo) (int, error) {
+	todo.ID = s.nextID
+	s.nextID++
+	todo.DateModified = s.tick()
+	s.todos[todo.ID] = todo
+
+	return todo.ID, nil
+}
+
+func (s *fakeStore) update(todoId int, change func(*db.Todo)) error {
+	todo, ok := s.todos[todoId]
+
+	if !ok {
+		return db.ErrNotFound
+	}
+
+	change(&todo)
+	todo.DateModified = s.tick()
+	s.todos[todoId] = todo
+
+	return nil
+}
+
+func (s *fakeStore) ChangeTodoName(todoId int, newName string) error {
+	return s.update(todoId, func(todo *db.Todo) { todo.Todo = newName })
+}
+
+func (s *fakeStore) CompleteTodo(todoId int) error {
+	return s.update(todoId, func(todo *db.Todo) { todo.State = db.Done })
+}
+
+func (s *fakeStore) UncompleteTodo(todoId int) error {
+	return s.update(todoId, func(todo *db.Todo) { todo.State = db.Pending })
+}
+
+func (s *fakeStore) SyncSources() ([]string, error) {
+	sources := []string{}
+	for source := range s.items {
+		sources = append(sources, source)
+	}
+
+	sort.Strings(sources)
+
+	return sources, nil
+}
+
+func (s *fakeStore) SyncItems(source string) ([]db.SyncItem, error) {
+	return append([]db.SyncItem{}, s.items[source]...), nil
+}
+
+func (s *fakeStore) SaveSyncItems(source string, items []db.SyncItem) error {
+	s.items[source] = append([]db.SyncItem{}, items...)
+	return nil
+}
+
+func (s *fakeStore) Commit() error {
+	s.committed++
+	return nil
+}
+
+// titles returns the title and the state of every todo, sorted by id.
+func (s *fakeStore) titles() []string {
+	todos, _ := s.GetTasksByFilter(db.Filter{})
+	titles := []string{}
+
+	for _, todo := range todos {
+		titles = append(titles, todo.State.String()+" "+todo.Todo)
+	}
+
+	return titles
+}
+
+// writeNote writes the note inside dir, with its modification time when it
+// isn't zero.
+func writeNote(t *testing.T, dir, name, content string, modified time.Time) {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !modified.IsZero() {
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readNoteFile(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func runSync(t *testing.T, store *fakeStore, dir string, policy Policy) Report {
+	t.Helper()
+
+	report, err := Sync(store, dir, policy)
+
+	if err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+
+	return report
+}
+
+func TestSyncFirst(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "week.md", "# Week\n- [ ] Buy milk\r\n* [x] Call Ana\n- not a task\n- [ ] \n```\n- [ ] in a code block\n```\n", time.Time{})
+	writeNote(t, dir, "projects/home.markdown", "  + [ ] Paint the door\n", time.Time{})
+	writeNote(t, dir, ".obsidian/templates.md", "- [ ] hidden\n", time.Time{})
+	writeNote(t, dir, "notes.txt", "- [ ] not a note\n", time.Time{})
+
+	store := newFakeStore()
+	report := runSync(t, store, dir, PreferDatabase)
+
+	if want := (Report{Added: 3, FileUpdated: 3}); report != want {
+		t.Errorf("Sync() = %+v, want %+v", report, want)
+	}
+
+	if want := []string{"todo Paint the door", "todo Buy milk", "done Call Ana"}; !reflect.DeepEqual(store.titles(), want) {
+		t.Errorf("todos = %q, want %q", store.titles(), want)
+	}
+
+	if store.todos[1].Tag != "home" || store.todos[2].Tag != "week" {
+		t.Errorf("tags = %q, %q, want the names of the notes", store.todos[1].Tag, store.todos[2].Tag)
+	}
+
+	week := "# Week\n- [ ] Buy milk <!-- todo:2 -->\r\n* [x] Call Ana <!-- todo:3 -->\n- not a task\n- [ ] \n```\n- [ ] in a code block\n```\n"
+
+	if got := readNoteFile(t, dir, "week.md"); got != week {
+		t.Errorf("week.md = %q, want %q", got, week)
+	}
+
+	if got := readNoteFile(t, dir, "projects/home.markdown"); got != "  + [ ] Paint the door <!-- todo:1 -->\n" {
+		t.Errorf("home.markdown = %q, want the marker added", got)
+	}
+
+	if got := readNoteFile(t, dir, ".obsidian/templates.md"); got != "- [ ] hidden\n" {
+		t.Errorf("templates.md = %q, want it untouched", got)
+	}
+
+	items := store.items["markdown:"+dir]
+	want := []db.SyncItem{
+		{TodoID: 1, RemoteID: "projects/home.markdown", Snapshot: "[ ] Paint the door"},
+		{TodoID: 2, RemoteID: "week.md", Snapshot: "[ ] Buy milk"},
+		{TodoID: 3, RemoteID: "week.md", Snapshot: "[x] Call Ana"},
+	}
+
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("sync items = %+v, want %+v", items, want)
+	}
+
+	if store.committed != 1 {
+		t.Errorf("Commit() called %d times, want 1", store.committed)
+	}
+
+	// Nothing changed, the second sync doesn't write anything.
+	if report := runSync(t, store, dir, PreferDatabase); report != (Report{}) {
+		t.Errorf("second Sync() = %+v, want no changes", report)
+	}
+}
+
+func TestSyncChanges(t *testing.T) {
+	// The note is modified before the tasks unless a test sets it later.
+	noteTime := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	later := time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		note     string    // the note after the first sync, empty to keep it
+		modified time.Time // when the note was modified, noteTime when zero
+		change   func(*fakeStore)
+		report   Report
+		todos    []string
+		want     string
+	}{
+		{
+			name:   "title edited in the note",
+			note:   "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+			report: Report{Updated: 1},
+			todos:  []string{"todo Buy oat milk", "todo Call Ana"},
+			want:   "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name:   "checked in the note",
+			note:   "- [x] Buy milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+			report: Report{Updated: 1},
+			todos:  []string{"done Buy milk", "todo Call Ana"},
+			want:   "- [x] Buy milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name: "changed in the database",
+			change: func(s *fakeStore) {
+				s.CompleteTodo(1)
+				s.ChangeTodoName(2, "Call Ana and Luis")
+			},
+			report: Report{FileUpdated: 2},
+			todos:  []string{"done Buy milk", "todo Call Ana and Luis"},
+			want:   "- [x] Buy milk <!-- todo:1 -->\n- [ ] Call Ana and Luis <!-- todo:2 -->\n",
+		},
+		{
+			name:   "changed on both sides, the database wins",
+			policy: PreferDatabase,
+			note:   "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+			change: func(s *fakeStore) { s.ChangeTodoName(1, "Buy soy milk") },
+			report: Report{FileUpdated: 1, Conflicts: 1},
+			todos:  []string{"todo Buy soy milk", "todo Call Ana"},
+			want:   "- [ ] Buy soy milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name:   "changed on both sides, the note wins",
+			policy: PreferFile,
+			note:   "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+			change: func(s *fakeStore) { s.ChangeTodoName(1, "Buy soy milk") },
+			report: Report{Updated: 1, Conflicts: 1},
+			todos:  []string{"todo Buy oat milk", "todo Call Ana"},
+			want:   "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name:     "changed on both sides, the newer note wins",
+			policy:   PreferNewer,
+			note:     "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+			modified: later,
+			change:   func(s *fakeStore) { s.ChangeTodoName(1, "Buy soy milk") },
+			report:   Report{Updated: 1, Conflicts: 1},
+			todos:    []string{"todo Buy oat milk", "todo Call Ana"},
+			want:     "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name:   "changed on both sides, the newer task wins",
+			policy: PreferNewer,
+			note:   "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+			change: func(s *fakeStore) { s.ChangeTodoName(1, "Buy soy milk") },
+			report: Report{FileUpdated: 1, Conflicts: 1},
+			todos:  []string{"todo Buy soy milk", "todo Call Ana"},
+			want:   "- [ ] Buy soy milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name:   "removed from the note",
+			note:   "- [ ] Call Ana <!-- todo:2 -->\n",
+			report: Report{Closed: 1},
+			todos:  []string{"done Buy milk", "todo Call Ana"},
+			want:   "- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name:   "removed from the note and changed in the database",
+			policy: PreferDatabase,
+			note:   "- [ ] Call Ana <!-- todo:2 -->\n",
+			change: func(s *fakeStore) { s.ChangeTodoName(1, "Buy soy milk") },
+			report: Report{FileUpdated: 1, Conflicts: 1},
+			todos:  []string{"todo Buy soy milk", "todo Call Ana"},
+			want:   "- [ ] Call Ana <!-- todo:2 -->\n- [ ] Buy soy milk <!-- todo:1 -->\n",
+		},
+		{
+			name:   "deleted from the database",
+			change: func(s *fakeStore) { delete(s.todos, 1) },
+			report: Report{FileDeleted: 1},
+			todos:  []string{"todo Call Ana"},
+			want:   "- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name:   "deleted from the database and edited in the note",
+			policy: PreferFile,
+			note:   "- [ ] Buy oat milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+			change: func(s *fakeStore) { delete(s.todos, 1) },
+			report: Report{Added: 1, FileUpdated: 1, Conflicts: 1},
+			todos:  []string{"todo Call Ana", "todo Buy oat milk"},
+			want:   "- [ ] Buy oat milk <!-- todo:3 -->\n- [ ] Call Ana <!-- todo:2 -->\n",
+		},
+		{
+			name:   "copied in the note",
+			note:   "- [ ] Buy milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n- [ ] Buy milk <!-- todo:1 -->\n",
+			report: Report{Added: 1, FileUpdated: 1},
+			todos:  []string{"todo Buy milk", "todo Call Ana", "todo Buy milk"},
+			want:   "- [ ] Buy milk <!-- todo:1 -->\n- [ ] Call Ana <!-- todo:2 -->\n- [ ] Buy milk <!-- todo:3 -->\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeNote(t, dir, "week.md", "- [ ] Buy milk\n- [ ] Call Ana\n", noteTime)
+
+			store := newFakeStore()
+			runSync(t, store, dir, PreferDatabase)
+
+			if tt.note != "" {
+				modified := tt.modified
+				if modified.IsZero() {
+					modified = noteTime
+				}
+
+				writeNote(t, dir, "week.md", tt.note, modified)
+			}
+
+			if tt.change != nil {
+				tt.change(store)
+			}
+
+			policy := tt.policy
+			if policy == "" {
+				policy = PreferDatabase
+			}
+
+			if report := runSync(t, store, dir, policy); report != tt.report {
+				t.Errorf("Sync() = %+v, want %+v", report, tt.report)
+			}
+
+			if !reflect.DeepEqual(store.titles(), tt.todos) {
+				t.Errorf("todos = %q, want %q", store.titles(), tt.todos)
+			}
+
+			if got := readNoteFile(t, dir, "week.md"); got != tt.want {
+				t.Errorf("week.md = %q, want %q", got, tt.want)
+			}
+
+			// The next sync finds both sides in sync.
+			if report := runSync(t, store, dir, policy); report != (Report{}) {
+				t.Errorf("next Sync() = %+v, want no changes", report)
+			}
+		})
+	}
+}
+
+func TestSyncRemovedNote(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "week.md", "- [ ] Buy milk\n- [x] Call Ana\n", time.Time{})
+
+	store := newFakeStore()
+	runSync(t, store, dir, PreferDatabase)
+
+	if err := os.Remove(filepath.Join(dir, "week.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := runSync(t, store, dir, PreferDatabase); report != (Report{Closed: 1}) {
+		t.Errorf("Sync() = %+v, want the open task closed", report)
+	}
+
+	if want := []string{"done Buy milk", "done Call Ana"}; !reflect.DeepEqual(store.titles(), want) {
+		t.Errorf("todos = %q, want %q", store.titles(), want)
+	}
+
+	if items := store.items["markdown:"+dir]; len(items) != 0 {
+		t.Errorf("sync items = %+v, want the tasks unlinked", items)
+	}
+}
+
+func TestSyncNotADirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "week.md", "- [ ] Buy milk\n", time.Time{})
+
+	store := newFakeStore()
+
+	if _, err := Sync(store, filepath.Join(dir, "week.md"), PreferDatabase); err == nil {
+		t.Error("Sync() of a file succeeded, want an error")
+	}
+
+	if len(store.todos) != 0 || store.committed != 0 {
+		t.Errorf("Sync() of a file changed the store")
+	}
+}
+
+func TestWriteBack(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "week.md", "- [ ] Buy milk\r\n- [x] Call Ana\n- [ ] Water the plants\n", time.Time{})
+	writeNote(t, dir, "other.md", "- [ ] Read\n", time.Time{})
+
+	store := newFakeStore()
+	runSync(t, store, dir, PreferDatabase)
+
+	// Buy milk is 2, Call Ana 3 and Water the plants 4. The title of Water the
+	// plants is edited in the note and the one of Call Ana in the database.
+	writeNote(t, dir, "week.md", "- [ ] Buy milk <!-- todo:2 -->\r\n- [x] Call Ana <!-- todo:3 -->\n- [ ] Water the ferns <!-- todo:4 -->\n", time.Time{})
+	store.ChangeTodoName(3, "Call Ana and Luis")
+	store.CompleteTodo(2)
+	store.UncompleteTodo(3)
+	store.CompleteTodo(4)
+
+	if err := WriteBack(store, []int{2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "- [x] Buy milk <!-- todo:2 -->\r\n- [ ] Call Ana <!-- todo:3 -->\n- [x] Water the ferns <!-- todo:4 -->\n"
+
+	if got := readNoteFile(t, dir, "week.md"); got != want {
+		t.Errorf("week.md = %q, want %q", got, want)
+	}
+
+	if got := readNoteFile(t, dir, "other.md"); got != "- [ ] Read <!-- todo:1 -->\n" {
+		t.Errorf("other.md = %q, want it untouched", got)
+	}
+
+	// The titles are still synced by Sync, each one to the other side.
+	if report := runSync(t, store, dir, PreferDatabase); report != (Report{Updated: 1, FileUpdated: 1}) {
+		t.Errorf("Sync() = %+v, want a title copied to each side", report)
+	}
+
+	if want := []string{"todo Read", "done Buy milk", "todo Call Ana and Luis", "done Water the ferns"}; !reflect.DeepEqual(store.titles(), want) {
+		t.Errorf("todos = %q, want %q", store.titles(), want)
+	}
+
+	want = "- [x] Buy milk <!-- todo:2 -->\r\n- [ ] Call Ana and Luis <!-- todo:3 -->\n- [x] Water the ferns <!-- todo:4 -->\n"
+
+	if got := readNoteFile(t, dir, "week.md"); got != want {
+		t.Errorf("week.md = %q, want %q", got, want)
+	}
+}
+
+func TestWriteBackNotSynced(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "week.md", "- [ ] Buy milk\n", time.Time{})
+
+	store := newFakeStore()
+	runSync(t, store, dir, PreferDatabase)
+
+	// A task that isn't synced, a note removed and other sources are skipped.
+	store.items["todotxt:/tmp/todo.txt"] = []db.SyncItem{{TodoID: 1, RemoteID: "1", Snapshot: "Buy milk"}}
+	id, _ := store.AddTodo(db.Todo{Todo: "Not synced", State: db.Done})
+	store.CompleteTodo(1)
+
+	if err := os.Remove(filepath.Join(dir, "week.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteBack(store, []int{1, id}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "week.md")); err == nil {
+		t.Error("WriteBack() wrote the note removed")
+	}
+
+	if items := store.items["markdown:"+dir]; len(items) != 1 || items[0].Snapshot != "[ ] Buy milk" {
+		t.Errorf("sync items = %+v, want them left to the next sync", items)
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "a.md", "old a", time.Time{})
+	writeNote(t, dir, "blocked", "a file where a directory is needed", time.Time{})
+
+	if err := os.Chmod(filepath.Join(dir, "a.md"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeFiles(map[string]string{
+		filepath.Join(dir, "a.md"):            "new a",
+		filepath.Join(dir, "blocked", "b.md"): "new b",
+	})
+
+	if err == nil {
+		t.Fatal("writeFiles() succeeded, want an error")
+	}
+
+	if got := readNoteFile(t, dir, "a.md"); got != "old a" {
+		t.Errorf("a.md = %q after a failed write, want it untouched", got)
+	}
+
+	if err := writeFiles(map[string]string{filepath.Join(dir, "a.md"): "new a", filepath.Join(dir, "sub", "c.md"): "new c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readNoteFile(t, dir, "a.md"); got != "new a" {
+		t.Errorf("a.md = %q, want %q", got, "new a")
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, "a.md")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("a.md mode = %v, want the permissions kept", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	if want := []string{"a.md", "blocked", "sub"}; strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("files = %q, want %q without temporary files", names, want)
+	}
+}
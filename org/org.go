@@ -0,0 +1,380 @@
+package org
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"todo/db"
+)
+
+const (
+	dateLayout = "2006-01-02 Mon"
+	timeLayout = "2006-01-02 Mon 15:04"
+)
+
+// The properties of the drawer of every task, the id round-trips the uid so
+// importing the file again doesn't duplicate the tasks.
+const (
+	idProperty      = "ID"
+	createdProperty = "CREATED"
+)
+
+// Write writes an Org file with a headline for every todo. The keyword of the
+// headline is the state of the todo, the #+TODO line at the top tells Org the
+// states of the workflow so the open ones are TODO and the closed ones DONE.
+// The tag is the tag of the headline and the priority its [#A] cookie.
+func Write(w io.Writer, todos []db.Todo) error {
+	bw := bufio.NewWriter(w)
+
+	open, closed := []string{}, []string{}
+
+	for _, state := range db.States() {
+		if state.Closed {
+			closed = append(closed, keyword(state.ID))
+		} else {
+			open = append(open, keyword(state.ID))
+		}
+	}
+
+	fmt.Fprintf(bw, "#+TODO: %s | %s\n", strings.Join(open, " "), strings.Join(closed, " "))
+
+	for _, todo := range todos {
+		words := []string{"*", keyword(todo.State)}
+
+		if todo.Priority != "" {
+			words = append(words, "[#"+todo.Priority+"]")
+		}
+
+		words = append(words, strings.Fields(todo.Todo)...)
+
+		if todo.Tag != "" {
+			words = append(words, ":"+tagOf(todo.Tag)+":")
+		}
+
+		fmt.Fprintln(bw)
+		fmt.Fprintln(bw, strings.Join(words, " "))
+
+		planning := []string{}
+
+		if todo.State.Closed() && todo.DateCompleted.Valid {
+			planning = append(planning, "CLOSED: ["+todo.DateCompleted.Time.Local().Format(timeLayout)+"]")
+		}
+
+		if todo.DateDue.Valid {
+			planning = append(planning, "DEADLINE: <"+todo.DateDue.Time.Local().Format(dateLayout)+">")
+		}
+
+		if len(planning) > 0 {
+			fmt.Fprintln(bw, strings.Join(planning, " "))
+		}
+
+		fmt.Fprintln(bw, ":PROPERTIES:")
+
+		if todo.UID != "" {
+			fmt.Fprintf(bw, ":%s: %s\n", idProperty, todo.UID)
+		}
+
+		fmt.Fprintf(bw, ":%s: [%s]\n", createdProperty, todo.DateCreated.Local().Format(timeLayout))
+		fmt.Fprintln(bw, ":END:")
+	}
+
+	return bw.Flush()
+}
+
+// keyword returns the keyword of the headlines of the todos in the state.
+func keyword(state db.Status) string {
+	return strings.ToUpper(state.String())
+}
+
+var notTagChars = regexp.MustCompile(`[^\p{L}\p{N}_@#%]+`)
+
+// tagOf returns the tag with the characters Org doesn't allow in tags replaced.
+func tagOf(tag string) string {
+	return notTagChars.ReplaceAllString(tag, "_")
+}
+
+var (
+	headlinePattern = regexp.MustCompile(`^(\*+)(?:\s+(.*?))?\s*$`)
+	tagsPattern     = regexp.MustCompile(`^(.*?)\s+:([\p{L}\p{N}_@#%:]+):$`)
+	priorityPattern = regexp.MustCompile(`^\[#([A-Z])\]\s*`)
+	propertyPattern = regexp.MustCompile(`^:([^:\s]+):\s*(.*?)\s*$`)
+	datePattern     = regexp.MustCompile(`[<\[](\d{4}-\d{2}-\d{2})[^\]>]*[\]>]`)
+	hourPattern     = regexp.MustCompile(`\b(\d{1,2}:\d{2})\b`)
+	keywordsPattern = regexp.MustCompile(`(?i)^#\+(?:SEQ_|TYP_)?TODO:(.*)$`)
+	fileTagsPattern = regexp.MustCompile(`(?i)^#\+FILETAGS:\s*:?(.*?):?\s*$`)
+)
+
+// parent is a headline the next ones may be nested in, for the tags they
+// inherit.
+type parent struct {
+	level int
+	tags  []string
+}
+
+// Read reads the headlines of an Org file that have a TODO keyword, the rest
+// are sections and only lend their tags to the tasks inside them. The keywords
+// are TODO and DONE unless the file has #+TODO lines, a keyword named after a
+// state of the workflow is that state and the other ones are pending or done.
+//
+// The tag is the first tag of the headline, or the one it inherits. CLOSED is
+// the completion date, DEADLINE the due date and the ID and CREATED properties
+// the uid and creation date, which is zero when the task doesn't have one.
+func Read(r io.Reader) ([]db.Todo, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	keywords := map[string]bool{}
+	fileTags := []string{}
+	todos := []*db.Todo{}
+	parents := []parent{}
+	var current *db.Todo
+	drawer := false
+
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if m := keywordsPattern.FindStringSubmatch(trimmed); m != nil {
+			addKeywords(keywords, m[1])
+			continue
+		}
+
+		if m := fileTagsPattern.FindStringSubmatch(trimmed); m != nil {
+			fileTags = splitTags(m[1])
+			continue
+		}
+
+		if m := headlinePattern.FindStringSubmatch(line); m != nil {
+			level := len(m[1])
+			title, tags := m[2], []string{}
+
+			if t := tagsPattern.FindStringSubmatch(title); t != nil {
+				title, tags = t[1], splitTags(t[2])
+			}
+
+			for len(parents) > 0 && parents[len(parents)-1].level >= level {
+				parents = parents[:len(parents)-1]
+			}
+
+			current, drawer = nil, false
+			word, rest, _ := strings.Cut(title, " ")
+
+			if state, ok := stateOf(keywords, word); ok {
+				todo := &db.Todo{State: state}
+
+				if p := priorityPattern.FindStringSubmatch(rest); p != nil {
+					todo.Priority = p[1]
+					rest = rest[len(p[0]):]
+				}
+
+				todo.Todo = strings.Join(strings.Fields(rest), " ")
+
+				if todo.Todo == "" {
+					return nil, fmt.Errorf("line %d: Not valid task %q, the title is empty", n, line)
+				}
+
+				todo.Tag = inheritedTag(tags, parents, fileTags)
+				todos = append(todos, todo)
+				current = todo
+			}
+
+			parents = append(parents, parent{level: level, tags: tags})
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(trimmed, ":PROPERTIES:"):
+			drawer = true
+		case drawer && strings.EqualFold(trimmed, ":END:"):
+			drawer = false
+		case drawer:
+			if err := setProperty(current, trimmed); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		default:
+			if err := setPlanning(current, trimmed); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []db.Todo{}
+
+	for _, todo := range todos {
+		if !todo.State.Closed() {
+			todo.DateCompleted = sql.NullTime{}
+		}
+
+		result = append(result, *todo)
+	}
+
+	return result, nil
+}
+
+// addKeywords adds the keywords of a #+TODO line, the ones after the | are the
+// closed ones. Without a | the last keyword is the closed one, as in Org.
+func addKeywords(keywords map[string]bool, line string) {
+	words := []string{}
+	closedFrom := -1
+
+	for _, word := range strings.Fields(line) {
+		if word == "|" {
+			closedFrom = len(words)
+			continue
+		}
+
+		// The fast access key and logging options, like DONE(d!).
+		word, _, _ = strings.Cut(word, "(")
+		words = append(words, word)
+	}
+
+	if closedFrom == -1 {
+		closedFrom = len(words) - 1
+	}
+
+	for i, word := range words {
+		keywords[word] = i >= closedFrom
+	}
+}
+
+// stateOf returns the state of the headlines with the keyword, false when it
+// isn't a keyword.
+func stateOf(keywords map[string]bool, word string) (db.Status, bool) {
+	closed, ok := keywords[word]
+
+	if len(keywords) == 0 {
+		closed, ok = word == "DONE", word == "TODO" || word == "DONE"
+	}
+
+	if !ok {
+		return db.Pending, false
+	}
+
+	if state, err := db.ParseStatus(word); err == nil && state.Closed() == closed {
+		return state, true
+	}
+
+	if closed {
+		return db.Done, true
+	}
+
+	return db.Pending, true
+}
+
+func splitTags(s string) []string {
+	tags := []string{}
+
+	for _, tag := range strings.Split(s, ":") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// inheritedTag returns the first tag of the headline or, without one, the
+// first one of the closest headline above it or of the file.
+func inheritedTag(tags []string, parents []parent, fileTags []string) string {
+	if len(tags) > 0 {
+		return tags[0]
+	}
+
+	for i := len(parents) - 1; i >= 0; i-- {
+		if len(parents[i].tags) > 0 {
+			return parents[i].tags[0]
+		}
+	}
+
+	if len(fileTags) > 0 {
+		return fileTags[0]
+	}
+
+	return ""
+}
+
+func setProperty(todo *db.Todo, line string) error {
+	m := propertyPattern.FindStringSubmatch(line)
+
+	if m == nil {
+		return nil
+	}
+
+	switch strings.ToUpper(m[1]) {
+	case idProperty:
+		todo.UID = m[2]
+	case createdProperty:
+		t, ok := parseTimestamp(m[2])
+
+		if !ok {
+			return fmt.Errorf("Not valid %s %q", createdProperty, m[2])
+		}
+
+		todo.DateCreated = t
+	}
+
+	return nil
+}
+
+// setPlanning reads the CLOSED and DEADLINE of a planning line, the lines that
+// don't start with a planning keyword are the notes of the task.
+func setPlanning(todo *db.Todo, line string) error {
+	if !strings.HasPrefix(line, "CLOSED:") && !strings.HasPrefix(line, "DEADLINE:") && !strings.HasPrefix(line, "SCHEDULED:") {
+		return nil
+	}
+
+	for _, name := range []string{"CLOSED", "DEADLINE"} {
+		_, value, ok := strings.Cut(line, name+":")
+
+		if !ok {
+			continue
+		}
+
+		timestamp := datePattern.FindString(value)
+		t, ok := parseTimestamp(timestamp)
+
+		if !ok {
+			return fmt.Errorf("Not valid %s %q", name, strings.TrimSpace(value))
+		}
+
+		if name == "CLOSED" {
+			todo.DateCompleted = sql.NullTime{Time: t, Valid: true}
+		} else {
+			todo.DateDue = sql.NullTime{Time: t, Valid: true}
+		}
+	}
+
+	return nil
+}
+
+// parseTimestamp parses an Org timestamp like <2024-03-01 Fri> or
+// [2024-03-01 Fri 10:00], in the local time zone.
+func parseTimestamp(s string) (time.Time, bool) {
+	m := datePattern.FindStringSubmatch(s)
+
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	value, layout := m[1], "2006-01-02"
+
+	if h := hourPattern.FindStringSubmatch(m[0]); h != nil {
+		value, layout = value+" "+h[1], "2006-01-02 15:04"
+	}
+
+	t, err := time.ParseInLocation(layout, value, time.Local)
+
+	return t, err == nil
+}
@@ -0,0 +1,121 @@
+package org
+
+import (
+	"bytes"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+func local(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.Local)
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		org     string
+		want    []db.Todo
+		wantErr bool
+	}{
+		{
+			name: "default keywords",
+			org:  "* TODO Write\n* DONE Read\n* Not a task\n** NEXT Not a keyword\n",
+			want: []db.Todo{
+				{Todo: "Write", State: db.Pending},
+				{Todo: "Read", State: db.Done},
+			},
+		},
+		{
+			name: "keywords of the file",
+			org: "#+TODO: NEXT BLOCKED | DONE(d!) CANCELLED\n" +
+				"* NEXT [#B] Plan   the trip :travel:\n* BLOCKED Wait\n* CANCELLED Skip\n* TODO Not a keyword here\n",
+			want: []db.Todo{
+				{Todo: "Plan the trip", Tag: "travel", State: db.Pending, Priority: "B"},
+				{Todo: "Wait", State: db.Blocked},
+				{Todo: "Skip", State: db.Cancelled},
+			},
+		},
+		{
+			name: "keywords without separator",
+			org:  "#+TODO: START FINISH\n* START a\n* FINISH b\n",
+			want: []db.Todo{
+				{Todo: "a", State: db.Pending},
+				{Todo: "b", State: db.Done},
+			},
+		},
+		{
+			name: "inherited tags",
+			org: "#+FILETAGS: :inbox:misc:\n* TODO From the file\n* Work :work:office:\n** TODO From the parent\n" +
+				"*** TODO Own tag :urgent:\n* Home\n** TODO Back to the file\n",
+			want: []db.Todo{
+				{Todo: "From the file", Tag: "inbox", State: db.Pending},
+				{Todo: "From the parent", Tag: "work", State: db.Pending},
+				{Todo: "Own tag", Tag: "urgent", State: db.Pending},
+				{Todo: "Back to the file", Tag: "inbox", State: db.Pending},
+			},
+		},
+		{
+			name: "planning and properties",
+			org: "* DONE Ship\nCLOSED: [2024-03-02 Sat 18:30] DEADLINE: <2024-03-05 Tue>\n" +
+				":PROPERTIES:\n:ID: abc\n:CREATED: [2024-03-01 Fri 09:00]\n:END:\nSome notes\n" +
+				"* TODO Reopened\nCLOSED: [2024-03-02 Sat]\n",
+			want: []db.Todo{
+				{
+					Todo:          "Ship",
+					State:         db.Done,
+					UID:           "abc",
+					DateCreated:   local(2024, 3, 1, 9, 0),
+					DateCompleted: sql.NullTime{Time: local(2024, 3, 2, 18, 30), Valid: true},
+					DateDue:       sql.NullTime{Time: local(2024, 3, 5, 0, 0), Valid: true},
+				},
+				{Todo: "Reopened", State: db.Pending},
+			},
+		},
+		{name: "empty title", org: "* TODO :tag:\n", wantErr: true},
+		{name: "not valid deadline", org: "* TODO a\nDEADLINE: <someday>\n", wantErr: true},
+		{name: "not valid created", org: "* TODO a\n:PROPERTIES:\n:CREATED: yesterday\n:END:\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos, err := Read(strings.NewReader(tt.org))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(todos, tt.want) {
+				t.Errorf("Read() = %+v, want %+v", todos, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	todos := []db.Todo{
+		{Todo: "Write the notes", Tag: "docs", State: db.Pending, Priority: "A", UID: "1", DateCreated: local(2024, 3, 1, 9, 0), DateDue: sql.NullTime{Time: local(2024, 3, 10, 0, 0), Valid: true}},
+		{Todo: "Fix the login", Tag: "web_app", State: db.Done, UID: "2", DateCreated: local(2024, 3, 1, 9, 0), DateCompleted: sql.NullTime{Time: local(2024, 3, 2, 18, 30), Valid: true}},
+		{Todo: "Wait for the review", State: db.Blocked, UID: "3", DateCreated: local(2024, 3, 1, 9, 0)},
+		{Todo: "Migrate", State: db.Cancelled, UID: "4", DateCreated: local(2024, 3, 1, 9, 0), DateCompleted: sql.NullTime{Time: local(2024, 3, 3, 8, 0), Valid: true}},
+	}
+
+	var b bytes.Buffer
+
+	if err := Write(&b, todos); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := Read(&b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read, todos) {
+		t.Errorf("Read(Write()) = %+v, want %+v", read, todos)
+	}
+}
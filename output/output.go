@@ -0,0 +1,297 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+	"todo/db"
+	todo_table "todo/todo-table"
+
+	"github.com/mattn/go-isatty"
+	"golang.org/x/term"
+)
+
+type Format string
+
+const (
+	Table    Format = "table"
+	Plain    Format = "plain"
+	JSON     Format = "json"
+	JSONL    Format = "jsonl"
+	CSV      Format = "csv"
+	TSV      Format = "tsv"
+	Markdown Format = "markdown"
+)
+
+var formats = []Format{Table, Plain, JSON, JSONL, CSV, TSV, Markdown}
+
+// ParseFormat parses the value of the --output flag, when it is empty the
+// interactive table is used if the standard output is a terminal and the plain
+// output otherwise.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		if IsTerminal() {
+			return Table, nil
+		}
+		return Plain, nil
+	}
+
+	for _, f := range formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+
+	return "", errors.New("Not valid output, use one of: table, plain, json, jsonl, csv, tsv, markdown")
+}
+
+// HasColumns reports whether the output shows only the columns chosen with
+// --columns, the json and jsonl ones always have every field.
+func (f Format) HasColumns() bool {
+	return f != JSON && f != JSONL
+}
+
+// HasGroups reports whether the output groups the todos by the --group-by
+// value, only the table, plain and markdown ones do.
+func (f Format) HasGroups() bool {
+	return f == Table || f == Plain || f == Markdown
+}
+
+// IsTerminal reports whether the standard output is a terminal.
+func IsTerminal() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// TerminalWidth returns the width of the terminal of the standard output, zero
+// when it isn't a terminal.
+func TerminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+
+	if err != nil {
+		return 0
+	}
+
+	return width
+}
+
+// Record is the representation of a todo in the json and jsonl outputs, the
+// dates use RFC 3339, date_completed is null while the todo is pending,
+// date_due when it has no due date and date_reviewed until it is reviewed. The
+// priority is a letter from A to Z, empty when the todo has none, and the uid
+// identifies the todo in other apps.
+type Record struct {
+	ID            int        `json:"id"`
+	Todo          string     `json:"todo"`
+	State         string     `json:"state"`
+	Tag           string     `json:"tag"`
+	DateCreated   time.Time  `json:"date_created"`
+	DateCompleted *time.Time `json:"date_completed"`
+	DateDue       *time.Time `json:"date_due"`
+	DateReviewed  *time.Time `json:"date_reviewed"`
+	Priority      string     `json:"priority"`
+	UID           string     `json:"uid"`
+}
+
+func NewRecord(todo db.Todo) Record {
+	record := Record{
+		ID:          todo.ID,
+		Todo:        todo.Todo,
+		State:       todo.State.String(),
+		Tag:         todo.Tag,
+		DateCreated: todo.DateCreated,
+		Priority:    todo.Priority,
+		UID:         todo.UID,
+	}
+
+	if todo.DateCompleted.Valid {
+		record.DateCompleted = &todo.DateCompleted.Time
+	}
+
+	if todo.DateDue.Valid {
+		record.DateDue = &todo.DateDue.Time
+	}
+
+	if todo.DateReviewed.Valid {
+		record.DateReviewed = &todo.DateReviewed.Time
+	}
+
+	return record
+}
+
+// Write writes the todos in the given format, the columns are used by the
+// plain, csv, tsv and markdown outputs and the groups by the plain and markdown
+// ones. The table format isn't handled here as it is interactive.
+func Write(w io.Writer, format Format, todos []db.Todo, columns []todo_table.Column, groupBy todo_table.GroupBy) error {
+	switch format {
+	case JSON:
+		return writeJSON(w, todos)
+	case JSONL:
+		return writeJSONL(w, todos)
+	case CSV:
+		return writeSeparated(w, ',', todos, columns)
+	case TSV:
+		return writeSeparated(w, '\t', todos, columns)
+	case Markdown:
+		return writeMarkdown(w, todos, columns, groupBy)
+	case Plain:
+		return writePlain(w, todos, columns, groupBy)
+	default:
+		return fmt.Errorf("output %q can't be written", format)
+	}
+}
+
+// WriteTemplate executes the Go template for every todo, a new line is added
+// after each one unless the template already ends with it.
+func WriteTemplate(w io.Writer, format string, todos []db.Todo) error {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+
+	tmpl, err := template.New("format").Parse(format)
+
+	if err != nil {
+		return fmt.Errorf("Not valid format: %w", err)
+	}
+
+	for _, todo := range todos {
+		if err := tmpl.Execute(w, todo); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeJSON(w io.Writer, todos []db.Todo) error {
+	records := []Record{}
+
+	for _, todo := range todos {
+		records = append(records, NewRecord(todo))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(records)
+}
+
+func writeJSONL(w io.Writer, todos []db.Todo) error {
+	encoder := json.NewEncoder(w)
+
+	for _, todo := range todos {
+		if err := encoder.Encode(NewRecord(todo)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeSeparated(w io.Writer, separator rune, todos []db.Todo, columns []todo_table.Column) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = separator
+
+	if err := writer.Write(headers(columns)); err != nil {
+		return err
+	}
+
+	for _, todo := range todos {
+		if err := writer.Write(values(todo, columns)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func writePlain(w io.Writer, todos []db.Todo, columns []todo_table.Column, groupBy todo_table.GroupBy) error {
+	keys, groups := todo_table.Group(todos, groupBy)
+
+	for i, key := range keys {
+		if groupBy != todo_table.NoGroup {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s (%d)\n", key, len(groups[key]))
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(tw, strings.Join(headers(columns), "\t"))
+
+		for _, todo := range groups[key] {
+			fmt.Fprintln(tw, strings.Join(values(todo, columns), "\t"))
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeMarkdown(w io.Writer, todos []db.Todo, columns []todo_table.Column, groupBy todo_table.GroupBy) error {
+	keys, groups := todo_table.Group(todos, groupBy)
+
+	for i, key := range keys {
+		if groupBy != todo_table.NoGroup {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "## %s (%d)\n\n", key, len(groups[key]))
+		}
+
+		separators := make([]string, len(columns))
+		for i := range separators {
+			separators[i] = "---"
+		}
+
+		fmt.Fprintf(w, "| %s |\n", strings.Join(headers(columns), " | "))
+		fmt.Fprintf(w, "| %s |\n", strings.Join(separators, " | "))
+
+		for _, todo := range groups[key] {
+			cells := values(todo, columns)
+
+			for i, cell := range cells {
+				cells[i] = strings.ReplaceAll(cell, "|", `\|`)
+			}
+
+			if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | ")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func headers(columns []todo_table.Column) []string {
+	headers := make([]string, len(columns))
+
+	for i, column := range columns {
+		headers[i] = column.Title()
+	}
+
+	return headers
+}
+
+func values(todo db.Todo, columns []todo_table.Column) []string {
+	values := make([]string, len(columns))
+
+	for i, column := range columns {
+		values[i] = column.Value(todo)
+	}
+
+	return values
+}
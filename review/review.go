@@ -0,0 +1,428 @@
+package review
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"todo/add"
+	"todo/dates"
+	"todo/db"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Store is the part of the database used by the review, every action is saved
+// right away.
+type Store interface {
+	CompleteTodo(todoId int) error
+	ChangeTodoTag(todoId int, tag string) error
+	SetDueDate(todoId int, due sql.NullTime) error
+	DeleteTodo(todoId int) error
+	MarkReviewed(todoId int) error
+}
+
+type keyMap struct {
+	Keep     key.Binding
+	Complete key.Binding
+	Retag    key.Binding
+	Defer    key.Binding
+	Delete   key.Binding
+	Skip     key.Binding
+	Help     key.Binding
+	Quit     key.Binding
+}
+
+// ShortHelp returns keybindings to be shown in the mini help view. It's part
+// of the key.Map interface.
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Keep, k.Complete, k.Retag, k.Defer, k.Delete, k.Help, k.Quit}
+}
+
+// FullHelp returns keybindings for the expanded help view. It's part of the
+// key.Map interface.
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Keep, k.Complete, k.Retag}, // first column
+		{k.Defer, k.Delete, k.Skip},   // second column
+		{k.Help, k.Quit},              // third column
+	}
+}
+
+var keys = keyMap{
+	Keep: key.NewBinding(
+		key.WithKeys("k"),
+		key.WithHelp("k", "keep"),
+	),
+	Complete: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "complete"),
+	),
+	Retag: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "retag"),
+	),
+	Defer: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "defer"),
+	),
+	Delete: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "delete"),
+	),
+	Skip: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "skip without reviewing"),
+	),
+	Help: key.NewBinding(
+		key.WithKeys("?"),
+		key.WithHelp("?", "toggle help"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "ctrl+c"),
+		key.WithHelp("q/ctrl+c", "quit"),
+	),
+}
+
+// The keys used while an input or a confirmation is open.
+var (
+	confirmKey = key.NewBinding(key.WithKeys("enter"))
+	cancelKey  = key.NewBinding(key.WithKeys("esc", "ctrl+c"))
+	yesKey     = key.NewBinding(key.WithKeys("y", "Y"))
+)
+
+// deferDays is how far the due date is proposed when deferring a task.
+const deferDays = 7
+
+var (
+	cardStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("57")).
+			Padding(0, 1)
+
+	titleStyle = lipgloss.NewStyle().
+			Bold(true).
+			MarginBottom(1)
+
+	labelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+
+	statusStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196"))
+)
+
+type mode int
+
+const (
+	reviewing mode = iota
+	retagging
+	deferring
+	deleting
+)
+
+// Model walks through the todos one by one, every action but skip records
+// that the todo has been reviewed.
+type Model struct {
+	store    Store
+	keys     keyMap
+	help     help.Model
+	progress progress.Model
+	input    add.Model
+	mode     mode
+	todos    []db.Todo
+	current  int
+	actions  map[string]int
+	message  string
+	err      error
+}
+
+// New returns the review of the todos, which should be sorted with Queue.
+func New(store Store, todos []db.Todo) Model {
+	return Model{
+		store:    store,
+		keys:     keys,
+		help:     help.New(),
+		progress: progress.New(progress.WithDefaultGradient(), progress.WithoutPercentage()),
+		todos:    todos,
+		actions:  map[string]int{},
+	}
+}
+
+// Queue sorts the todos in the order they are reviewed, first the ones never
+// reviewed and then the ones reviewed longest ago, the oldest first when
+// tied.
+func Queue(todos []db.Todo) {
+	sort.SliceStable(todos, func(i, j int) bool {
+		a, b := todos[i], todos[j]
+
+		if a.DateReviewed.Valid != b.DateReviewed.Valid {
+			return !a.DateReviewed.Valid
+		}
+
+		if a.DateReviewed.Valid && !a.DateReviewed.Time.Equal(b.DateReviewed.Time) {
+			return a.DateReviewed.Time.Before(b.DateReviewed.Time)
+		}
+
+		return a.DateCreated.Before(b.DateCreated)
+	})
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.help.Width = msg.Width
+		m.progress.Width = min(msg.Width-4, 60)
+		return m, nil
+	case tea.KeyMsg:
+		switch m.mode {
+		case retagging, deferring:
+			return m.updateInput(msg)
+		case deleting:
+			return m.updateDelete(msg)
+		}
+
+		if key.Matches(msg, m.keys.Quit) {
+			return m, tea.Quit
+		}
+
+		if key.Matches(msg, m.keys.Help) {
+			m.help.ShowAll = !m.help.ShowAll
+			return m, nil
+		}
+
+		todo, ok := m.currentTodo()
+
+		if !ok {
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Keep):
+			m.next("kept", nil)
+		case key.Matches(msg, m.keys.Complete):
+			m.next("completed", m.store.CompleteTodo(todo.ID))
+		case key.Matches(msg, m.keys.Skip):
+			m.message = fmt.Sprintf("task with the id %d skipped.", todo.ID)
+			m.err = nil
+			m.current++
+		case key.Matches(msg, m.keys.Delete):
+			m.mode = deleting
+		case key.Matches(msg, m.keys.Retag):
+			m.mode = retagging
+			m.err = nil
+			m.input = add.TagInputModel(todo.Tag)
+			return m, m.input.Init()
+		case key.Matches(msg, m.keys.Defer):
+			m.mode = deferring
+			m.err = nil
+			m.input = add.DeferInputModel(dates.StartOfDay(time.Now()).AddDate(0, 0, deferDays).Format("2006-01-02"))
+			return m, m.input.Init()
+		}
+	}
+
+	return m, nil
+}
+
+// updateInput handles the keys while retagging or deferring a task, the add
+// model quits the program on enter and esc so those keys aren't passed to it.
+func (m Model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, cancelKey):
+		m.mode = reviewing
+		return m, nil
+	case key.Matches(msg, confirmKey):
+		updated, _ := m.input.Update(msg)
+		value := strings.TrimSpace(updated.(add.Model).Value)
+		todo, _ := m.currentTodo()
+
+		if m.mode == retagging {
+			m.next("retagged", m.store.ChangeTodoTag(todo.ID, value))
+			m.mode = reviewing
+			return m, nil
+		}
+
+		due, err := dates.ParseDay(value, time.Now())
+
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+
+		m.next("deferred", m.store.SetDueDate(todo.ID, sql.NullTime{Time: due, Valid: true}))
+		m.mode = reviewing
+		return m, nil
+	}
+
+	updated, cmd := m.input.Update(msg)
+	m.input = updated.(add.Model)
+	return m, cmd
+}
+
+// updateDelete asks for confirmation before deleting the task.
+func (m Model) updateDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.mode = reviewing
+
+	if !key.Matches(msg, yesKey) {
+		return m, nil
+	}
+
+	todo, _ := m.currentTodo()
+
+	if err := m.store.DeleteTodo(todo.ID); err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	m.actions["deleted"]++
+	m.message = fmt.Sprintf("task with the id %d deleted.", todo.ID)
+	m.err = nil
+	m.current++
+
+	return m, nil
+}
+
+// next records the review of the current todo after the action and moves to
+// the following one, the todo stays when the action failed.
+func (m *Model) next(action string, err error) {
+	todo, _ := m.currentTodo()
+
+	if err == nil {
+		err = m.store.MarkReviewed(todo.ID)
+	}
+
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	m.actions[action]++
+	m.message = fmt.Sprintf("task with the id %d %s.", todo.ID, action)
+	m.err = nil
+	m.current++
+}
+
+func (m Model) currentTodo() (db.Todo, bool) {
+	if m.current >= len(m.todos) {
+		return db.Todo{}, false
+	}
+	return m.todos[m.current], true
+}
+
+func (m Model) View() string {
+	todo, ok := m.currentTodo()
+
+	if !ok {
+		return m.summaryView() + "\n" + m.statusView() + "\n" + m.help.ShortHelpView([]key.Binding{m.keys.Quit})
+	}
+
+	position := fmt.Sprintf("%d of %d", m.current+1, len(m.todos))
+	view := titleStyle.Render("Review "+position) + "\n" +
+		m.progress.ViewAs(float64(m.current)/float64(len(m.todos))) + "\n\n" +
+		cardStyle.Render(cardView(todo)) + "\n"
+
+	switch m.mode {
+	case retagging, deferring:
+		view += m.input.View()
+
+		// A date that couldn't be parsed is shown under the input, which
+		// stays open to fix it.
+		if m.err != nil {
+			view += "\n" + m.statusView()
+		}
+	case deleting:
+		view += errorStyle.Render(fmt.Sprintf("delete task %d %q? y/N", todo.ID, todo.Todo)) + "\n"
+	default:
+		view += m.statusView() + "\n" + m.help.View(m.keys)
+	}
+
+	return view
+}
+
+func cardView(todo db.Todo) string {
+	reviewed := "never"
+	if todo.DateReviewed.Valid {
+		reviewed = ago(todo.DateReviewed.Time)
+	}
+
+	due := "-"
+	if todo.DateDue.Valid {
+		due = todo.DateDue.Time.Format("2006-01-02")
+	}
+
+	tag := todo.Tag
+	if tag == "" {
+		tag = "-"
+	}
+
+	rows := [][2]string{
+		{"tag", tag},
+		{"state", todo.State.String()},
+		{"created", todo.DateCreated.Format("2006-01-02") + " (" + ago(todo.DateCreated) + ")"},
+		{"due", due},
+		{"reviewed", reviewed},
+	}
+
+	lines := []string{titleStyle.Render(fmt.Sprintf("#%d %s", todo.ID, todo.Todo))}
+
+	for _, row := range rows {
+		lines = append(lines, labelStyle.Render(fmt.Sprintf("%-9s", row[0]))+row[1])
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (m Model) summaryView() string {
+	if len(m.todos) == 0 {
+		return titleStyle.Render("There are no pending tasks to review.")
+	}
+
+	counts := []string{}
+	reviewed := 0
+
+	for _, action := range []string{"kept", "completed", "retagged", "deferred", "deleted"} {
+		reviewed += m.actions[action]
+
+		if m.actions[action] > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", m.actions[action], action))
+		}
+	}
+
+	if len(counts) == 0 {
+		counts = append(counts, "nothing changed")
+	}
+
+	return titleStyle.Render(fmt.Sprintf("Review finished, %d of %d tasks reviewed", reviewed, len(m.todos))) + "\n" + strings.Join(counts, " · ")
+}
+
+func (m Model) statusView() string {
+	if m.err != nil {
+		return errorStyle.Render(m.err.Error())
+	}
+	return statusStyle.Render(m.message)
+}
+
+// ago returns how long ago the time was in days, or today.
+func ago(t time.Time) string {
+	days := dates.DaysBetween(t.Local(), time.Now())
+
+	switch {
+	case days <= 0:
+		return "today"
+	case days == 1:
+		return "yesterday"
+	default:
+		return fmt.Sprintf("%d days ago", days)
+	}
+}
@@ -0,0 +1,121 @@
+package review
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"todo/db"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestQueue(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 3, d, 9, 0, 0, 0, time.UTC)
+	}
+
+	reviewed := func(d int) sql.NullTime {
+		return sql.NullTime{Time: day(d), Valid: true}
+	}
+
+	todos := []db.Todo{
+		{ID: 1, DateCreated: day(1), DateReviewed: reviewed(10)},
+		{ID: 2, DateCreated: day(5)},
+		{ID: 3, DateCreated: day(2), DateReviewed: reviewed(8)},
+		{ID: 4, DateCreated: day(3)},
+		{ID: 5, DateCreated: day(4), DateReviewed: reviewed(8)},
+		{ID: 6, DateCreated: day(1), DateReviewed: reviewed(10)},
+	}
+
+	Queue(todos)
+
+	got := []int{}
+	for _, todo := range todos {
+		got = append(got, todo.ID)
+	}
+
+	// The ones never reviewed first, then the ones reviewed longest ago, the
+	// oldest first and the order they had when tied.
+	if want := []int{4, 2, 3, 5, 1, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Queue() = %v, want %v", got, want)
+	}
+}
+
+type fakeStore struct {
+	err      error // returned by every action but MarkReviewed
+	reviewed []int
+	deleted  []int
+}
+
+func (s *fakeStore) CompleteTodo(todoId int) error                 { return s.err }
+func (s *fakeStore) ChangeTodoTag(todoId int, tag string) error    { return s.err }
+func (s *fakeStore) SetDueDate(todoId int, due sql.NullTime) error { return s.err }
+
+func (s *fakeStore) DeleteTodo(todoId int) error {
+	if s.err == nil {
+		s.deleted = append(s.deleted, todoId)
+	}
+
+	return s.err
+}
+
+func (s *fakeStore) MarkReviewed(todoId int) error {
+	s.reviewed = append(s.reviewed, todoId)
+	return nil
+}
+
+func press(m Model, keys ...string) Model {
+	for _, k := range keys {
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = updated.(Model)
+	}
+
+	return m
+}
+
+func TestNext(t *testing.T) {
+	failure := errors.New("database is locked")
+
+	tests := []struct {
+		name     string
+		err      error
+		keys     []string
+		current  int
+		reviewed []int
+		deleted  []int
+	}{
+		{name: "keep", keys: []string{"k"}, current: 1, reviewed: []int{1}},
+		{name: "complete", keys: []string{"c"}, current: 1, reviewed: []int{1}},
+		{name: "complete failed", err: failure, keys: []string{"c"}, current: 0},
+		{name: "skip", keys: []string{"s", "k"}, current: 2, reviewed: []int{2}},
+		{name: "delete", keys: []string{"x", "y"}, current: 1, deleted: []int{1}},
+		{name: "delete failed", err: failure, keys: []string{"x", "y"}, current: 0},
+		{name: "delete cancelled", keys: []string{"x", "n"}, current: 0},
+		{name: "past the last one", keys: []string{"k", "k", "k"}, current: 2, reviewed: []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{err: tt.err}
+			m := press(New(store, []db.Todo{{ID: 1}, {ID: 2}}), tt.keys...)
+
+			if m.current != tt.current {
+				t.Errorf("current = %d, want %d", m.current, tt.current)
+			}
+
+			if !reflect.DeepEqual(store.reviewed, tt.reviewed) {
+				t.Errorf("reviewed = %v, want %v", store.reviewed, tt.reviewed)
+			}
+
+			if !reflect.DeepEqual(store.deleted, tt.deleted) {
+				t.Errorf("deleted = %v, want %v", store.deleted, tt.deleted)
+			}
+
+			if !errors.Is(m.err, tt.err) || (tt.err == nil) != (m.err == nil) {
+				t.Errorf("err = %v, want %v", m.err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package scan
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// rule is a pattern of a .gitignore file, it only applies to the paths inside
+// the directory of the file.
+type rule struct {
+	base    string
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// readIgnore returns the rules of the .gitignore of the directory, none when
+// it doesn't have one.
+func readIgnore(dir string) ([]rule, error) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	rules := []rule{}
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		if r, ok := parseRule(dir, scanner.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// parseRule parses a line of a .gitignore, false when it is blank or a
+// comment. A pattern with a slash is relative to the directory of the
+// .gitignore and one without it matches the names at any depth.
+func parseRule(base, line string) (rule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	r := rule{base: base}
+
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if line == "" {
+		return rule{}, false
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	prefix := "^(?:.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+
+	pattern, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+
+	if err != nil {
+		return rule{}, false
+	}
+
+	r.pattern = pattern
+
+	return r, true
+}
+
+// globToRegexp translates the wildcards of a pattern, ** matches any number
+// of directories and the rest of wildcards don't match a slash.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+
+			if end == -1 {
+				b.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
+
+// ignored reports whether the path is ignored by the rules, the last rule
+// matching it wins as in git.
+func ignored(rules []rule, path string, isDir bool) bool {
+	result := false
+
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+
+		rel, err := filepath.Rel(r.base, path)
+
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		if r.pattern.MatchString(filepath.ToSlash(rel)) {
+			result = !r.negate
+		}
+	}
+
+	return result
+}
+
+// parentRules returns the rules of the .gitignore files of the directories
+// above dir up to the root of its git repository, none when dir isn't inside
+// one. They come first so the ones of the directories scanned win.
+func parentRules(dir string) ([]rule, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return nil, nil
+	}
+
+	parents := []string{}
+
+	for d := filepath.Dir(dir); ; d = filepath.Dir(d) {
+		parents = append([]string{d}, parents...)
+
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+
+		if d == filepath.Dir(d) {
+			return nil, nil
+		}
+	}
+
+	rules := []rule{}
+
+	for _, d := range parents {
+		r, err := readIgnore(d)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, r...)
+	}
+
+	return rules, nil
+}
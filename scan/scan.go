@@ -0,0 +1,422 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"todo/db"
+)
+
+// DefaultTag is the tag of the tasks of the comments unless another one is
+// chosen.
+const DefaultTag = "code"
+
+// maxFileSize is the size of the biggest file read, the bigger ones are
+// usually generated.
+const maxFileSize = 1 << 20
+
+// skippedExtensions are the documents, which mention TODO without being code.
+var skippedExtensions = map[string]bool{
+	".md": true, ".markdown": true, ".txt": true, ".rst": true, ".org": true, ".adoc": true,
+}
+
+// commentPattern matches a TODO, FIXME or XXX after the start of a comment,
+// with the owner in parentheses that some projects add.
+var commentPattern = regexp.MustCompile(`(?:^|\s)(?://+|#+|/\*+|\*|--|;+|<!--|%+)\s*(TODO|FIXME|XXX)(?:\([^)]*\))?(?:[:\s]\s*(.*?))?\s*(?:\*/|-->)?\s*$`)
+
+// commentStartPattern matches the start of a comment in any of the languages.
+var commentStartPattern = regexp.MustCompile(`(?:^|\s)(?://|#|/\*|\*|--|;|<!--|%)`)
+
+// Store is the part of the database used by the scan.
+type Store interface {
+	GetTasksByFilter(filter db.Filter) ([]db.Todo, error)
+	AddTodo(todo db.Todo) (int, error)
+	CompleteTodo(todoId int) error
+	SyncItems(source string) ([]db.SyncItem, error)
+	SaveSyncItems(source string, items []db.SyncItem) error
+	Commit() error
+}
+
+// Report counts the tasks changed by a scan, the updated ones are the tasks
+// whose comment moved.
+type Report struct {
+	Created, Updated, Closed, Unchanged int
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d closed, %d unchanged", r.Created, r.Updated, r.Closed, r.Unchanged)
+}
+
+// Comment is a TODO, FIXME or XXX comment of a file.
+type Comment struct {
+	Path string // relative to the directory scanned
+	Line int
+	Kind string
+	Text string
+	// nth counts the comments of the file with the same kind and text before
+	// this one, so copies of a comment are different tasks.
+	nth int
+}
+
+// Title returns the title of the task of the comment, the place of the
+// comment is kept in its sync item instead so moving it doesn't change the
+// task.
+func (c Comment) Title() string {
+	if c.Text == "" {
+		return c.Kind
+	}
+
+	return c.Kind + ": " + c.Text
+}
+
+// key identifies the comment between scans, the line isn't part of it as it
+// changes when the code above it does.
+func (c Comment) key() string {
+	return strings.Join([]string{c.Path, c.Kind, c.Text, strconv.Itoa(c.nth)}, "\n")
+}
+
+// Scan creates a task tagged with the tag for every TODO, FIXME and XXX comment
+// of the files of the directory, skipping the ones ignored by git. The sync
+// items remember the task of every comment, with the file and line of the
+// comment as the remote id, so scanning again only updates the place of the
+// comments that moved and closes the tasks of the comments removed instead of
+// duplicating them. The title and the tag of a task aren't changed after it is
+// created, and a task deleted isn't created again while its comment is still
+// there.
+//
+// The store is a transaction, like the one of db.Begin, the tasks and the sync
+// items are committed together so a failed scan doesn't leave tasks the next
+// one would create again.
+func Scan(store Store, dir, tag string) (Report, error) {
+	var report Report
+
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return report, err
+	}
+
+	source := "scan:" + dir
+
+	comments, err := Find(dir)
+
+	if err != nil {
+		return report, err
+	}
+
+	todos, err := store.GetTasksByFilter(db.Filter{})
+
+	if err != nil {
+		return report, err
+	}
+
+	saved, err := store.SyncItems(source)
+
+	if err != nil {
+		return report, err
+	}
+
+	tasks := map[int]db.Todo{}
+	for _, todo := range todos {
+		tasks[todo.ID] = todo
+	}
+
+	byKey := map[string]db.SyncItem{}
+	for _, item := range saved {
+		byKey[item.Snapshot] = item
+	}
+
+	found := map[string]bool{}
+	items := []db.SyncItem{}
+
+	for _, comment := range comments {
+		key := comment.key()
+		found[key] = true
+		place := comment.Path + ":" + strconv.Itoa(comment.Line)
+
+		item, ok := byKey[key]
+
+		if !ok {
+			id, err := store.AddTodo(db.Todo{
+				Todo:        comment.Title(),
+				Tag:         tag,
+				State:       db.Pending,
+				DateCreated: time.Now(),
+			})
+
+			if err != nil {
+				return report, err
+			}
+
+			items = append(items, db.SyncItem{TodoID: id, RemoteID: place, Snapshot: key})
+			report.Created++
+			continue
+		}
+
+		moved := item.RemoteID != place
+		item.RemoteID = place
+		items = append(items, item)
+
+		if _, exists := tasks[item.TodoID]; !exists {
+			continue
+		}
+
+		if moved {
+			report.Updated++
+		} else {
+			report.Unchanged++
+		}
+	}
+
+	for _, item := range saved {
+		if found[item.Snapshot] {
+			continue
+		}
+
+		if task, exists := tasks[item.TodoID]; exists && !task.State.Closed() {
+			if err := store.CompleteTodo(task.ID); err != nil {
+				return report, err
+			}
+
+			report.Closed++
+		}
+	}
+
+	if err := store.SaveSyncItems(source, items); err != nil {
+		return report, err
+	}
+
+	return report, store.Commit()
+}
+
+// Task is an open task of a comment with the place of the comment.
+type Task struct {
+	db.Todo
+	Path string // relative to the directory scanned
+	Line int
+}
+
+// TaskStore is the part of the database used to list the tasks of the
+// comments.
+type TaskStore interface {
+	GetTasksByFilter(filter db.Filter) ([]db.Todo, error)
+	SyncItems(source string) ([]db.SyncItem, error)
+}
+
+// Tasks returns the open tasks of the comments found by the last scan of the
+// directory, sorted by the place of their comment.
+func Tasks(store TaskStore, dir string) ([]Task, error) {
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := store.SyncItems("scan:" + dir)
+
+	if err != nil || len(items) == 0 {
+		return []Task{}, err
+	}
+
+	ids := []int{}
+	for _, item := range items {
+		ids = append(ids, item.TodoID)
+	}
+
+	todos, err := store.GetTasksByFilter(db.Filter{IDs: ids, Open: true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	open := map[int]db.Todo{}
+	for _, todo := range todos {
+		open[todo.ID] = todo
+	}
+
+	tasks := []Task{}
+
+	for _, item := range items {
+		todo, ok := open[item.TodoID]
+
+		if !ok {
+			continue
+		}
+
+		i := strings.LastIndex(item.RemoteID, ":")
+		line, _ := strconv.Atoi(item.RemoteID[i+1:])
+
+		tasks = append(tasks, Task{Todo: todo, Path: item.RemoteID[:max(i, 0)], Line: line})
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Path != tasks[j].Path {
+			return tasks[i].Path < tasks[j].Path
+		}
+
+		return tasks[i].Line < tasks[j].Line
+	})
+
+	return tasks, nil
+}
+
+// Find returns the comments of the files of the directory and its
+// subdirectories, the .git directory, the files ignored by the .gitignore
+// files, the documents and the binary and big files are skipped.
+func Find(dir string) ([]Comment, error) {
+	info, err := os.Stat(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("Not valid directory %q, it is a file", dir)
+	}
+
+	rules, err := parentRules(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	comments := []Comment{}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != dir && (d.Name() == ".git" || ignored(rules, path, true)) {
+				return filepath.SkipDir
+			}
+
+			more, err := readIgnore(path)
+			rules = append(rules, more...)
+
+			return err
+		}
+
+		if !d.Type().IsRegular() || skippedExtensions[strings.ToLower(filepath.Ext(path))] || ignored(rules, path, false) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+
+		if err != nil {
+			return err
+		}
+
+		found, err := findInFile(path, filepath.ToSlash(rel))
+		comments = append(comments, found...)
+
+		return err
+	})
+
+	return comments, err
+}
+
+func findInFile(path, rel string) ([]Comment, error) {
+	info, err := os.Stat(path)
+
+	if err != nil || info.Size() > maxFileSize {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A NUL byte at the start tells the binary files, as git does.
+	if bytes.IndexByte(content[:min(len(content), 8000)], 0) != -1 {
+		return nil, nil
+	}
+
+	comments := []Comment{}
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, maxFileSize)
+
+	for n := 1; scanner.Scan(); n++ {
+		m := findComment(scanner.Text())
+
+		if m == nil {
+			continue
+		}
+
+		comment := Comment{Path: rel, Line: n, Kind: m[1], Text: strings.Join(strings.Fields(m[2]), " ")}
+		comment.nth = seen[comment.Kind+"\n"+comment.Text]
+		seen[comment.Kind+"\n"+comment.Text]++
+
+		comments = append(comments, comment)
+	}
+
+	return comments, scanner.Err()
+}
+
+// findComment returns the submatches of commentPattern in the comment of the
+// line, nil when it doesn't have one. The comment starts at the first start of
+// a comment outside of the string literals, so a TODO inside a string isn't a
+// comment.
+func findComment(line string) []string {
+	for _, start := range commentStartPattern.FindAllStringIndex(line, -1) {
+		if !inString(line, start[0]) {
+			return commentPattern.FindStringSubmatch(line[start[0]:])
+		}
+	}
+
+	return nil
+}
+
+// inString reports whether the position of the line is inside a string
+// literal, between two double, single or back quotes. A quote without its
+// closing one on the line, like the ' of a Lisp symbol, doesn't start one.
+func inString(line string, pos int) bool {
+	for i := 0; i < pos; i++ {
+		quote := line[i]
+
+		if quote != '"' && quote != '\'' && quote != '`' {
+			continue
+		}
+
+		end := closingQuote(line, i)
+
+		if end == -1 {
+			continue
+		}
+
+		if pos <= end {
+			return true
+		}
+
+		i = end
+	}
+
+	return false
+}
+
+// closingQuote returns the index of the quote that closes the one at start,
+// skipping the escaped ones, or -1 when the line doesn't have it.
+func closingQuote(line string, start int) int {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case line[start]:
+			return i
+		}
+	}
+
+	return -1
+}
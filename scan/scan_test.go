@@ -0,0 +1,266 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"todo/db"
+)
+
+// writeFiles creates the files with their contents inside dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string // path:line kind text
+	}{
+		{
+			name: "comment styles",
+			files: map[string]string{
+				"a.go":   "package a\n// TODO: first\n/* FIXME second */\nx := 1 // XXX(ana): third\n",
+				"b.py":   "# TODO\nprint(1)  # FIXME: fourth\n",
+				"c.sql":  "-- TODO fifth\n",
+				"d.html": "<!-- TODO: sixth -->\n",
+				"e.el":   "(foo 'bar) ; TODO seventh\n",
+			},
+			want: []string{
+				"a.go:2 TODO first",
+				"a.go:3 FIXME second",
+				"a.go:4 XXX third",
+				"b.py:1 TODO ",
+				"b.py:2 FIXME fourth",
+				"c.sql:1 TODO fifth",
+				"d.html:1 TODO sixth",
+				"e.el:1 TODO seventh",
+			},
+		},
+		{
+			name: "not comments",
+			files: map[string]string{
+				"a.go": "package a\n" +
+					"var s = \"a # TODO not a comment\"\n" +
+					"var r = `raw // TODO no`\n" +
+					"var e = \"esc \\\" // TODO no\"\n" +
+					"var c = \"x\" // TODO after a string\n" +
+					"var todo = 1 // TODOS aren't markers\n",
+				"notes.md":  "# TODO in a document\n",
+				"binary.go": "\x00// TODO in a binary\n",
+			},
+			want: []string{"a.go:5 TODO after a string"},
+		},
+		{
+			name: "gitignore",
+			files: map[string]string{
+				".git/HEAD":         "// TODO in the git directory\n",
+				".gitignore":        "/build\n*.gen.go\nvendor/\n!keep.gen.go\n",
+				"main.go":           "// TODO kept\n",
+				"build/out.go":      "// TODO ignored build\n",
+				"src/build/in.go":   "// TODO kept nested build\n",
+				"api.gen.go":        "// TODO ignored generated\n",
+				"keep.gen.go":       "// TODO kept negated\n",
+				"vendor/lib/lib.go": "// TODO ignored vendor\n",
+				"sub/.gitignore":    "*.py\n",
+				"sub/script.py":     "# TODO ignored by the nested gitignore\n",
+				"other/script.py":   "# TODO kept outside the nested gitignore\n",
+			},
+			want: []string{
+				"keep.gen.go:1 TODO kept negated",
+				"main.go:1 TODO kept",
+				"other/script.py:1 TODO kept outside the nested gitignore",
+				"src/build/in.go:1 TODO kept nested build",
+			},
+		},
+		{
+			name: "copies of a comment",
+			files: map[string]string{
+				"a.go": "// TODO same\n// TODO same\n",
+			},
+			want: []string{"a.go:1 TODO same", "a.go:2 TODO same"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			comments, err := Find(dir)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, c := range comments {
+				got = append(got, c.Path+":"+strconv.Itoa(c.Line)+" "+c.Kind+" "+c.Text)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindParentGitignore(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		".git/HEAD":          "",
+		".gitignore":         "generated/\n",
+		"app/main.go":        "// TODO kept\n",
+		"app/generated/a.go": "// TODO ignored by the gitignore of the repository\n",
+	})
+
+	comments, err := Find(filepath.Join(repo, "app"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(comments) != 1 || comments[0].Path != "main.go" {
+		t.Errorf("Find() = %+v, want only the comment of main.go", comments)
+	}
+}
+
+func TestCommentTitle(t *testing.T) {
+	tests := []struct {
+		comment Comment
+		want    string
+	}{
+		{comment: Comment{Path: "a.go", Line: 3, Kind: "TODO", Text: "fix it"}, want: "TODO: fix it"},
+		{comment: Comment{Path: "a.go", Line: 3, Kind: "FIXME"}, want: "FIXME"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.comment.Title(); got != tt.want {
+			t.Errorf("Title() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+type fakeStore struct {
+	todos map[int]db.Todo
+	items map[string][]db.SyncItem
+}
+
+func (s *fakeStore) GetTasksByFilter(filter db.Filter) ([]db.Todo, error) {
+	todos := []db.Todo{}
+	for _, todo := range s.todos {
+		if !filter.Open || !todo.State.Closed() {
+			todos = append(todos, todo)
+		}
+	}
+
+	return todos, nil
+}
+
+func (s *fakeStore) AddTodo(todo db.Todo) (int, error) {
+	todo.ID = len(s.todos) + 1
+	s.todos[todo.ID] = todo
+
+	return todo.ID, nil
+}
+
+func (s *fakeStore) CompleteTodo(todoId int) error {
+	todo := s.todos[todoId]
+	todo.State = db.Done
+	s.todos[todoId] = todo
+
+	return nil
+}
+
+func (s *fakeStore) SyncItems(source string) ([]db.SyncItem, error) {
+	return s.items[source], nil
+}
+
+func (s *fakeStore) SaveSyncItems(source string, items []db.SyncItem) error {
+	s.items[source] = items
+	return nil
+}
+
+func (s *fakeStore) Commit() error {
+	return nil
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.go": "package a\n// TODO: first\n// FIXME: second\n",
+	})
+
+	store := &fakeStore{todos: map[int]db.Todo{}, items: map[string][]db.SyncItem{}}
+
+	report, err := Scan(store, dir, "code")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (Report{Created: 2}); report != want {
+		t.Errorf("Scan() = %v, want %v", report, want)
+	}
+
+	// The tag chosen for the task is kept when scanning with another one, and
+	// the comment that moved only changes its place.
+	todo := store.todos[1]
+	todo.Tag = "backend"
+	store.todos[1] = todo
+
+	writeFiles(t, dir, map[string]string{
+		"a.go": "package a\n\n// TODO: first\n",
+	})
+
+	report, err = Scan(store, dir, "other")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (Report{Updated: 1, Closed: 1}); report != want {
+		t.Errorf("second Scan() = %v, want %v", report, want)
+	}
+
+	if store.todos[1].Tag != "backend" || store.todos[1].State != db.Pending {
+		t.Errorf("task of the comment moved = %+v, want it untouched", store.todos[1])
+	}
+
+	if store.todos[2].State != db.Done {
+		t.Errorf("task of the comment removed is %s, want done", store.todos[2].State)
+	}
+
+	items := store.items["scan:"+dir]
+
+	if len(items) != 1 || items[0].RemoteID != "a.go:3" {
+		t.Errorf("sync items = %+v, want the new place of the comment", items)
+	}
+
+	tasks, err := Tasks(store, dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tasks) != 1 || tasks[0].ID != 1 || tasks[0].Path != "a.go" || tasks[0].Line != 3 {
+		t.Errorf("Tasks() = %+v, want the open task at a.go:3", tasks)
+	}
+}
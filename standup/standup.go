@@ -0,0 +1,146 @@
+package standup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"todo/dates"
+	"todo/db"
+	todo_table "todo/todo-table"
+)
+
+type Format string
+
+const (
+	Markdown Format = "markdown"
+	Slack    Format = "slack"
+)
+
+// ParseFormat parses the value of the --output flag of the standup.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case Markdown, Slack:
+		return Format(s), nil
+	default:
+		return "", errors.New("Not valid output, use one of: markdown, slack")
+	}
+}
+
+// Report is the standup, the tasks done since the last one, the ones planned
+// for today and the blocked ones.
+type Report struct {
+	Since   time.Time
+	Done    []db.Todo
+	Planned []db.Todo
+	Blocked []db.Todo
+}
+
+// LastWorkday returns the start of the previous working day, so on Monday the
+// report covers Friday.
+func LastWorkday(now time.Time) time.Time {
+	day := dates.StartOfDay(now).AddDate(0, 0, -1)
+
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return day
+}
+
+// ParseSince parses the value of the --since flag, a day as accepted by
+// dates.ParseDay where yesterday skips the weekend.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if s == "yesterday" {
+		return LastWorkday(now), nil
+	}
+	return dates.ParseDay(s, now)
+}
+
+// Build splits the todos in the sections of the report, the done ones are the
+// ones completed since the day passed and the planned ones are the rest of the
+// open ones but the backlog, the ones furthest in the workflow first.
+func Build(todos []db.Todo, since time.Time) Report {
+	report := Report{Since: since}
+
+	for _, todo := range todos {
+		switch {
+		case todo.DateCompleted.Valid && !todo.DateCompleted.Time.Before(since):
+			report.Done = append(report.Done, todo)
+		case todo.State == db.Blocked:
+			report.Blocked = append(report.Blocked, todo)
+		case !todo.State.Closed() && todo.State != db.Backlog:
+			report.Planned = append(report.Planned, todo)
+		}
+	}
+
+	todo_table.SortTodos(report.Done, todo_table.Order{Field: todo_table.SortByCompleted})
+	todo_table.SortTodos(report.Planned, todo_table.Order{Field: todo_table.SortByState, Descending: true})
+
+	return report
+}
+
+// Write writes the report as Markdown or as the plain text with the bold and
+// the bullets Slack understands.
+func Write(w io.Writer, format Format, report Report) error {
+	sections := []struct {
+		title string
+		todos []db.Todo
+	}{
+		{"Done since last standup", report.Done},
+		{"Planned today", report.Planned},
+		{"Blocked", report.Blocked},
+	}
+
+	var b strings.Builder
+
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		if format == Slack {
+			fmt.Fprintf(&b, "*%s*\n", section.title)
+		} else {
+			fmt.Fprintf(&b, "## %s\n\n", section.title)
+		}
+
+		if len(section.todos) == 0 {
+			b.WriteString(bullet(format) + "nothing\n")
+		}
+
+		for _, todo := range section.todos {
+			b.WriteString(bullet(format) + item(format, todo) + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func bullet(format Format) string {
+	if format == Slack {
+		return "• "
+	}
+	return "- "
+}
+
+func item(format Format, todo db.Todo) string {
+	s := todo.Todo
+
+	if todo.State == db.InProgress {
+		s += " (in progress)"
+	}
+
+	if todo.Tag != "" {
+		if format == Slack {
+			s += " [" + todo.Tag + "]"
+		} else {
+			s += " `" + todo.Tag + "`"
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,311 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"todo/dates"
+	"todo/db"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// oldestPending is how many pending tasks are listed in the report.
+const oldestPending = 5
+
+var (
+	panelStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("240")).
+			Padding(0, 1)
+
+	panelTitleStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("212"))
+
+	labelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+)
+
+// Report holds the statistics of the tasks created in a period, the lead time
+// is the time from the creation of a task to its completion.
+type Report struct {
+	From             time.Time   `json:"from"`
+	To               time.Time   `json:"to"`
+	Total            int         `json:"total"`
+	Completed        int         `json:"completed"`
+	Pending          int         `json:"pending"`
+	CompletionRate   float64     `json:"completion_rate"`
+	AverageLeadTime  Duration    `json:"average_lead_time"`
+	MedianLeadTime   Duration    `json:"median_lead_time"`
+	CompletedPerDay  float64     `json:"completed_per_day"`
+	CompletedPerWeek float64     `json:"completed_per_week"`
+	BusiestWeekday   string      `json:"busiest_weekday"`
+	OldestPending    []Task      `json:"oldest_pending"`
+	Tags             []TagReport `json:"tags"`
+}
+
+// Task is a pending task of the report.
+type Task struct {
+	ID          int       `json:"id"`
+	Todo        string    `json:"todo"`
+	Tag         string    `json:"tag"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// TagReport holds the statistics of the tasks with a tag, the tasks without
+// tag use an empty one.
+type TagReport struct {
+	Tag             string   `json:"tag"`
+	Total           int      `json:"total"`
+	Completed       int      `json:"completed"`
+	CompletionRate  float64  `json:"completion_rate"`
+	AverageLeadTime Duration `json:"average_lead_time"`
+}
+
+// Duration is a time.Duration written in the json output as a number of
+// hours.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%.2f", time.Duration(d).Hours())), nil
+}
+
+// String returns the duration in days and hours, or hours and minutes when it
+// is shorter than a day.
+func (d Duration) String() string {
+	duration := time.Duration(d).Round(time.Minute)
+	days := int(duration.Hours()) / 24
+	hours := int(duration.Hours()) % 24
+
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+
+	return fmt.Sprintf("%dh %dm", hours, int(duration.Minutes())%60)
+}
+
+// Compute builds the report of the todos, which are expected to be the ones
+// created from from, inclusive, to to, exclusive.
+func Compute(todos []db.Todo, from, to time.Time) Report {
+	report := Report{From: from, To: to, Total: len(todos), OldestPending: []Task{}, Tags: []TagReport{}}
+
+	leadTimes := []time.Duration{}
+	weekdays := [7]int{}
+	tags := map[string]*TagReport{}
+	tagLeadTimes := map[string][]time.Duration{}
+	pending := []db.Todo{}
+
+	for _, todo := range todos {
+		tag, ok := tags[todo.Tag]
+
+		if !ok {
+			tag = &TagReport{Tag: todo.Tag}
+			tags[todo.Tag] = tag
+		}
+
+		tag.Total++
+
+		switch {
+		case todo.DateCompleted.Valid:
+			leadTime := max(todo.DateCompleted.Time.Sub(todo.DateCreated), 0)
+
+			report.Completed++
+			leadTimes = append(leadTimes, leadTime)
+			weekdays[todo.DateCompleted.Time.Local().Weekday()]++
+
+			tag.Completed++
+			tagLeadTimes[todo.Tag] = append(tagLeadTimes[todo.Tag], leadTime)
+		case !todo.State.Closed():
+			report.Pending++
+			pending = append(pending, todo)
+		}
+	}
+
+	report.CompletionRate = rate(report.Completed, report.Total)
+	report.AverageLeadTime = average(leadTimes)
+	report.MedianLeadTime = median(leadTimes)
+
+	days := math.Ceil(to.Sub(from).Hours() / 24)
+
+	if days > 0 {
+		report.CompletedPerDay = float64(report.Completed) / days
+		report.CompletedPerWeek = report.CompletedPerDay * 7
+	}
+
+	busiest := -1
+	for weekday, count := range weekdays {
+		if count > 0 && (busiest < 0 || count > weekdays[busiest]) {
+			busiest = weekday
+		}
+	}
+
+	if busiest >= 0 {
+		report.BusiestWeekday = time.Weekday(busiest).String()
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].DateCreated.Before(pending[j].DateCreated)
+	})
+
+	for _, todo := range pending[:min(len(pending), oldestPending)] {
+		report.OldestPending = append(report.OldestPending, Task{ID: todo.ID, Todo: todo.Todo, Tag: todo.Tag, DateCreated: todo.DateCreated})
+	}
+
+	for _, tag := range tags {
+		tag.CompletionRate = rate(tag.Completed, tag.Total)
+		tag.AverageLeadTime = average(tagLeadTimes[tag.Tag])
+		report.Tags = append(report.Tags, *tag)
+	}
+
+	sort.Slice(report.Tags, func(i, j int) bool {
+		if report.Tags[i].Total != report.Tags[j].Total {
+			return report.Tags[i].Total > report.Tags[j].Total
+		}
+		return report.Tags[i].Tag < report.Tags[j].Tag
+	})
+
+	return report
+}
+
+func rate(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+func average(durations []time.Duration) Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+
+	for _, d := range durations {
+		total += d
+	}
+
+	return Duration(total / time.Duration(len(durations)))
+}
+
+func median(durations []time.Duration) Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	middle := len(sorted) / 2
+
+	if len(sorted)%2 == 0 {
+		return Duration((sorted[middle-1] + sorted[middle]) / 2)
+	}
+
+	return Duration(sorted[middle])
+}
+
+// Render renders the report as panels for the terminal.
+func Render(report Report) string {
+	period := fmt.Sprintf("%s to %s", report.From.Format("2006-01-02"), report.To.AddDate(0, 0, -1).Format("2006-01-02"))
+
+	summary := lipgloss.JoinHorizontal(lipgloss.Top,
+		panel("Tasks", [][2]string{
+			{"total", fmt.Sprint(report.Total)},
+			{"completed", fmt.Sprint(report.Completed)},
+			{"pending", fmt.Sprint(report.Pending)},
+			{"completion rate", percent(report.CompletionRate)},
+		}),
+		panel("Lead time", [][2]string{
+			{"average", leadTime(report.Completed, report.AverageLeadTime)},
+			{"median", leadTime(report.Completed, report.MedianLeadTime)},
+		}),
+		panel("Throughput", [][2]string{
+			{"per day", fmt.Sprintf("%.1f", report.CompletedPerDay)},
+			{"per week", fmt.Sprintf("%.1f", report.CompletedPerWeek)},
+			{"busiest day", orNone(report.BusiestWeekday)},
+		}),
+	)
+
+	oldest := [][2]string{}
+	for _, task := range report.OldestPending {
+		oldest = append(oldest, [2]string{task.DateCreated.Format("2006-01-02"), fmt.Sprintf("#%d %s", task.ID, task.Todo)})
+	}
+
+	if len(oldest) == 0 {
+		oldest = append(oldest, [2]string{"none", ""})
+	}
+
+	tags := [][2]string{}
+	for _, tag := range report.Tags {
+		name := tag.Tag
+		if name == "" {
+			name = "no tag"
+		}
+
+		tags = append(tags, [2]string{name, fmt.Sprintf("%d/%d done · %s · %s", tag.Completed, tag.Total, percent(tag.CompletionRate), leadTime(tag.Completed, tag.AverageLeadTime))})
+	}
+
+	if len(tags) == 0 {
+		tags = append(tags, [2]string{"none", ""})
+	}
+
+	details := lipgloss.JoinHorizontal(lipgloss.Top,
+		panel("Oldest pending", oldest),
+		panel("Tags", tags),
+	)
+
+	return labelStyle.Render("Tasks created from "+period) + "\n" + summary + "\n" + details + "\n"
+}
+
+// panel renders the rows of labels and values in a box with a title.
+func panel(title string, rows [][2]string) string {
+	width := 0
+	for _, row := range rows {
+		width = max(width, lipgloss.Width(row[0]))
+	}
+
+	lines := []string{panelTitleStyle.Render(title)}
+
+	for _, row := range rows {
+		label := labelStyle.Render(row[0] + strings.Repeat(" ", width-lipgloss.Width(row[0])))
+		lines = append(lines, strings.TrimRight(label+"  "+row[1], " "))
+	}
+
+	return panelStyle.Render(strings.Join(lines, "\n"))
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+func leadTime(completed int, d Duration) string {
+	if completed == 0 {
+		return "-"
+	}
+	return d.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Since returns the start of the period of the report, the creation day of the
+// oldest todo when there is no --since flag.
+func Since(todos []db.Todo, now time.Time) time.Time {
+	from := dates.StartOfDay(now)
+
+	for _, todo := range todos {
+		if day := dates.StartOfDay(todo.DateCreated.In(now.Location())); day.Before(from) {
+			from = day
+		}
+	}
+
+	return from
+}
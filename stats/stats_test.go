@@ -0,0 +1,126 @@
+package stats
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+func TestCompute(t *testing.T) {
+	from := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, 7)
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, 3, day, hour, 0, 0, 0, time.Local)
+	}
+	completed := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: at(day, hour), Valid: true}
+	}
+
+	tests := []struct {
+		name  string
+		todos []db.Todo
+		want  Report
+	}{
+		{
+			name:  "empty",
+			todos: nil,
+			want:  Report{From: from, To: to, OldestPending: []Task{}, Tags: []TagReport{}},
+		},
+		{
+			name: "completed and pending",
+			todos: []db.Todo{
+				// Completed on Wednesday after 2 hours.
+				{ID: 1, Todo: "a", Tag: "work", State: db.Done, DateCreated: at(6, 8), DateCompleted: completed(6, 10)},
+				// Completed on Wednesday after 2 days.
+				{ID: 2, Todo: "b", Tag: "work", State: db.Done, DateCreated: at(4, 10), DateCompleted: completed(6, 10)},
+				// Completed on Thursday after 6 hours.
+				{ID: 3, Todo: "c", Tag: "home", State: db.Done, DateCreated: at(7, 9), DateCompleted: completed(7, 15)},
+				{ID: 4, Todo: "d", Tag: "home", State: db.Pending, DateCreated: at(5, 9)},
+				{ID: 5, Todo: "e", Tag: "", State: db.InProgress, DateCreated: at(4, 9)},
+				// Cancelled without completion date, neither completed nor pending.
+				{ID: 6, Todo: "f", Tag: "work", State: db.Cancelled, DateCreated: at(4, 11)},
+			},
+			want: Report{
+				From:             from,
+				To:               to,
+				Total:            6,
+				Completed:        3,
+				Pending:          2,
+				CompletionRate:   0.5,
+				AverageLeadTime:  Duration(56 * time.Hour / 3),
+				MedianLeadTime:   Duration(6 * time.Hour),
+				CompletedPerDay:  3.0 / 7,
+				CompletedPerWeek: 3,
+				BusiestWeekday:   "Wednesday",
+				OldestPending: []Task{
+					{ID: 5, Todo: "e", Tag: "", DateCreated: at(4, 9)},
+					{ID: 4, Todo: "d", Tag: "home", DateCreated: at(5, 9)},
+				},
+				Tags: []TagReport{
+					{Tag: "work", Total: 3, Completed: 2, CompletionRate: 2.0 / 3, AverageLeadTime: Duration(25 * time.Hour)},
+					{Tag: "home", Total: 2, Completed: 1, CompletionRate: 0.5, AverageLeadTime: Duration(6 * time.Hour)},
+					{Tag: "", Total: 1, Completed: 0, CompletionRate: 0, AverageLeadTime: 0},
+				},
+			},
+		},
+		{
+			name: "completed before created",
+			todos: []db.Todo{
+				{ID: 1, Todo: "a", State: db.Done, DateCreated: at(6, 10), DateCompleted: completed(6, 8)},
+			},
+			want: Report{
+				From:             from,
+				To:               to,
+				Total:            1,
+				Completed:        1,
+				CompletionRate:   1,
+				CompletedPerDay:  1.0 / 7,
+				CompletedPerWeek: 1,
+				BusiestWeekday:   "Wednesday",
+				OldestPending:    []Task{},
+				Tags:             []TagReport{{Tag: "", Total: 1, Completed: 1, CompletionRate: 1}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Compute(tt.todos, from, to)
+
+			// The rates are compared apart, with a tolerance.
+			if !near(report.CompletedPerWeek, tt.want.CompletedPerWeek) {
+				t.Errorf("CompletedPerWeek = %v, want %v", report.CompletedPerWeek, tt.want.CompletedPerWeek)
+			}
+
+			report.CompletedPerWeek, tt.want.CompletedPerWeek = 0, 0
+
+			if !reflect.DeepEqual(report, tt.want) {
+				t.Errorf("Compute() = %+v, want %+v", report, tt.want)
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
+
+func TestDurationString(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 0, want: "0h 0m"},
+		{d: 90 * time.Minute, want: "1h 30m"},
+		{d: 26*time.Hour + 29*time.Minute, want: "1d 2h"},
+		{d: 59*time.Second + 59*time.Minute, want: "1h 0m"},
+	}
+
+	for _, tt := range tests {
+		if got := Duration(tt.d).String(); got != tt.want {
+			t.Errorf("Duration(%v).String() = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package taskwarrior
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"todo/db"
+)
+
+// timeLayout is the layout of the dates of Taskwarrior.
+const timeLayout = "20060102T150405Z"
+
+// stateAttribute keeps the state of the todos whose status isn't enough to
+// tell it, Taskwarrior keeps the attributes it doesn't know as orphan UDAs.
+const stateAttribute = "todostate"
+
+// Task is a task of the Taskwarrior export, the attributes used.
+type Task struct {
+	UUID        string   `json:"uuid"`
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Entry       string   `json:"entry,omitempty"`
+	Modified    string   `json:"modified,omitempty"`
+	Start       string   `json:"start,omitempty"`
+	End         string   `json:"end,omitempty"`
+	Due         string   `json:"due,omitempty"`
+	Project     string   `json:"project,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+	State       string   `json:"todostate,omitempty"`
+}
+
+// known are the attributes read into the todos, id and urgency are computed by
+// Taskwarrior so they aren't reported as lost.
+var known = map[string]bool{
+	"uuid": true, "description": true, "status": true, "entry": true, "modified": true, "start": true, "end": true,
+	"due": true, "project": true, "tags": true, "priority": true, stateAttribute: true, "id": true, "urgency": true,
+}
+
+// priorities maps the priorities of Taskwarrior to the ones of the todos, the
+// lower ones are exported as L.
+var priorities = map[string]string{"H": "A", "M": "B", "L": "C"}
+
+// Write writes the todos as the `task export` JSON array with a task on every
+// line, which `task import` reads. The tag is the project.
+func Write(w io.Writer, todos []db.Todo) error {
+	var b bytes.Buffer
+
+	b.WriteString("[\n")
+
+	for i, todo := range todos {
+		line, err := json.Marshal(newTask(todo))
+
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+
+		b.Write(line)
+	}
+
+	b.WriteString("\n]\n")
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
+
+func newTask(todo db.Todo) Task {
+	task := Task{
+		UUID:        uuid(todo.UID),
+		Description: todo.Todo,
+		Status:      "pending",
+		Entry:       todo.DateCreated.UTC().Format(timeLayout),
+		Project:     todo.Tag,
+	}
+
+	if !todo.DateModified.IsZero() {
+		task.Modified = todo.DateModified.UTC().Format(timeLayout)
+	}
+
+	switch {
+	case todo.State == db.Done:
+		task.Status = "completed"
+	case todo.State.Closed():
+		task.Status = "deleted"
+	case todo.State == db.InProgress:
+		task.Start = task.Modified
+	}
+
+	if todo.DateCompleted.Valid && todo.State.Closed() {
+		task.End = todo.DateCompleted.Time.UTC().Format(timeLayout)
+	}
+
+	if todo.DateDue.Valid {
+		task.Due = todo.DateDue.Time.UTC().Format(timeLayout)
+	}
+
+	for tw, priority := range priorities {
+		if todo.Priority == priority {
+			task.Priority = tw
+		}
+	}
+
+	if todo.Priority > "C" {
+		task.Priority = "L"
+	}
+
+	if stateOf(task) != todo.State {
+		task.State = todo.State.String()
+	}
+
+	return task
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// uuid returns the uid when it is a UUID, as Taskwarrior requires, and a
+// version 5 UUID made from it otherwise so it is always the same.
+func uuid(uid string) string {
+	if uuidPattern.MatchString(strings.ToLower(uid)) {
+		return strings.ToLower(uid)
+	}
+
+	h := sha1.Sum([]byte(uid))
+	h[6] = h[6]&0x0f | 0x50
+	h[8] = h[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// stateOf returns the state of the todos with the status of the task.
+func stateOf(task Task) db.Status {
+	switch {
+	case task.Status == "completed":
+		return db.Done
+	case task.Status == "deleted":
+		return db.Cancelled
+	case task.Start != "" && task.Status == "pending":
+		return db.InProgress
+	case task.Status == "waiting":
+		return db.Backlog
+	default:
+		return db.Pending
+	}
+}
+
+// Read reads the tasks of `task export`, a JSON array or a task on every line.
+// The tag is the project or, without one, the first tag. The notes tell the
+// attributes that couldn't be imported and how many tasks had them.
+func Read(r io.Reader) ([]db.Todo, []string, error) {
+	decoder := json.NewDecoder(r)
+	objects := []json.RawMessage{}
+
+	for {
+		var value json.RawMessage
+
+		err := decoder.Decode(&value)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Not valid Taskwarrior export: %w", err)
+		}
+
+		if bytes.HasPrefix(bytes.TrimSpace(value), []byte("[")) {
+			var array []json.RawMessage
+
+			if err := json.Unmarshal(value, &array); err != nil {
+				return nil, nil, fmt.Errorf("Not valid Taskwarrior export: %w", err)
+			}
+
+			objects = append(objects, array...)
+		} else {
+			objects = append(objects, value)
+		}
+	}
+
+	todos := []db.Todo{}
+	lost := map[string]int{}
+
+	for i, object := range objects {
+		var task Task
+		var attributes map[string]json.RawMessage
+
+		if err := json.Unmarshal(object, &task); err != nil {
+			return nil, nil, fmt.Errorf("task %d: Not valid task: %w", i+1, err)
+		}
+
+		if err := json.Unmarshal(object, &attributes); err != nil {
+			return nil, nil, fmt.Errorf("task %d: Not valid task: %w", i+1, err)
+		}
+
+		for name := range attributes {
+			if !known[name] {
+				lost[name]++
+			}
+		}
+
+		todo, extraTags, err := newTodo(task)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+
+		if extraTags {
+			lost["tags"]++
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos, notes(lost), nil
+}
+
+func newTodo(task Task) (db.Todo, bool, error) {
+	todo := db.Todo{
+		Todo:     strings.TrimSpace(task.Description),
+		Tag:      task.Project,
+		UID:      task.UUID,
+		Priority: priorities[task.Priority],
+		State:    stateOf(task),
+	}
+
+	if todo.Todo == "" {
+		return todo, false, errors.New("the description is empty")
+	}
+
+	extraTags := len(task.Tags) > 0
+
+	if todo.Tag == "" && len(task.Tags) > 0 {
+		todo.Tag = task.Tags[0]
+		extraTags = len(task.Tags) > 1
+	}
+
+	if task.State != "" {
+		state, err := db.ParseStatus(task.State)
+
+		if err != nil {
+			return todo, false, err
+		}
+
+		todo.State = state
+	}
+
+	// The workflow may not have the state of the status.
+	if !todo.State.Valid() {
+		todo.State = db.Pending
+
+		if task.Status == "deleted" {
+			todo.State = db.Done
+		}
+	}
+
+	for _, date := range []struct {
+		name  string
+		value string
+		time  *sql.NullTime
+	}{
+		{"end", task.End, &todo.DateCompleted},
+		{"due", task.Due, &todo.DateDue},
+	} {
+		t, err := parseTime(date.name, date.value)
+
+		if err != nil {
+			return todo, false, err
+		}
+
+		*date.time = sql.NullTime{Time: t, Valid: !t.IsZero()}
+	}
+
+	var err error
+
+	if todo.DateCreated, err = parseTime("entry", task.Entry); err != nil {
+		return todo, false, err
+	}
+
+	if todo.DateModified, err = parseTime("modified", task.Modified); err != nil {
+		return todo, false, err
+	}
+
+	if !todo.State.Closed() {
+		todo.DateCompleted = sql.NullTime{}
+	}
+
+	return todo, extraTags, nil
+}
+
+// parseTime parses a date of Taskwarrior, the zero time is returned for an
+// empty one.
+func parseTime(name, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(timeLayout, s)
+
+	if err != nil {
+		return t, fmt.Errorf("Not valid %s %q, use YYYYMMDDTHHMMSSZ", name, s)
+	}
+
+	return t, nil
+}
+
+// notes describes the attributes lost, sorted by name.
+func notes(lost map[string]int) []string {
+	names := []string{}
+	for name := range lost {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	notes := []string{}
+
+	for _, name := range names {
+		tasks := fmt.Sprintf("%d tasks", lost[name])
+		if lost[name] == 1 {
+			tasks = "1 task"
+		}
+
+		if name == "tags" {
+			notes = append(notes, fmt.Sprintf("the tags of %s weren't imported, only the project or the first tag is kept", tasks))
+			continue
+		}
+
+		notes = append(notes, fmt.Sprintf("the %s attribute of %s wasn't imported", name, tasks))
+	}
+
+	return notes
+}
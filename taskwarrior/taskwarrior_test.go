@@ -0,0 +1,135 @@
+package taskwarrior
+
+import (
+	"bytes"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+func TestRead(t *testing.T) {
+	utc := func(day, hour int) time.Time {
+		return time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		export  string
+		want    []db.Todo
+		notes   []string
+		wantErr bool
+	}{
+		{
+			name: "array",
+			export: `[
+{"id":1,"uuid":"a","description":"Write","status":"pending","entry":"20240301T090000Z","project":"docs","priority":"H","urgency":3.9},
+{"id":0,"uuid":"b","description":"Read","status":"completed","entry":"20240301T090000Z","end":"20240302T100000Z","due":"20240305T230000Z"}
+]`,
+			want: []db.Todo{
+				{UID: "a", Todo: "Write", Tag: "docs", Priority: "A", State: db.Pending, DateCreated: utc(1, 9)},
+				{UID: "b", Todo: "Read", State: db.Done, DateCreated: utc(1, 9), DateCompleted: sql.NullTime{Time: utc(2, 10), Valid: true}, DateDue: sql.NullTime{Time: utc(5, 23), Valid: true}},
+			},
+			notes: []string{},
+		},
+		{
+			name: "a task on every line",
+			export: `{"uuid":"a","description":"Started","status":"pending","start":"20240301T100000Z","modified":"20240301T100000Z"}
+{"uuid":"b","description":"Waiting","status":"waiting","tags":["home"]}
+{"uuid":"c","description":"Deleted","status":"deleted","end":"20240302T100000Z","priority":"L"}`,
+			want: []db.Todo{
+				{UID: "a", Todo: "Started", State: db.InProgress, DateModified: utc(1, 10)},
+				{UID: "b", Todo: "Waiting", Tag: "home", State: db.Backlog},
+				{UID: "c", Todo: "Deleted", Priority: "C", State: db.Cancelled, DateCompleted: sql.NullTime{Time: utc(2, 10), Valid: true}},
+			},
+			notes: []string{},
+		},
+		{
+			name:   "state attribute",
+			export: `[{"uuid":"a","description":"Blocked","status":"pending","todostate":"blocked"}]`,
+			want:   []db.Todo{{UID: "a", Todo: "Blocked", State: db.Blocked}},
+			notes:  []string{},
+		},
+		{
+			name: "attributes lost",
+			export: `[{"uuid":"a","description":"One","status":"pending","project":"p","tags":["x"],"annotations":[],"recur":"weekly"},
+{"uuid":"b","description":"Two","status":"pending","tags":["x","y"],"annotations":[]}]`,
+			want: []db.Todo{
+				{UID: "a", Todo: "One", Tag: "p", State: db.Pending},
+				{UID: "b", Todo: "Two", Tag: "x", State: db.Pending},
+			},
+			notes: []string{
+				"the annotations attribute of 2 tasks wasn't imported",
+				"the recur attribute of 1 task wasn't imported",
+				"the tags of 2 tasks weren't imported, only the project or the first tag is kept",
+			},
+		},
+		{name: "empty description", export: `[{"uuid":"a","description":" ","status":"pending"}]`, wantErr: true},
+		{name: "not valid date", export: `[{"uuid":"a","description":"a","status":"pending","due":"2024-03-05"}]`, wantErr: true},
+		{name: "not valid state", export: `[{"uuid":"a","description":"a","status":"pending","todostate":"unknown"}]`, wantErr: true},
+		{name: "not json", export: `uuid,description`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos, notes, err := Read(strings.NewReader(tt.export))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(todos, tt.want) {
+				t.Errorf("Read() = %+v, want %+v", todos, tt.want)
+			}
+
+			if !reflect.DeepEqual(notes, tt.notes) {
+				t.Errorf("Read() notes = %q, want %q", notes, tt.notes)
+			}
+		})
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	completed := sql.NullTime{Time: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	todos := []db.Todo{
+		{UID: "0b6f1f5e-8a3b-4c1e-9d7a-2f4e6c8b1a3d", Todo: "Write", Tag: "docs", Priority: "B", State: db.Pending, DateCreated: created, DateModified: created},
+		{UID: "1c7a2b6f-9b4c-4d2f-8e8b-3a5f7d9c2b4e", Todo: "Read", State: db.Done, DateCreated: created, DateModified: created, DateCompleted: completed},
+		{UID: "2d8b3c7a-ac5d-4e3a-9f9c-4b6a8e1d3c5f", Todo: "Review", State: db.Review, DateCreated: created, DateModified: created},
+	}
+
+	var b bytes.Buffer
+
+	if err := Write(&b, todos); err != nil {
+		t.Fatal(err)
+	}
+
+	read, _, err := Read(&b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read, todos) {
+		t.Errorf("Read(Write()) = %+v, want %+v", read, todos)
+	}
+}
+
+func TestUUID(t *testing.T) {
+	valid := "0B6F1F5E-8A3B-4C1E-9D7A-2F4E6C8B1A3D"
+
+	if got := uuid(valid); got != strings.ToLower(valid) {
+		t.Errorf("uuid(%q) = %q, want it in lower case", valid, got)
+	}
+
+	if got := uuid("todo-42"); !uuidPattern.MatchString(got) || got != uuid("todo-42") || got == uuid("todo-43") {
+		t.Errorf("uuid(%q) = %q, want a UUID that only depends on the uid", "todo-42", got)
+	}
+}
@@ -0,0 +1,165 @@
+package todo_table
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"todo/db"
+
+	"github.com/mattn/go-runewidth"
+)
+
+type Column int
+
+const (
+	IDColumn Column = iota
+	TitleColumn
+	TagColumn
+	StateColumn
+	CreatedColumn
+	CompletedColumn
+	DueColumn
+	PriorityColumn
+)
+
+var columnNames = []string{"id", "title", "tag", "state", "created", "completed", "due", "priority"}
+
+var columnTitles = []string{"ID", "Todo", "Tag", "State", "Creation date", "Completion date", "Due date", "Pri"}
+
+// columnWidths are the widths used when the size of the terminal is unknown,
+// they are the minimum widths for every column but the Todo and Tag ones.
+var columnWidths = []int{4, 25, 16, 11, 13, 15, 10, 3}
+
+const (
+	minTitleWidth = 10
+	minTagWidth   = 6
+	cellPadding   = 2
+	checkboxWidth = 3
+)
+
+// DefaultColumns are the columns shown when --columns isn't used.
+var DefaultColumns = []Column{IDColumn, TitleColumn, TagColumn, StateColumn, CreatedColumn}
+
+func (c Column) String() string {
+	return columnNames[c]
+}
+
+// Title returns the header of the column.
+func (c Column) Title() string {
+	return columnTitles[c]
+}
+
+// ParseColumns parses the comma separated value of the --columns flag.
+func ParseColumns(s string) ([]Column, error) {
+	if s == "" {
+		return DefaultColumns, nil
+	}
+
+	var columns []Column
+
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+
+		for i, columnName := range columnNames {
+			if columnName == name {
+				columns = append(columns, Column(i))
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, errors.New("Not valid column " + strconv.Quote(name) + ", use any of: " + strings.Join(columnNames, ", "))
+		}
+	}
+
+	return columns, nil
+}
+
+// Value returns the content of the column for the todo.
+func (c Column) Value(todo db.Todo) string {
+	switch c {
+	case IDColumn:
+		return strconv.Itoa(todo.ID)
+	case TitleColumn:
+		return todo.Todo
+	case TagColumn:
+		return todo.Tag
+	case StateColumn:
+		return todo.State.String()
+	case CreatedColumn:
+		return todo.DateCreated.Format("2006-01-02")
+	case CompletedColumn:
+		if todo.DateCompleted.Valid {
+			return todo.DateCompleted.Time.Format("2006-01-02")
+		}
+		return ""
+	case PriorityColumn:
+		return todo.Priority
+	case DueColumn:
+		if todo.DateDue.Valid {
+			return todo.DateDue.Time.Format("2006-01-02")
+		}
+		return ""
+	default:
+		return ""
+	}
+}
+
+// layout returns the width of every column to fill the given width, the extra
+// space goes to the Todo column and when there isn't enough the Todo and then
+// the Tag columns shrink. A width of zero keeps the default widths.
+func layout(columns []Column, width int) []int {
+	widths := make([]int, len(columns))
+	total := 0
+
+	for i, c := range columns {
+		widths[i] = columnWidths[c]
+		total += widths[i] + cellPadding
+	}
+
+	if width <= 0 {
+		return widths
+	}
+
+	extra := width - total
+
+	for _, shrinking := range []struct {
+		column   Column
+		minWidth int
+	}{{TitleColumn, minTitleWidth}, {TagColumn, minTagWidth}} {
+		for i, c := range columns {
+			if c != shrinking.column {
+				continue
+			}
+
+			if extra > 0 {
+				widths[i] += extra
+				return widths
+			}
+
+			shrink := min(-extra, widths[i]-shrinking.minWidth)
+			widths[i] -= shrink
+			extra += shrink
+		}
+	}
+
+	return widths
+}
+
+// Truncate shortens the value to the width ending it with an ellipsis, cutting
+// after the last whole word when that doesn't waste more than a quarter of the
+// width.
+func Truncate(value string, width int) string {
+	if runewidth.StringWidth(value) <= width {
+		return value
+	}
+
+	cut := runewidth.Truncate(value, width-1, "")
+
+	if i := strings.LastIndex(cut, " "); i > 0 && runewidth.StringWidth(cut[:i]) >= width*3/4 {
+		cut = cut[:i]
+	}
+
+	return cut + "…"
+}
@@ -0,0 +1,175 @@
+package todo_table
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"todo/db"
+)
+
+type SortField int
+
+const (
+	SortByID SortField = iota
+	SortByTitle
+	SortByTag
+	SortByState
+	SortByCreated
+	SortByCompleted
+	SortByDue
+)
+
+var sortFieldNames = []string{"id", "title", "tag", "state", "created", "completed", "due"}
+
+func (f SortField) String() string {
+	return sortFieldNames[f]
+}
+
+// Order is the sort applied to the todos, the zero value sorts them by id.
+type Order struct {
+	Field      SortField
+	Descending bool
+}
+
+func (o Order) String() string {
+	if o.Descending {
+		return "-" + o.Field.String()
+	}
+	return o.Field.String()
+}
+
+// ParseOrder parses the value of the --sort flag, a field name optionally
+// prefixed with "-" to sort in descending order.
+func ParseOrder(s string) (Order, error) {
+	var order Order
+
+	if s == "" {
+		return order, nil
+	}
+
+	if strings.HasPrefix(s, "-") {
+		order.Descending = true
+		s = s[1:]
+	}
+
+	for i, name := range sortFieldNames {
+		if name == s {
+			order.Field = SortField(i)
+			return order, nil
+		}
+	}
+
+	return order, errors.New("Not valid sort, use one of: " + strings.Join(sortFieldNames, ", "))
+}
+
+// next returns the order by the following field, used to cycle the sort
+// column from the keyboard.
+func (o Order) next() Order {
+	o.Field = (o.Field + 1) % SortField(len(sortFieldNames))
+	return o
+}
+
+// SortTodos sorts the todos in place, the tasks without completion or due date
+// are always placed last when sorting by those dates.
+func SortTodos(todos []db.Todo, order Order) {
+	sort.SliceStable(todos, func(i, j int) bool {
+		a, b := todos[i], todos[j]
+
+		if order.Field == SortByCompleted && a.DateCompleted.Valid != b.DateCompleted.Valid {
+			return a.DateCompleted.Valid
+		}
+
+		if order.Field == SortByDue && a.DateDue.Valid != b.DateDue.Valid {
+			return a.DateDue.Valid
+		}
+
+		if order.Descending {
+			a, b = b, a
+		}
+
+		switch order.Field {
+		case SortByTitle:
+			return strings.ToLower(a.Todo) < strings.ToLower(b.Todo)
+		case SortByTag:
+			return strings.ToLower(a.Tag) < strings.ToLower(b.Tag)
+		case SortByState:
+			return a.State.Position() < b.State.Position()
+		case SortByCreated:
+			return a.DateCreated.Before(b.DateCreated)
+		case SortByCompleted:
+			return a.DateCompleted.Time.Before(b.DateCompleted.Time)
+		case SortByDue:
+			return a.DateDue.Time.Before(b.DateDue.Time)
+		default:
+			return a.ID < b.ID
+		}
+	})
+}
+
+type GroupBy int
+
+const (
+	NoGroup GroupBy = iota
+	GroupByTag
+	GroupByState
+	GroupByDay
+)
+
+var groupByNames = []string{"", "tag", "state", "day"}
+
+func (g GroupBy) String() string {
+	return groupByNames[g]
+}
+
+// ParseGroupBy parses the value of the --group-by flag.
+func ParseGroupBy(s string) (GroupBy, error) {
+	for i, name := range groupByNames {
+		if name == s {
+			return GroupBy(i), nil
+		}
+	}
+
+	return NoGroup, errors.New("Not valid group, use one of: tag, state, day")
+}
+
+// next returns the following grouping, going back to no grouping after the
+// last one.
+func (g GroupBy) next() GroupBy {
+	return (g + 1) % GroupBy(len(groupByNames))
+}
+
+// GroupKey returns the name of the group the todo belongs to.
+func (g GroupBy) GroupKey(todo db.Todo) string {
+	switch g {
+	case GroupByTag:
+		if todo.Tag == "" {
+			return "no tag"
+		}
+		return todo.Tag
+	case GroupByState:
+		return todo.State.String()
+	case GroupByDay:
+		return todo.DateCreated.Format("2006-01-02")
+	default:
+		return ""
+	}
+}
+
+// Group splits the already sorted todos in groups keeping the order in which
+// every group first appears, so sorting by creation date in descending order
+// and grouping by day shows the most recent day first.
+func Group(todos []db.Todo, groupBy GroupBy) (keys []string, groups map[string][]db.Todo) {
+	groups = map[string][]db.Todo{}
+
+	for _, todo := range todos {
+		key := groupBy.GroupKey(todo)
+
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		groups[key] = append(groups[key], todo)
+	}
+
+	return keys, groups
+}
@@ -0,0 +1,95 @@
+package todo_table
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+func TestSortTodos(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 3, d, 10, 0, 0, 0, time.UTC)
+	}
+	valid := func(d int) sql.NullTime {
+		return sql.NullTime{Time: day(d), Valid: true}
+	}
+
+	todos := []db.Todo{
+		{ID: 1, Todo: "banana", Tag: "Work", State: db.Done, DateCreated: day(3), DateCompleted: valid(5)},
+		{ID: 2, Todo: "Apple", Tag: "home", State: db.Pending, DateCreated: day(1), DateDue: valid(20)},
+		{ID: 3, Todo: "cherry", Tag: "", State: db.InProgress, DateCreated: day(2)},
+		{ID: 4, Todo: "apple pie", Tag: "home", State: db.Done, DateCreated: day(4), DateCompleted: valid(4), DateDue: valid(10)},
+	}
+
+	tests := []struct {
+		order string
+		want  []int
+	}{
+		{order: "", want: []int{1, 2, 3, 4}},
+		{order: "-id", want: []int{4, 3, 2, 1}},
+		{order: "title", want: []int{2, 4, 1, 3}},
+		{order: "-title", want: []int{3, 1, 4, 2}},
+		// The sort is stable, the tasks with the same tag keep their order.
+		{order: "tag", want: []int{3, 2, 4, 1}},
+		{order: "state", want: []int{2, 3, 1, 4}},
+		{order: "created", want: []int{2, 3, 1, 4}},
+		{order: "-created", want: []int{4, 1, 3, 2}},
+		// The tasks without the date are last in both directions.
+		{order: "completed", want: []int{4, 1, 2, 3}},
+		{order: "-completed", want: []int{1, 4, 2, 3}},
+		{order: "due", want: []int{4, 2, 1, 3}},
+		{order: "-due", want: []int{2, 4, 1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			order, err := ParseOrder(tt.order)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sorted := append([]db.Todo{}, todos...)
+			SortTodos(sorted, order)
+
+			ids := []int{}
+			for _, todo := range sorted {
+				ids = append(ids, todo.ID)
+			}
+
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("SortTodos(%q) = %v, want %v", tt.order, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Order
+		wantErr bool
+	}{
+		{s: "", want: Order{}},
+		{s: "due", want: Order{Field: SortByDue}},
+		{s: "-title", want: Order{Field: SortByTitle, Descending: true}},
+		{s: "name", wantErr: true},
+		{s: "--id", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			order, err := ParseOrder(tt.s)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOrder(%q) error = %v, want error %v", tt.s, err, tt.wantErr)
+			}
+
+			if !tt.wantErr && order != tt.want {
+				t.Errorf("ParseOrder(%q) = %v, want %v", tt.s, order, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,594 @@
+package todo_table
+
+import (
+	"fmt"
+	"strings"
+	"todo/db"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+type keyMap struct {
+	Up         key.Binding
+	Down       key.Binding
+	PageUp     key.Binding
+	PageDown   key.Binding
+	GotoTop    key.Binding
+	GotoBottom key.Binding
+	Filter     key.Binding
+	Accept     key.Binding
+	Cancel     key.Binding
+	Sort       key.Binding
+	Reverse    key.Binding
+	Group      key.Binding
+}
+
+// Keys are the keybindings handled by the table itself, the parent models can
+// use them to build their help views.
+var Keys = keyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "move up"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "move down"),
+	),
+	PageUp: key.NewBinding(
+		key.WithKeys("pgup"),
+		key.WithHelp("pgup", "page up"),
+	),
+	PageDown: key.NewBinding(
+		key.WithKeys("pgdown"),
+		key.WithHelp("pgdown", "page down"),
+	),
+	GotoTop: key.NewBinding(
+		key.WithKeys("home", "g"),
+		key.WithHelp("g/home", "go to start"),
+	),
+	GotoBottom: key.NewBinding(
+		key.WithKeys("end", "G"),
+		key.WithHelp("G/end", "go to end"),
+	),
+	Filter: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "filter"),
+	),
+	Accept: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "apply filter"),
+	),
+	Cancel: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "clear filter"),
+	),
+	Sort: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "change sort column"),
+	),
+	Reverse: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "reverse sort"),
+	),
+	Group: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "group by tag/state/day"),
+	),
+}
+
+var (
+	headerStyle = lipgloss.NewStyle().
+			Padding(0, 1).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(lipgloss.Color("240")).
+			BorderBottom(true)
+
+	selectedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("229")).
+			Background(lipgloss.Color("57"))
+
+	matchStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("212")).
+			Underline(true)
+
+	groupStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("212")).
+			Bold(true).
+			Padding(0, 1)
+
+	statusStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+)
+
+// row is either a todo or, when header isn't empty, the header of a group.
+type row struct {
+	todo    db.Todo
+	matches map[Column][]int // matched rune positions of the Todo and Tag columns
+	header  string
+}
+
+// Model is a table of todos with an incremental fuzzy filter, sorting and
+// grouping, it is shared by the list_table and list_actionable models.
+type Model struct {
+	todos     []db.Todo
+	visible   []row
+	matched   int
+	cursor    int
+	offset    int
+	height    int
+	columns   []Column
+	widths    []int
+	filter    textinput.Model
+	filtering bool
+	order     Order
+	groupBy   GroupBy
+
+	// selectable adds a checkbox to every row, selected holds the ids of the
+	// checked todos.
+	selectable bool
+	selected   map[int]bool
+}
+
+type Option func(*Model)
+
+// WithOrder sets the initial sort of the table.
+func WithOrder(order Order) Option {
+	return func(m *Model) {
+		m.order = order
+	}
+}
+
+// WithColumns sets the columns shown and their order.
+func WithColumns(columns []Column) Option {
+	return func(m *Model) {
+		m.columns = columns
+	}
+}
+
+// WithSelection allows to select many todos, the rows show a checkbox.
+func WithSelection() Option {
+	return func(m *Model) {
+		m.selectable = true
+	}
+}
+
+// WithGroupBy sets the initial grouping of the table.
+func WithGroupBy(groupBy GroupBy) Option {
+	return func(m *Model) {
+		m.groupBy = groupBy
+	}
+}
+
+func New(todos []db.Todo, opts ...Option) Model {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "filter by todo or tag"
+	ti.CharLimit = 156
+
+	m := Model{
+		todos:    todos,
+		height:   8,
+		columns:  DefaultColumns,
+		filter:   ti,
+		selected: map[int]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	m.widths = layout(m.columns, 0)
+
+	m.refresh()
+
+	return m
+}
+
+// SetTodos replaces the todos keeping the filter, the sort, the grouping and,
+// when it is still there, the todo under the cursor.
+func (m *Model) SetTodos(todos []db.Todo) {
+	m.todos = todos
+	m.refresh()
+}
+
+// SetSize adapts the table to the width and height available, the header is
+// included in the height.
+func (m *Model) SetSize(width, height int) {
+	if m.selectable {
+		width -= checkboxWidth + cellPadding
+	}
+
+	m.widths = layout(m.columns, width)
+	m.height = max(height-2, 1)
+	m.moveCursor(0)
+}
+
+// ToggleSelected checks or unchecks the todo under the cursor.
+func (m *Model) ToggleSelected() {
+	if todo, ok := m.SelectedTodo(); ok {
+		if m.selected[todo.ID] {
+			delete(m.selected, todo.ID)
+		} else {
+			m.selected[todo.ID] = true
+		}
+	}
+}
+
+// SelectAll checks every todo shown, the ones hidden by the filter aren't.
+func (m *Model) SelectAll() {
+	for _, r := range m.visible {
+		if r.header == "" {
+			m.selected[r.todo.ID] = true
+		}
+	}
+}
+
+// SelectNone unchecks every todo.
+func (m *Model) SelectNone() {
+	m.selected = map[int]bool{}
+}
+
+// SelectedIDs returns the ids of the checked todos in the order they were
+// given to the table.
+func (m Model) SelectedIDs() []int {
+	ids := []int{}
+
+	for _, todo := range m.todos {
+		if m.selected[todo.ID] {
+			ids = append(ids, todo.ID)
+		}
+	}
+
+	return ids
+}
+
+// Filtering reports whether the filter input is focused, while it is every key
+// belongs to the table and the parent models shouldn't act on them.
+func (m Model) Filtering() bool {
+	return m.filtering
+}
+
+// SelectedTodo returns the todo under the cursor, false if there are no rows.
+func (m Model) SelectedTodo() (db.Todo, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.visible) || m.visible[m.cursor].header != "" {
+		return db.Todo{}, false
+	}
+	return m.visible[m.cursor].todo, true
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	if m.filtering {
+		switch {
+		case key.Matches(keyMsg, Keys.Accept):
+			m.filtering = false
+			m.filter.Blur()
+			return m, nil
+		case key.Matches(keyMsg, Keys.Cancel):
+			m.filtering = false
+			m.filter.Blur()
+			m.filter.Reset()
+			m.refresh()
+			return m, nil
+		}
+
+		m.filter, cmd = m.filter.Update(msg)
+		m.refresh()
+		return m, cmd
+	}
+
+	switch {
+	case key.Matches(keyMsg, Keys.Filter):
+		m.filtering = true
+		return m, m.filter.Focus()
+	case key.Matches(keyMsg, Keys.Cancel) && m.filter.Value() != "":
+		m.filter.Reset()
+		m.refresh()
+	case key.Matches(keyMsg, Keys.Sort):
+		m.order = m.order.next()
+		m.refresh()
+	case key.Matches(keyMsg, Keys.Reverse):
+		m.order.Descending = !m.order.Descending
+		m.refresh()
+	case key.Matches(keyMsg, Keys.Group):
+		m.groupBy = m.groupBy.next()
+		m.refresh()
+	case key.Matches(keyMsg, Keys.Up):
+		m.moveCursor(-1)
+	case key.Matches(keyMsg, Keys.Down):
+		m.moveCursor(1)
+	case key.Matches(keyMsg, Keys.PageUp):
+		m.moveCursor(-m.height)
+	case key.Matches(keyMsg, Keys.PageDown):
+		m.moveCursor(m.height)
+	case key.Matches(keyMsg, Keys.GotoTop):
+		m.moveCursor(-len(m.visible))
+	case key.Matches(keyMsg, Keys.GotoBottom):
+		m.moveCursor(len(m.visible))
+	}
+
+	return m, nil
+}
+
+// HasFilter reports whether a filter is narrowing the rows, esc clears it
+// instead of being handled by the parent model.
+func (m Model) HasFilter() bool {
+	return m.filter.Value() != ""
+}
+
+// moveCursor moves the cursor n rows skipping the group headers, a header
+// right above the cursor is kept on screen.
+func (m *Model) moveCursor(n int) {
+	m.cursor = clamp(m.cursor+n, 0, len(m.visible)-1)
+
+	step := 1
+	if n < 0 {
+		step = -1
+	}
+
+	for m.cursor >= 0 && m.cursor < len(m.visible) && m.visible[m.cursor].header != "" {
+		if next := m.cursor + step; next < 0 || next >= len(m.visible) {
+			step = -step
+		}
+		m.cursor += step
+	}
+
+	top := m.cursor
+	if top > 0 && m.visible[top-1].header != "" {
+		top--
+	}
+
+	if top < m.offset {
+		m.offset = top
+	} else if m.cursor >= m.offset+m.height {
+		m.offset = m.cursor - m.height + 1
+	}
+
+	m.offset = max(m.offset, 0)
+}
+
+// refresh rebuilds the visible rows applying the filter, the sort and the
+// grouping, keeping the cursor on the same todo when it is still visible.
+func (m *Model) refresh() {
+	selected, hasSelected := m.SelectedTodo()
+	query := m.filter.Value()
+
+	todos := []db.Todo{}
+	matches := map[int]map[Column][]int{}
+
+	for _, todo := range m.todos {
+		if query == "" {
+			todos = append(todos, todo)
+		} else if match, ok := matchTodo(query, todo.Todo, todo.Tag); ok {
+			todos = append(todos, todo)
+			matches[todo.ID] = match
+		}
+	}
+
+	SortTodos(todos, m.order)
+
+	m.matched = len(todos)
+	m.visible = []row{}
+
+	keys, groups := Group(todos, m.groupBy)
+
+	for _, key := range keys {
+		if m.groupBy != NoGroup {
+			m.visible = append(m.visible, row{
+				header: fmt.Sprintf("%s (%d)", key, len(groups[key])),
+			})
+		}
+
+		for _, todo := range groups[key] {
+			m.visible = append(m.visible, row{todo: todo, matches: matches[todo.ID]})
+		}
+	}
+
+	m.cursor = 0
+	m.offset = 0
+
+	for i, r := range m.visible {
+		if hasSelected && r.header == "" && r.todo.ID == selected.ID {
+			m.cursor = i
+		}
+	}
+
+	m.moveCursor(0)
+}
+
+// matchTodo fuzzy matches the query against the todo and its tag as if they
+// were a single string, so "fix work" matches the todo "fix bug" tagged "work".
+func matchTodo(query, todo, tag string) (map[Column][]int, bool) {
+	todoLength := len([]rune(todo))
+
+	positions, ok := fuzzyMatch(query, todo+" "+tag)
+	if !ok {
+		return nil, false
+	}
+
+	matches := map[Column][]int{}
+
+	for _, p := range positions {
+		switch {
+		case p < todoLength:
+			matches[TitleColumn] = append(matches[TitleColumn], p)
+		case p > todoLength:
+			matches[TagColumn] = append(matches[TagColumn], p-todoLength-1)
+		}
+	}
+
+	return matches, true
+}
+
+// fuzzyMatch reports whether every rune of the pattern appears in the target in
+// the same order, ignoring case and spaces of the pattern, and returns the rune
+// positions of the target that matched. The case is folded a rune at a time as
+// lowering a whole string can change its number of runes, like with İ.
+func fuzzyMatch(pattern, target string) ([]int, bool) {
+	pattern = strings.ReplaceAll(pattern, " ", "")
+	targetRunes := []rune(target)
+
+	positions := []int{}
+	i := 0
+
+	for _, p := range pattern {
+		p = unicode.ToLower(p)
+
+		for i < len(targetRunes) && unicode.ToLower(targetRunes[i]) != p {
+			i++
+		}
+
+		if i == len(targetRunes) {
+			return nil, false
+		}
+
+		positions = append(positions, i)
+		i++
+	}
+
+	return positions, true
+}
+
+func (m Model) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.headersView())
+
+	for i := m.offset; i < min(m.offset+m.height, len(m.visible)); i++ {
+		b.WriteString("\n")
+		b.WriteString(m.renderRow(m.visible[i], i == m.cursor))
+	}
+
+	return b.String()
+}
+
+// Render renders the headers and every row without the cursor, for the tables
+// that are printed instead of browsed.
+func (m Model) Render() string {
+	var b strings.Builder
+
+	b.WriteString(m.headersView())
+
+	for _, r := range m.visible {
+		b.WriteString("\n")
+		b.WriteString(m.renderRow(r, false))
+	}
+
+	return b.String()
+}
+
+// StatusView renders the filter input while it is being edited, or the
+// applied filter, sort and grouping afterwards, empty when there are none.
+func (m Model) StatusView() string {
+	if m.filtering {
+		return m.filter.View() + "\n"
+	}
+
+	status := []string{}
+
+	if m.HasFilter() {
+		status = append(status, fmt.Sprintf("filter %q: %d of %d tasks", m.filter.Value(), m.matched, len(m.todos)))
+	}
+
+	if m.order != (Order{}) {
+		status = append(status, "sorted by "+m.order.String())
+	}
+
+	if m.groupBy != NoGroup {
+		status = append(status, "grouped by "+m.groupBy.String())
+	}
+
+	if len(status) == 0 {
+		return ""
+	}
+
+	return statusStyle.Render(strings.Join(status, " · ")) + "\n"
+}
+
+func (m Model) headersView() string {
+	var s = make([]string, 0, len(m.columns)+1)
+
+	if m.selectable {
+		s = append(s, headerStyle.Render(strings.Repeat(" ", checkboxWidth)))
+	}
+
+	for i, col := range m.columns {
+		style := lipgloss.NewStyle().Width(m.widths[i]).MaxWidth(m.widths[i]).Inline(true)
+		s = append(s, headerStyle.Render(style.Render(Truncate(col.Title(), m.widths[i]))))
+	}
+
+	return lipgloss.JoinHorizontal(lipgloss.Left, s...)
+}
+
+// renderRow renders every cell itself instead of relying on the bubbles table,
+// the matched characters are styled and the table truncates the cells without
+// taking the escape sequences into account.
+func (m Model) renderRow(r row, current bool) string {
+	if r.header != "" {
+		return groupStyle.Render(r.header)
+	}
+
+	base := lipgloss.NewStyle()
+	highlight := matchStyle.Copy()
+
+	if current {
+		base = selectedStyle.Copy()
+		highlight = highlight.Background(selectedStyle.GetBackground())
+	}
+
+	var s = make([]string, 0, len(m.columns)+1)
+
+	if m.selectable {
+		checkbox := "[ ]"
+		if m.selected[r.todo.ID] {
+			checkbox = "[x]"
+		}
+		s = append(s, renderCell(checkbox, checkboxWidth, nil, base, highlight))
+	}
+
+	for i, col := range m.columns {
+		s = append(s, renderCell(col.Value(r.todo), m.widths[i], r.matches[col], base, highlight))
+	}
+
+	return lipgloss.JoinHorizontal(lipgloss.Left, s...)
+}
+
+func renderCell(value string, width int, matches []int, base, highlight lipgloss.Style) string {
+	value = Truncate(value, width)
+
+	matched := map[int]bool{}
+	for _, p := range matches {
+		matched[p] = true
+	}
+
+	var b strings.Builder
+
+	b.WriteString(base.Render(" "))
+
+	for i, r := range []rune(value) {
+		if matched[i] {
+			b.WriteString(highlight.Render(string(r)))
+		} else {
+			b.WriteString(base.Render(string(r)))
+		}
+	}
+
+	b.WriteString(base.Render(strings.Repeat(" ", width-runewidth.StringWidth(value)+1)))
+
+	return b.String()
+}
+
+func clamp(v, low, high int) int {
+	return min(max(v, low), high)
+}
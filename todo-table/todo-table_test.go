@@ -0,0 +1,65 @@
+package todo_table
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern, target string
+		want            []int
+		ok              bool
+	}{
+		{pattern: "", target: "anything", want: []int{}, ok: true},
+		{pattern: "fb", target: "fix bug", want: []int{0, 4}, ok: true},
+		{pattern: "FIX", target: "fix bug", want: []int{0, 1, 2}, ok: true},
+		{pattern: "f b", target: "fix bug", want: []int{0, 4}, ok: true},
+		{pattern: "bf", target: "fix bug", ok: false},
+		{pattern: "fixx", target: "fix bug", ok: false},
+		{pattern: "ñu", target: "Año nuevo", want: []int{1, 5}, ok: true},
+		{pattern: "ib", target: "İstanbul", want: []int{0, 5}, ok: true},
+		{pattern: "x", target: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" in "+tt.target, func(t *testing.T) {
+			positions, ok := fuzzyMatch(tt.pattern, tt.target)
+
+			if ok != tt.ok {
+				t.Fatalf("fuzzyMatch(%q, %q) ok = %t, want %t", tt.pattern, tt.target, ok, tt.ok)
+			}
+
+			if ok && !reflect.DeepEqual(positions, tt.want) {
+				t.Errorf("fuzzyMatch(%q, %q) = %v, want %v", tt.pattern, tt.target, positions, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchTodo(t *testing.T) {
+	tests := []struct {
+		query, todo, tag string
+		want             map[Column][]int
+		ok               bool
+	}{
+		{query: "fix work", todo: "fix bug", tag: "work", want: map[Column][]int{TitleColumn: {0, 1, 2}, TagColumn: {0, 1, 2, 3}}, ok: true},
+		{query: "bug", todo: "fix bug", tag: "work", want: map[Column][]int{TitleColumn: {4, 5, 6}}, ok: true},
+		{query: "wk", todo: "fix bug", tag: "work", want: map[Column][]int{TagColumn: {0, 3}}, ok: true},
+		{query: "work fix", todo: "fix bug", tag: "work", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			matches, ok := matchTodo(tt.query, tt.todo, tt.tag)
+
+			if ok != tt.ok {
+				t.Fatalf("matchTodo(%q) ok = %t, want %t", tt.query, ok, tt.ok)
+			}
+
+			if ok && !reflect.DeepEqual(matches, tt.want) {
+				t.Errorf("matchTodo(%q) = %v, want %v", tt.query, matches, tt.want)
+			}
+		})
+	}
+}
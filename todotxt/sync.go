@@ -0,0 +1,349 @@
+package todotxt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"todo/db"
+)
+
+// Policy decides which side wins when a task changed both in the database and
+// in the file since the last sync.
+type Policy string
+
+const (
+	PreferDatabase Policy = "db"
+	PreferFile     Policy = "file"
+	PreferNewer    Policy = "newer"
+)
+
+// ParsePolicy parses the value of the --conflict flag of the sync.
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case PreferDatabase, PreferFile, PreferNewer:
+		return Policy(s), nil
+	default:
+		return "", errors.New("Not valid conflict policy, use one of: db, file, newer")
+	}
+}
+
+// SyncStore is the part of the database used by the sync.
+type SyncStore interface {
+	GetTasksByFilter(filter db.Filter) ([]db.Todo, error)
+	AddTodo(todo db.Todo) (int, error)
+	UpdateTodo(todo db.Todo) error
+	DeleteTodo(todoId int) error
+	SyncItems(source string) ([]db.SyncItem, error)
+	SaveSyncItems(source string, items []db.SyncItem) error
+	Commit() error
+}
+
+// SyncReport counts the changes made on each side by a sync.
+type SyncReport struct {
+	Added, Updated, Deleted             int // in the database
+	FileAdded, FileUpdated, FileDeleted int
+	Conflicts                           int
+}
+
+func (r SyncReport) String() string {
+	return fmt.Sprintf(
+		"database: %d added, %d updated, %d deleted · file: %d added, %d updated, %d removed · %d conflicts",
+		r.Added, r.Updated, r.Deleted, r.FileAdded, r.FileUpdated, r.FileDeleted, r.Conflicts,
+	)
+}
+
+// Sync keeps the database and the todo.txt file at path in sync, the file is
+// created when it doesn't exist. Every line of the file gets the id: of its
+// task, the lines without one are new tasks.
+//
+// Each side is compared with how the task looked after the last sync, so a
+// task changed on one side is copied to the other and a task removed on one
+// side is removed from the other. When a task changed on both sides the policy
+// decides which one wins, newer compares the last modification of the task
+// with the one of the file.
+//
+// The store is a transaction, like the one of db.Begin, the whole file is
+// parsed before changing it and it is committed once the file is written so a
+// failed sync leaves both sides as they were.
+func Sync(store SyncStore, path string, policy Policy) (SyncReport, error) {
+	var report SyncReport
+
+	path, err := filepath.Abs(path)
+
+	if err != nil {
+		return report, err
+	}
+
+	source := "todotxt:" + path
+
+	lines, modified, exists, err := readLines(path)
+
+	if err != nil {
+		return report, err
+	}
+
+	todos, err := store.GetTasksByFilter(db.Filter{})
+
+	if err != nil {
+		return report, err
+	}
+
+	items, err := store.SyncItems(source)
+
+	if err != nil {
+		return report, err
+	}
+
+	parsedLines := make([]db.Todo, len(lines))
+
+	for n, line := range lines {
+		parsedLines[n], err = Parse(line)
+
+		if err != nil {
+			return report, fmt.Errorf("%s line %d: %w", path, n+1, err)
+		}
+	}
+
+	tasks := map[int]db.Todo{}
+	for _, todo := range todos {
+		tasks[todo.ID] = todo
+	}
+
+	// Without the file the sync starts again, instead of deleting all the
+	// tasks synced before.
+	snapshots := map[int]string{}
+	for _, item := range items {
+		if exists {
+			snapshots[item.TodoID] = item.Snapshot
+		}
+	}
+
+	synced := map[int]bool{}
+	out := []db.Todo{}
+
+	for n, line := range lines {
+		parsed := parsedLines[n]
+		task, inDB := tasks[parsed.ID]
+		snapshot, wasSynced := snapshots[parsed.ID]
+
+		switch {
+		case !inDB && wasSynced && !synced[parsed.ID] && (policy == PreferDatabase || FormatWithID(parsed) == snapshot):
+			// Deleted from the database, the line goes too unless it was
+			// edited since and the file wins.
+			if FormatWithID(parsed) != snapshot {
+				report.Conflicts++
+			}
+
+			report.FileDeleted++
+			synced[parsed.ID] = true
+			continue
+		case parsed.ID == 0 || synced[parsed.ID] || !inDB:
+			// A new line, one with an id this database doesn't know or one
+			// edited after its task was deleted from the database.
+			if !inDB && wasSynced && !synced[parsed.ID] {
+				report.Conflicts++
+			}
+
+			if parsed.ID != 0 {
+				synced[parsed.ID] = true
+			}
+
+			if parsed.DateCreated.IsZero() {
+				parsed.DateCreated = time.Now()
+			}
+
+			if parsed.State.Closed() && !parsed.DateCompleted.Valid {
+				parsed.DateCompleted.Time, parsed.DateCompleted.Valid = time.Now(), true
+			}
+
+			parsed.ID, err = store.AddTodo(parsed)
+
+			if err != nil {
+				return report, err
+			}
+
+			report.Added++
+			report.FileUpdated++
+			synced[parsed.ID] = true
+			out = append(out, parsed)
+		default:
+			merged := merge(task, parsed)
+			fileChanged := FormatWithID(merged) != snapshot
+			dbChanged := FormatWithID(task) != snapshot
+
+			useFile := fileChanged && !dbChanged
+
+			if fileChanged && dbChanged && FormatWithID(merged) != FormatWithID(task) {
+				report.Conflicts++
+				useFile = policy == PreferFile || policy == PreferNewer && modified.After(task.DateModified)
+			}
+
+			if useFile && FormatWithID(merged) != FormatWithID(task) {
+				if err := store.UpdateTodo(merged); err != nil {
+					return report, err
+				}
+
+				report.Updated++
+				task = merged
+			}
+
+			if FormatWithID(task) != line {
+				report.FileUpdated++
+			}
+
+			synced[task.ID] = true
+			out = append(out, task)
+		}
+	}
+
+	// The tasks that aren't in the file are new in the database or were removed
+	// from the file.
+	ids := []int{}
+	for id := range tasks {
+		if !synced[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		task := tasks[id]
+		snapshot, wasSynced := snapshots[id]
+
+		changed := FormatWithID(task) != snapshot
+
+		if wasSynced && changed {
+			report.Conflicts++
+		}
+
+		if wasSynced && (!changed || policy == PreferFile || policy == PreferNewer && modified.After(task.DateModified)) {
+			if err := store.DeleteTodo(id); err != nil && !errors.Is(err, db.ErrNotFound) {
+				return report, err
+			}
+
+			report.Deleted++
+			continue
+		}
+
+		report.FileAdded++
+		out = append(out, task)
+	}
+
+	newItems := []db.SyncItem{}
+	var b strings.Builder
+
+	for _, todo := range out {
+		line := FormatWithID(todo)
+		b.WriteString(line + "\n")
+		newItems = append(newItems, db.SyncItem{TodoID: todo.ID, RemoteID: strconv.Itoa(todo.ID), Snapshot: line})
+	}
+
+	if err := store.SaveSyncItems(source, newItems); err != nil {
+		return report, err
+	}
+
+	if err := writeFile(path, b.String()); err != nil {
+		return report, err
+	}
+
+	return report, store.Commit()
+}
+
+// merge returns the task with the fields of the line, the dates the line
+// doesn't have are kept.
+func merge(task, line db.Todo) db.Todo {
+	merged := task
+	merged.Todo = line.Todo
+	merged.Tag = line.Tag
+	merged.State = line.State
+	merged.Priority = line.Priority
+	merged.DateDue = line.DateDue
+
+	if !line.DateCreated.IsZero() {
+		merged.DateCreated = line.DateCreated
+	}
+
+	switch {
+	case !merged.State.Closed():
+		merged.DateCompleted.Valid = false
+	case line.DateCompleted.Valid:
+		// Keep the time of the day when the line has the same day.
+		if !sameDay(line.DateCompleted.Time, task.DateCompleted.Time) || !task.DateCompleted.Valid {
+			merged.DateCompleted = line.DateCompleted
+		}
+	case !task.DateCompleted.Valid:
+		merged.DateCompleted.Time, merged.DateCompleted.Valid = time.Now(), true
+	}
+
+	if sameDay(line.DateCreated, task.DateCreated) {
+		merged.DateCreated = task.DateCreated
+	}
+
+	if line.DateDue.Valid && task.DateDue.Valid && sameDay(line.DateDue.Time, task.DateDue.Time) {
+		merged.DateDue = task.DateDue
+	}
+
+	return merged
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Local().Format(dateLayout) == b.Local().Format(dateLayout)
+}
+
+// readLines returns the lines of the file but the blank ones, its
+// modification time and whether it exists.
+func readLines(path string) ([]string, time.Time, bool, error) {
+	content, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, time.Time{}, false, nil
+	}
+
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	lines := []string{}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, info.ModTime(), true, nil
+}
+
+// writeFile replaces the file through a temporary one so it is never left
+// half written.
+func writeFile(path, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
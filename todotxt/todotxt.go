@@ -0,0 +1,239 @@
+package todotxt
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"todo/db"
+)
+
+// dateLayout is the layout of the dates of todo.txt.
+const dateLayout = "2006-01-02"
+
+// The keys of the key:value pairs used for the fields todo.txt doesn't have.
+// The priority of a completed task is kept as pri:A, as the format suggests,
+// and the state only when it isn't the one the completion marker implies. The
+// id is only written by the sync.
+const (
+	idKey       = "id"
+	dueKey      = "due"
+	stateKey    = "state"
+	priorityKey = "pri"
+)
+
+// Format returns the todo as a todo.txt line. The tag is written as a
+// +project and the closed todos are marked with x and their completion date.
+// The words of the title Parse would read as a field, like a +project or a
+// due: key, get a backslash before them so the line parses back to the todo.
+func Format(todo db.Todo) string {
+	words := []string{}
+
+	if todo.State.Closed() {
+		words = append(words, "x")
+
+		if todo.DateCompleted.Valid {
+			words = append(words, todo.DateCompleted.Time.Local().Format(dateLayout))
+		}
+	} else if todo.Priority != "" {
+		words = append(words, "("+todo.Priority+")")
+	}
+
+	if !todo.DateCreated.IsZero() {
+		words = append(words, todo.DateCreated.Local().Format(dateLayout))
+	}
+
+	for i, word := range strings.Fields(todo.Todo) {
+		words = append(words, escape(word, i == 0))
+	}
+
+	if todo.Tag != "" {
+		words = append(words, "+"+strings.Join(strings.Fields(todo.Tag), "-"))
+	}
+
+	if todo.DateDue.Valid {
+		words = append(words, dueKey+":"+todo.DateDue.Time.Local().Format(dateLayout))
+	}
+
+	if todo.State != db.Pending && todo.State != db.Done {
+		words = append(words, stateKey+":"+todo.State.String())
+	}
+
+	if todo.State.Closed() && todo.Priority != "" {
+		words = append(words, priorityKey+":"+todo.Priority)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// escape returns the word of the title with a backslash before it when Parse
+// would read it as a field instead of as a word, first tells the first word of
+// the title, which could also be read as the completion marker, the priority or
+// a date.
+func escape(word string, first bool) string {
+	key, value, _ := strings.Cut(word, ":")
+
+	switch {
+	case strings.HasPrefix(word, `\`),
+		(strings.HasPrefix(word, "+") || strings.HasPrefix(word, "@")) && len(word) > 1,
+		value != "" && (key == idKey || key == dueKey || key == stateKey || key == priorityKey),
+		first && (word == "x" || isPriority(word)),
+		first && isDate(word):
+		return `\` + word
+	}
+
+	return word
+}
+
+// FormatWithID returns the todo.txt line of the todo followed by its id.
+func FormatWithID(todo db.Todo) string {
+	return Format(todo) + " " + idKey + ":" + strconv.Itoa(todo.ID)
+}
+
+// Parse reads a todo.txt line. The first +project, or the first @context when
+// there is no project, is the tag and is removed from the title, the rest of
+// projects and contexts stay in it. A word with a backslash before it is a
+// word of the title, without the backslash, as Format escapes them. The
+// creation date is zero when the line doesn't have one and the id is zero
+// unless the line has an id: key.
+func Parse(line string) (db.Todo, error) {
+	todo := db.Todo{State: db.Pending}
+	words := strings.Fields(line)
+
+	if len(words) > 0 && words[0] == "x" {
+		todo.State = db.Done
+		words = words[1:]
+
+		if t, ok := parseDate(words); ok {
+			todo.DateCompleted = sql.NullTime{Time: t, Valid: true}
+			words = words[1:]
+		}
+	} else if len(words) > 0 && isPriority(words[0]) {
+		todo.Priority = words[0][1:2]
+		words = words[1:]
+	}
+
+	if t, ok := parseDate(words); ok {
+		todo.DateCreated = t
+		words = words[1:]
+	}
+
+	title := []string{}
+	context := -1
+
+	for _, word := range words {
+		key, value, _ := strings.Cut(word, ":")
+
+		switch {
+		case strings.HasPrefix(word, `\`) && len(word) > 1:
+			title = append(title, word[1:])
+			continue
+		case strings.HasPrefix(word, "+") && len(word) > 1 && todo.Tag == "":
+			todo.Tag = word[1:]
+			continue
+		case strings.HasPrefix(word, "@") && len(word) > 1 && context == -1:
+			context = len(title)
+		case key == idKey && value != "":
+			id, err := strconv.Atoi(value)
+
+			if err != nil || id <= 0 {
+				return todo, fmt.Errorf("Not valid id %q", value)
+			}
+
+			todo.ID = id
+			continue
+		case key == dueKey && value != "":
+			due, err := time.ParseInLocation(dateLayout, value, time.Local)
+
+			if err != nil {
+				return todo, fmt.Errorf("Not valid due date %q, use YYYY-MM-DD", value)
+			}
+
+			todo.DateDue = sql.NullTime{Time: due, Valid: true}
+			continue
+		case key == stateKey && value != "":
+			state, err := db.ParseStatus(value)
+
+			if err != nil {
+				return todo, err
+			}
+
+			todo.State = state
+			continue
+		case key == priorityKey && len(value) == 1 && db.ValidPriority(strings.ToUpper(value)):
+			todo.Priority = strings.ToUpper(value)
+			continue
+		}
+
+		title = append(title, word)
+	}
+
+	if todo.Tag == "" && context != -1 {
+		todo.Tag = title[context][1:]
+		title = append(title[:context], title[context+1:]...)
+	}
+
+	todo.Todo = strings.Join(title, " ")
+
+	if todo.Todo == "" {
+		return todo, fmt.Errorf("Not valid task %q, the title is empty", line)
+	}
+
+	return todo, nil
+}
+
+func parseDate(words []string) (time.Time, bool) {
+	if len(words) == 0 {
+		return time.Time{}, false
+	}
+
+	t, err := time.ParseInLocation(dateLayout, words[0], time.Local)
+
+	return t, err == nil
+}
+
+func isDate(word string) bool {
+	_, ok := parseDate([]string{word})
+
+	return ok
+}
+
+func isPriority(word string) bool {
+	return len(word) == 3 && word[0] == '(' && word[1] >= 'A' && word[1] <= 'Z' && word[2] == ')'
+}
+
+// Read parses every line of a todo.txt file, the blank ones are skipped.
+func Read(r io.Reader) ([]db.Todo, error) {
+	todos := []db.Todo{}
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		todo, err := Parse(scanner.Text())
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos, scanner.Err()
+}
+
+// Write writes a todo.txt line for every todo.
+func Write(w io.Writer, todos []db.Todo) error {
+	for _, todo := range todos {
+		if _, err := fmt.Fprintln(w, Format(todo)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
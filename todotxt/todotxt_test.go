@@ -0,0 +1,164 @@
+package todotxt
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+	"todo/db"
+)
+
+func day(s string) time.Time {
+	t, err := time.ParseInLocation(dateLayout, s, time.Local)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestFormatParse(t *testing.T) {
+	created := day("2024-01-02")
+	completed := sql.NullTime{Time: day("2024-01-05"), Valid: true}
+	due := sql.NullTime{Time: day("2024-01-10"), Valid: true}
+
+	tests := []struct {
+		name string
+		todo db.Todo
+		line string
+	}{
+		{
+			name: "pending",
+			todo: db.Todo{Todo: "Write the notes", Tag: "docs", State: db.Pending, Priority: "A", DateCreated: created, DateDue: due},
+			line: "(A) 2024-01-02 Write the notes +docs due:2024-01-10",
+		},
+		{
+			name: "done",
+			todo: db.Todo{Todo: "Fix the login", Tag: "web", State: db.Done, Priority: "B", DateCreated: created, DateCompleted: completed},
+			line: "x 2024-01-05 2024-01-02 Fix the login +web pri:B",
+		},
+		{
+			name: "other state",
+			todo: db.Todo{Todo: "Migrate", State: db.Cancelled, DateCreated: created, DateCompleted: completed},
+			line: "x 2024-01-05 2024-01-02 Migrate state:cancelled",
+		},
+		{
+			name: "projects and contexts in the title",
+			todo: db.Todo{Todo: "Call @mom about +garden", Tag: "home", State: db.Pending, DateCreated: created},
+			line: `2024-01-02 Call \@mom about \+garden +home`,
+		},
+		{
+			name: "context in the title without tag",
+			todo: db.Todo{Todo: "Call @mom", State: db.Pending, DateCreated: created},
+			line: `2024-01-02 Call \@mom`,
+		},
+		{
+			name: "keys in the title",
+			todo: db.Todo{Todo: "Set due:friday state:x id:7 pri:A", State: db.Pending, DateCreated: created},
+			line: `2024-01-02 Set \due:friday \state:x \id:7 \pri:A`,
+		},
+		{
+			name: "backslash in the title",
+			todo: db.Todo{Todo: `Escape \n and \`, State: db.Pending, DateCreated: created},
+			line: `2024-01-02 Escape \\n and \\`,
+		},
+		{
+			name: "completion marker first",
+			todo: db.Todo{Todo: "x marks the spot", State: db.Pending},
+			line: `\x marks the spot`,
+		},
+		{
+			name: "priority first",
+			todo: db.Todo{Todo: "(B) plan", State: db.Pending},
+			line: `\(B) plan`,
+		},
+		{
+			name: "date first",
+			todo: db.Todo{Todo: "2024-03-01 deadline", State: db.Done, DateCompleted: completed},
+			line: `x 2024-01-05 \2024-03-01 deadline`,
+		},
+		{
+			name: "words that aren't fields",
+			todo: db.Todo{Todo: "Read x + @ due: at 10:30", State: db.Pending},
+			line: "Read x + @ due: at 10:30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := Format(tt.todo)
+
+			if line != tt.line {
+				t.Errorf("Format() = %q, want %q", line, tt.line)
+			}
+
+			parsed, err := Parse(line)
+
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", line, err)
+			}
+
+			if !reflect.DeepEqual(parsed, tt.todo) {
+				t.Errorf("Parse(Format()) = %+v, want %+v", parsed, tt.todo)
+			}
+		})
+	}
+}
+
+func TestFormatWithIDParse(t *testing.T) {
+	todo := db.Todo{ID: 12, Todo: "Ask about id:3", Tag: "work", State: db.Pending, DateCreated: day("2024-01-02")}
+
+	parsed, err := Parse(FormatWithID(todo))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, todo) {
+		t.Errorf("Parse(FormatWithID()) = %+v, want %+v", parsed, todo)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line    string
+		todo    db.Todo
+		wantErr bool
+	}{
+		{
+			line: "Buy milk @shop +home @later",
+			todo: db.Todo{Todo: "Buy milk @shop @later", Tag: "home", State: db.Pending},
+		},
+		{
+			line: "Buy milk @shop @later",
+			todo: db.Todo{Todo: "Buy milk @later", Tag: "shop", State: db.Pending},
+		},
+		{
+			line: "x Done without dates",
+			todo: db.Todo{Todo: "Done without dates", State: db.Done},
+		},
+		{
+			line: "Read pri:a",
+			todo: db.Todo{Todo: "Read", State: db.Pending, Priority: "A"},
+		},
+		{line: "Pay due:tomorrow", wantErr: true},
+		{line: "Pay id:abc", wantErr: true},
+		{line: "Pay state:unknown", wantErr: true},
+		{line: "(A) 2024-01-02 +home", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			todo, err := Parse(tt.line)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(todo, tt.todo) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.line, todo, tt.todo)
+			}
+		})
+	}
+}
@@ -0,0 +1,487 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"todo/add"
+	"todo/db"
+	todo_table "todo/todo-table"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Store is the part of the database used by the task manager, every change is
+// written as soon as it is made.
+type Store interface {
+	GetTasks(tag string) ([]db.Todo, error)
+	CreateTodo(title string, tag string) error
+	ChangeTodoName(todoId int, newName string) error
+	CompleteTodo(todoId int) error
+	UncompleteTodo(todoId int) error
+	DeleteTodo(todoId int) error
+}
+
+type keyMap struct {
+	Add        key.Binding
+	Edit       key.Binding
+	ToggleDone key.Binding
+	Delete     key.Binding
+	NextTag    key.Binding
+	PrevTag    key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	Filter     key.Binding
+	Sort       key.Binding
+	Reverse    key.Binding
+	Group      key.Binding
+	Help       key.Binding
+	Quit       key.Binding
+}
+
+// ShortHelp returns keybindings to be shown in the mini help view. It's part
+// of the key.Map interface.
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Add, k.Edit, k.ToggleDone, k.Delete, k.Filter, k.NextTag, k.Help, k.Quit}
+}
+
+// FullHelp returns keybindings for the expanded help view. It's part of the
+// key.Map interface.
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.NextTag, k.PrevTag},    // first column
+		{k.Add, k.Edit, k.ToggleDone, k.Delete}, // second column
+		{k.Filter, k.Sort, k.Reverse, k.Group},  // third column
+		{k.Help, k.Quit},                        // fourth column
+	}
+}
+
+var keys = keyMap{
+	Add: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "add task"),
+	),
+	Edit: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "edit task"),
+	),
+	ToggleDone: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "toggle done"),
+	),
+	Delete: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "delete task"),
+	),
+	NextTag: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "next tag"),
+	),
+	PrevTag: key.NewBinding(
+		key.WithKeys("shift+tab"),
+		key.WithHelp("shift+tab", "previous tag"),
+	),
+	Up:      todo_table.Keys.Up,
+	Down:    todo_table.Keys.Down,
+	Filter:  todo_table.Keys.Filter,
+	Sort:    todo_table.Keys.Sort,
+	Reverse: todo_table.Keys.Reverse,
+	Group:   todo_table.Keys.Group,
+	Help: key.NewBinding(
+		key.WithKeys("?"),
+		key.WithHelp("?", "toggle help"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "ctrl+c"),
+		key.WithHelp("q/ctrl+c", "quit"),
+	),
+}
+
+// The keys used while an input or a confirmation is open.
+var (
+	confirmKey = key.NewBinding(key.WithKeys("enter"))
+	cancelKey  = key.NewBinding(key.WithKeys("esc", "ctrl+c"))
+	yesKey     = key.NewBinding(key.WithKeys("y", "Y"))
+)
+
+const (
+	sidebarWidth = 22
+	allTags      = "all"
+	noTag        = "no tag"
+)
+
+var (
+	baseStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(lipgloss.Color("240"))
+
+	sidebarStyle = baseStyle.Copy().
+			Width(sidebarWidth-2).
+			Padding(0, 1)
+
+	currentTagStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("229")).
+			Background(lipgloss.Color("57"))
+
+	titleStyle = lipgloss.NewStyle().
+			Bold(true).
+			MarginBottom(1)
+
+	statusStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196"))
+)
+
+type mode int
+
+const (
+	browsing mode = iota
+	adding
+	editing
+	deleting
+)
+
+type tag struct {
+	name  string
+	count int
+}
+
+// Model is the full screen task manager, it composes the todo table, the add
+// input and a sidebar to switch between tags.
+type Model struct {
+	store   Store
+	keys    keyMap
+	help    help.Model
+	table   todo_table.Model
+	input   add.Model
+	mode    mode
+	todos   []db.Todo
+	tags    []tag
+	tag     int
+	editing db.Todo
+	message string
+	err     error
+	width   int
+	height  int
+}
+
+func New(store Store) (Model, error) {
+	m := Model{
+		store: store,
+		keys:  keys,
+		help:  help.New(),
+		table: todo_table.New(nil),
+	}
+
+	if err := m.reload(); err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.resize()
+		return m, nil
+	case tea.KeyMsg:
+		switch m.mode {
+		case adding, editing:
+			return m.updateInput(msg)
+		case deleting:
+			return m.updateDelete(msg)
+		}
+
+		if m.table.Filtering() {
+			break
+		}
+
+		switch {
+		case key.Matches(msg, todo_table.Keys.Cancel) && m.table.HasFilter():
+			break
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+			m.resize()
+			return m, nil
+		case key.Matches(msg, m.keys.NextTag):
+			m.switchTag(1)
+			return m, nil
+		case key.Matches(msg, m.keys.PrevTag):
+			m.switchTag(-1)
+			return m, nil
+		case key.Matches(msg, m.keys.Add):
+			m.mode = adding
+			m.input = add.AddInputModel()
+			m.resize()
+			return m, m.input.Init()
+		case key.Matches(msg, m.keys.Edit):
+			if todo, ok := m.table.SelectedTodo(); ok {
+				m.mode = editing
+				m.editing = todo
+				m.input = add.EditInputModel(todo.Todo)
+				m.resize()
+				return m, m.input.Init()
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.ToggleDone):
+			m.toggleDone()
+			return m, nil
+		case key.Matches(msg, m.keys.Delete):
+			if todo, ok := m.table.SelectedTodo(); ok {
+				m.mode = deleting
+				m.editing = todo
+			}
+			return m, nil
+		}
+	}
+
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+// updateInput handles the keys while adding or editing a task, the add model
+// quits the program on enter and esc so those keys aren't passed to it.
+func (m Model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, cancelKey):
+		m.mode = browsing
+		m.resize()
+		return m, nil
+	case key.Matches(msg, confirmKey):
+		updated, _ := m.input.Update(msg)
+		value := strings.TrimSpace(updated.(add.Model).Value)
+
+		if value == "" {
+			m.setResult("", errors.New("Cannot add empty task"))
+			return m, nil
+		}
+
+		if m.mode == adding {
+			m.setResult(fmt.Sprintf("new task %q created correctly.", value), m.store.CreateTodo(value, m.currentTag()))
+		} else {
+			m.setResult(fmt.Sprintf("task with the id %d renamed.", m.editing.ID), m.store.ChangeTodoName(m.editing.ID, value))
+		}
+
+		m.mode = browsing
+		m.resize()
+		return m, nil
+	}
+
+	updated, cmd := m.input.Update(msg)
+	m.input = updated.(add.Model)
+	return m, cmd
+}
+
+// updateDelete asks for confirmation before deleting the task.
+func (m Model) updateDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.mode = browsing
+
+	if key.Matches(msg, yesKey) {
+		m.setResult(fmt.Sprintf("task with the id %d deleted.", m.editing.ID), m.store.DeleteTodo(m.editing.ID))
+	} else {
+		m.message = ""
+	}
+
+	return m, nil
+}
+
+func (m *Model) toggleDone() {
+	todo, ok := m.table.SelectedTodo()
+
+	if !ok {
+		return
+	}
+
+	if todo.State.Closed() {
+		m.setResult(fmt.Sprintf("task with the id %d marked as pending.", todo.ID), m.store.UncompleteTodo(todo.ID))
+	} else {
+		m.setResult(fmt.Sprintf("task with the id %d marked as done.", todo.ID), m.store.CompleteTodo(todo.ID))
+	}
+}
+
+// setResult shows the outcome of a change in the status bar and reloads the
+// tasks from the database.
+func (m *Model) setResult(message string, err error) {
+	m.message = message
+	m.err = err
+
+	if err == nil {
+		m.err = m.reload()
+	}
+}
+
+// reload reads every task again, rebuilding the tags of the sidebar and the
+// tasks of the table.
+func (m *Model) reload() error {
+	todos, err := m.store.GetTasks("")
+
+	if err != nil {
+		return err
+	}
+
+	showingAll := m.tag == 0
+	current := m.currentTag()
+	m.todos = todos
+
+	counts := map[string]int{}
+
+	for _, todo := range todos {
+		counts[todo.Tag]++
+	}
+
+	names := []string{}
+
+	for name := range counts {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	m.tags = []tag{{name: allTags, count: len(todos)}}
+	m.tag = 0
+
+	for _, name := range names {
+		m.tags = append(m.tags, tag{name: name, count: counts[name]})
+
+		if name == current && !showingAll {
+			m.tag = len(m.tags) - 1
+		}
+	}
+
+	m.showTag()
+
+	return nil
+}
+
+func (m *Model) switchTag(step int) {
+	m.tag = (m.tag + step + len(m.tags)) % len(m.tags)
+	m.showTag()
+}
+
+func (m *Model) showTag() {
+	if m.tag == 0 {
+		m.table.SetTodos(m.todos)
+		return
+	}
+
+	todos := []db.Todo{}
+
+	for _, todo := range m.todos {
+		if todo.Tag == m.tags[m.tag].name {
+			todos = append(todos, todo)
+		}
+	}
+
+	m.table.SetTodos(todos)
+}
+
+// currentTag returns the tag shown, it is given to the new tasks.
+func (m Model) currentTag() string {
+	if m.tag == 0 || m.tag >= len(m.tags) {
+		return ""
+	}
+	return m.tags[m.tag].name
+}
+
+// resize fits the table in the space left by the sidebar, the input, the
+// status bar and the help.
+func (m *Model) resize() {
+	if m.width == 0 {
+		return
+	}
+
+	m.help.Width = m.width
+
+	// The border of the table takes two lines and the status of the table and
+	// the status bar one line each.
+	height := m.height - 4 - lipgloss.Height(m.help.View(m.keys))
+
+	if m.mode == adding || m.mode == editing {
+		height -= lipgloss.Height(m.input.View())
+	}
+
+	m.table.SetSize(m.width-sidebarWidth-2, height)
+}
+
+func (m Model) View() string {
+	body := lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		m.sidebarView(),
+		baseStyle.Render(m.table.View()),
+	)
+
+	view := body + "\n" + m.table.StatusView()
+
+	if m.mode == adding || m.mode == editing {
+		view += m.input.View()
+	}
+
+	return view + m.statusBarView() + "\n" + m.help.View(m.keys)
+}
+
+func (m Model) sidebarView() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Tags"))
+	b.WriteString("\n")
+
+	for i, t := range m.tags {
+		name := t.name
+		if name == "" {
+			name = noTag
+		}
+
+		line := fmt.Sprintf("%-*s%3d", sidebarWidth-7, todo_table.Truncate(name, sidebarWidth-7), t.count)
+
+		if i == m.tag {
+			line = currentTagStyle.Render(line)
+		}
+
+		b.WriteString(line + "\n")
+	}
+
+	return sidebarStyle.Render(strings.TrimSuffix(b.String(), "\n"))
+}
+
+func (m Model) statusBarView() string {
+	if m.mode == deleting {
+		return errorStyle.Render(fmt.Sprintf("delete task %d %q? y/N", m.editing.ID, m.editing.Todo))
+	}
+
+	if m.err != nil {
+		return errorStyle.Render(m.err.Error())
+	}
+
+	closed := 0
+
+	for _, todo := range m.todos {
+		if todo.State.Closed() {
+			closed++
+		}
+	}
+
+	status := fmt.Sprintf("%d tasks · %d open · %d closed", len(m.todos), len(m.todos)-closed, closed)
+
+	if m.message != "" {
+		status += " · " + m.message
+	}
+
+	return statusStyle.Render(status)
+}